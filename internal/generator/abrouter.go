@@ -0,0 +1,133 @@
+package generator
+
+var abRouterTmpl = newTemplate("ab-router", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+	{{.GoImport}}
+)
+
+// {{.Name}}ABConfig controls the share of calls served by the B
+// implementation.
+type {{.Name}}ABConfig struct {
+	// Percent is the share of calls served by B, from 0 to 100.
+	Percent float64
+	// Methods overrides Percent for the named methods.
+	Methods map[string]float64
+	// StickyKey, if set, is the metadata key whose value buckets the calls,
+	// e.g. x-user-id, so that the calls carrying the same value are served by
+	// the same implementation as long as the percentage holds. Calls without
+	// it are split at random.
+	StickyKey string
+	// OverrideKey, if set, is the metadata key letting a call pick its
+	// implementation with the value "a" or "b", e.g. to try B out.
+	OverrideKey string
+	// OnRoute, if set, is called with the implementation picked for every
+	// call or stream.
+	OnRoute func(method string, b bool)
+}
+
+// {{.Name}}ABRouter serves every method of {{.Name}} from one of two
+// implementations, e.g. the legacy one as A and a rewrite as B, for methods
+// to move to the rewrite incrementally. It is safe for concurrent use.
+type {{.Name}}ABRouter struct {
+	a, b {{.GoPrefix}}.{{.Name}}Server
+
+	mu  sync.RWMutex
+	cfg {{.Name}}ABConfig
+}
+
+var _ {{.GoPrefix}}.{{.Name}}Server = (*{{.Name}}ABRouter)(nil)
+
+// New{{.Name}}ABRouter returns a server splitting calls between a and b as
+// configured by cfg.
+func New{{.Name}}ABRouter(a, b {{.GoPrefix}}.{{.Name}}Server, cfg {{.Name}}ABConfig) *{{.Name}}ABRouter {
+	return &{{.Name}}ABRouter{a: a, b: b, cfg: cfg}
+}
+
+// SetConfig replaces the configuration, e.g. when it is reloaded. Calls
+// already routed are left as they are.
+func (r *{{.Name}}ABRouter) SetConfig(cfg {{.Name}}ABConfig) {
+	r.mu.Lock()
+	r.cfg = cfg
+	r.mu.Unlock()
+}
+
+// pick returns the implementation serving a call to method.
+func (r *{{.Name}}ABRouter) pick(ctx context.Context, method string) {{.GoPrefix}}.{{.Name}}Server {
+	r.mu.RLock()
+	cfg := r.cfg
+	r.mu.RUnlock()
+
+	b := {{.LowerName}}RouteB(ctx, method, cfg)
+	if cfg.OnRoute != nil {
+		cfg.OnRoute(method, b)
+	}
+	if b {
+		return r.b
+	}
+	return r.a
+}
+
+// {{.LowerName}}RouteB reports whether cfg routes a call to method to B.
+func {{.LowerName}}RouteB(ctx context.Context, method string, cfg {{.Name}}ABConfig) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if cfg.OverrideKey != "" {
+		if v := md.Get(cfg.OverrideKey); len(v) > 0 {
+			switch v[0] {
+			case "a":
+				return false
+			case "b":
+				return true
+			}
+		}
+	}
+	percent := cfg.Percent
+	if p, ok := cfg.Methods[method]; ok {
+		percent = p
+	}
+	if percent <= 0 {
+		return false
+	}
+	if percent >= 100 {
+		return true
+	}
+	if cfg.StickyKey != "" {
+		if v := md.Get(cfg.StickyKey); len(v) > 0 {
+			h := fnv.New32a()
+			h.Write([]byte(v[0]))
+			return float64(h.Sum32()%10000) < percent*100
+		}
+	}
+	return rand.Float64()*100 < percent
+}
+{{ range .Methods }}
+	{{- if .GetClientStreaming }}
+
+// {{.Name}} serves the stream from the implementation picked for it.
+func (r *{{$.Name}}ABRouter) {{.Name}}(stream {{$.GoPrefix}}.{{.StreamName}}) error {
+	return r.pick(stream.Context(), "{{.Name}}").{{.Name}}(stream)
+}
+	{{- else if .GetServerStreaming }}
+
+// {{.Name}} serves the stream from the implementation picked for it.
+func (r *{{$.Name}}ABRouter) {{.Name}}(in *{{$.GoPrefix}}.{{.InputGoName}}, stream {{$.GoPrefix}}.{{.StreamName}}) error {
+	return r.pick(stream.Context(), "{{.Name}}").{{.Name}}(in, stream)
+}
+	{{- else }}
+
+// {{.Name}} serves the call from the implementation picked for it.
+func (r *{{$.Name}}ABRouter) {{.Name}}(ctx context.Context, in *{{$.GoPrefix}}.{{.InputGoName}}) (*{{$.GoPrefix}}.{{.OutputGoName}}, error) {
+	return r.pick(ctx, "{{.Name}}").{{.Name}}(ctx, in)
+}
+	{{- end }}
+{{- end }}
+`)
@@ -0,0 +1,196 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// wantsAmalgamation reports whether any service is generated with
+// amalgamate=true, which then applies to the whole response.
+func wantsAmalgamation(ps []*Service) bool {
+	for _, p := range ps {
+		if p.Amalgamate {
+			return true
+		}
+	}
+	return false
+}
+
+// amalgamable reports whether a generated file can be merged into the
+// amalgamated file of its directory: Go files other than tests and files
+// with build constraints, which must stay files of their own.
+func amalgamable(f *plugin.CodeGeneratorResponse_File) bool {
+	name := f.GetName()
+	if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	for _, line := range strings.Split(f.GetContent(), "\n") {
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if strings.HasPrefix(line, "// +build ") || strings.HasPrefix(line, "//go:build ") {
+			return false
+		}
+	}
+	return true
+}
+
+// amalgamate merges the amalgamable files of every output directory into a
+// single <package>.gen.go, where owners[i] is the owner of files[i]. The
+// other files, and directories with a single file, are left as they are.
+func amalgamate(files []*plugin.CodeGeneratorResponse_File, owners []fileOwner) ([]*plugin.CodeGeneratorResponse_File, []fileOwner, error) {
+	groups := make(map[string][]int)
+	var dirs []string
+	var outFiles []*plugin.CodeGeneratorResponse_File
+	var outOwners []fileOwner
+	for i, f := range files {
+		if !amalgamable(f) {
+			outFiles, outOwners = append(outFiles, f), append(outOwners, owners[i])
+			continue
+		}
+		dir := path.Dir(f.GetName())
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], i)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		var (
+			group       []*plugin.CodeGeneratorResponse_File
+			groupOwners []fileOwner
+		)
+		for _, i := range groups[dir] {
+			group, groupOwners = append(group, files[i]), append(groupOwners, owners[i])
+		}
+		if len(group) == 1 {
+			outFiles, outOwners = append(outFiles, group[0]), append(outOwners, groupOwners[0])
+			continue
+		}
+		pkg, content, err := amalgamateFiles(group, groupOwners)
+		if err != nil {
+			return nil, nil, errors.New("unable to amalgamate " + dir + ": " + err.Error())
+		}
+		outFiles = append(outFiles, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(dir, pkg+".gen.go")),
+			Content: proto.String(string(content)),
+		})
+		outOwners = append(outOwners, mergeOwners(groupOwners))
+	}
+	return outFiles, outOwners, nil
+}
+
+// mergeOwners returns the owner of the file merging those of owners: the
+// first one sets its options, and it covers every service they cover, with
+// all the methods rendered for each.
+func mergeOwners(owners []fileOwner) fileOwner {
+	merged := fileOwner{Service: owners[0].Service}
+	index := make(map[*Service]int)
+	seen := make(map[*Service]map[string]bool)
+	for _, o := range owners {
+		for _, c := range o.covers {
+			i, ok := index[c.service]
+			if !ok {
+				i = len(merged.covers)
+				index[c.service] = i
+				seen[c.service] = make(map[string]bool)
+				merged.covers = append(merged.covers, coverage{service: c.service})
+			}
+			for _, m := range c.methods {
+				if !seen[c.service][m.GetName()] {
+					seen[c.service][m.GetName()] = true
+					merged.covers[i].methods = append(merged.covers[i].methods, m)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// amalgamateFiles merges the files of a directory, group[i] generated for
+// owners[i], into one: a header naming their proto files, the package
+// clause, the union of their imports and their declarations in order. It
+// returns the name of their package along with the merged file.
+func amalgamateFiles(group []*plugin.CodeGeneratorResponse_File, owners []fileOwner) (string, []byte, error) {
+	var (
+		pkg     string
+		sources []string
+		imports []string
+		bodies  bytes.Buffer
+	)
+	seenSource := make(map[string]bool)
+	seenImport := make(map[string]bool)
+	importPaths := make(map[string]string)
+	for n, f := range group {
+		src := []byte(f.GetContent())
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, f.GetName(), src, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		if pkg == "" {
+			pkg = file.Name.Name
+		} else if file.Name.Name != pkg {
+			return "", nil, errors.New(f.GetName() + " is in package " + file.Name.Name + ", not " + pkg)
+		}
+		for _, c := range owners[n].covers {
+			if !seenSource[c.service.ProtoName] {
+				seenSource[c.service.ProtoName] = true
+				sources = append(sources, c.service.ProtoName)
+			}
+		}
+
+		for _, imp := range file.Imports {
+			name := importName(imp, owners[n].importNames())
+			if prev, ok := importPaths[name]; ok && prev != imp.Path.Value {
+				return "", nil, errors.New(prev + " and " + imp.Path.Value + " are both imported as " + name)
+			}
+			importPaths[name] = imp.Path.Value
+			spec := imp.Path.Value
+			if imp.Name != nil {
+				spec = imp.Name.Name + " " + spec
+			}
+			if !seenImport[spec] {
+				seenImport[spec] = true
+				imports = append(imports, spec)
+			}
+		}
+
+		// The declarations start after the imports, or the package clause
+		// of files without any.
+		start := fset.Position(file.Name.End()).Offset
+		for _, d := range file.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				start = fset.Position(gd.End()).Offset
+			}
+		}
+		bodies.Write(bytes.TrimSpace(src[start:]))
+		bodies.WriteString("\n\n")
+	}
+
+	var w bytes.Buffer
+	w.WriteString("// Code initially generated by protoc-gen-grpc-go-service\n")
+	w.WriteString("// source: " + strings.Join(sources, ", ") + "\n\n")
+	w.WriteString("package " + pkg + "\n\n")
+	if len(imports) > 0 {
+		w.WriteString("import (\n")
+		for _, spec := range imports {
+			w.WriteString("\t" + spec + "\n")
+		}
+		w.WriteString(")\n\n")
+	}
+	w.Write(bodies.Bytes())
+	content, err := format.Source(w.Bytes())
+	return pkg, content, err
+}
@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// indexField is a field of a message of the API index.
+type indexField struct {
+	Name      string
+	Number    int32
+	Type      string
+	Repeated  bool
+	Sensitive bool
+}
+
+// indexFields returns the fields of the message named name, or nil for
+// messages declared in no file of the request.
+func (idx messageIndex) indexFields(name string) []indexField {
+	msg, ok := idx[name]
+	if !ok {
+		return nil
+	}
+	var fs []indexField
+	for _, f := range msg.GetField() {
+		typ := strings.TrimPrefix(f.GetTypeName(), ".")
+		if typ == "" {
+			typ = strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+		}
+		fs = append(fs, indexField{
+			Name:      f.GetName(),
+			Number:    f.GetNumber(),
+			Type:      typ,
+			Repeated:  f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+			Sensitive: sensitive(f),
+		})
+	}
+	return fs
+}
+
+// InputFields returns the fields of the input of the method.
+func (m method) InputFields() []indexField {
+	return m.messages.indexFields(m.GetInputType())
+}
+
+// OutputFields returns the fields of the output of the method.
+func (m method) OutputFields() []indexField {
+	return m.messages.indexFields(m.GetOutputType())
+}
+
+// TimeoutExpr returns the Go expression of the (service_gen.timeout) of the
+// method, 0 when it has none.
+func (m method) TimeoutExpr() (string, error) {
+	if m.Timeout() == "" {
+		return "0", nil
+	}
+	d, err := time.ParseDuration(m.Timeout())
+	if err != nil {
+		return "", errors.New("invalid timeout option on " + m.GetName() + ": " + err.Error())
+	}
+	return durationExpr(d), nil
+}
+
+// quotedList returns the Go literals of s separated by commas.
+func quotedList(s []string) string {
+	q := make([]string, len(s))
+	for i, v := range s {
+		q[i] = strconv.Quote(v)
+	}
+	return strings.Join(q, ", ")
+}
+
+// QuotedRoles returns the (service_gen.required_roles) of the method as Go
+// literals separated by commas.
+func (m method) QuotedRoles() string {
+	return quotedList(m.RequiredRoles())
+}
+
+// QuotedDownstream returns the (service_gen.downstream) of the method as Go
+// literals separated by commas.
+func (m method) QuotedDownstream() string {
+	return quotedList(m.Downstream())
+}
+
+// QuotedBaggageFields returns the (service_gen.baggage_fields) of the
+// method as Go literals separated by commas.
+func (m method) QuotedBaggageFields() string {
+	return quotedList(m.BaggageFields())
+}
+
+var apiIndexTmpl = newTemplate("apiindex", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"time"
+)
+
+// {{.Name}}APIField is a field of a message of {{.Name}}.
+type {{.Name}}APIField struct {
+	Name   string
+	Number int32
+	// Type is the scalar type of the field, e.g. "string", or the full name
+	// of its message or enum type.
+	Type     string
+	Repeated bool
+	// Sensitive reports whether the field is marked (service_gen.sensitive).
+	Sensitive bool
+}
+
+// {{.Name}}APIMessage is the input or output of a method of {{.Name}}.
+type {{.Name}}APIMessage struct {
+	// Name is the full proto name of the message.
+	Name string
+	// Fields are the fields of the message, nil for messages imported from
+	// packages outside of the generation.
+	Fields []{{.Name}}APIField
+}
+
+// {{.Name}}APIMethod is a method of {{.Name}} with the options in effect.
+type {{.Name}}APIMethod struct {
+	// Name is the name of the method in its proto file.
+	Name string
+	// FullMethod is the full name gRPC calls the method by.
+	FullMethod      string
+	Input           {{.Name}}APIMessage
+	Output          {{.Name}}APIMessage
+	ClientStreaming bool
+	ServerStreaming bool
+	Deprecated      bool
+	// Idempotent reports whether the method is IDEMPOTENT or NO_SIDE_EFFECTS.
+	Idempotent bool
+	// Timeout is the (service_gen.timeout) of the method, 0 without one.
+	Timeout time.Duration
+	// Sunset is the time the method is retired at, zero without one.
+	Sunset          time.Time
+	Owner           string
+	FeatureFlag     string
+	TenantRequired  bool
+	MaxRequestBytes uint64
+	RequiredRoles   []string
+	Downstream      []string
+	CacheControl    string
+	BaggageFields   []string
+}
+
+// {{.Name}}APIService describes {{.Name}}.
+type {{.Name}}APIService struct {
+	// Name is the full proto name of the service.
+	Name string
+	// ProtoFile is the proto file declaring the service.
+	ProtoFile string
+	Owner     string
+	Methods   []{{.Name}}APIMethod
+}
+
+// {{.Name}}API is the machine-readable description of {{.Name}}, generated
+// from its proto file for tooling importing it, e.g. developer portals.
+var {{.Name}}API = {{.Name}}APIService{
+	Name:      "{{.FullName}}",
+	ProtoFile: "{{.ProtoName}}",
+	Owner:     {{printf "%q" .Owner}},
+	Methods: []{{.Name}}APIMethod{
+		{{- range .Methods }}
+		{
+			Name:       "{{.WireName}}",
+			FullMethod: "/{{$.FullName}}/{{.WireName}}",
+			Input: {{$.Name}}APIMessage{
+				Name: "{{.TrimmedInput}}",
+				{{- with .InputFields }}
+				Fields: []{{$.Name}}APIField{
+					{{- range . }}
+					{Name: "{{.Name}}", Number: {{.Number}}, Type: "{{.Type}}", Repeated: {{.Repeated}}, Sensitive: {{.Sensitive}}},
+					{{- end }}
+				},
+				{{- end }}
+			},
+			Output: {{$.Name}}APIMessage{
+				Name: "{{.TrimmedOutput}}",
+				{{- with .OutputFields }}
+				Fields: []{{$.Name}}APIField{
+					{{- range . }}
+					{Name: "{{.Name}}", Number: {{.Number}}, Type: "{{.Type}}", Repeated: {{.Repeated}}, Sensitive: {{.Sensitive}}},
+					{{- end }}
+				},
+				{{- end }}
+			},
+			ClientStreaming: {{.GetClientStreaming}},
+			ServerStreaming: {{.GetServerStreaming}},
+			Deprecated:      {{.GetOptions.GetDeprecated}},
+			Idempotent:      {{.Idempotent}},
+			Timeout:         {{.TimeoutExpr}},
+			{{- if .Sunset }}
+			Sunset:          {{.SunsetExpr}},
+			{{- end }}
+			Owner:           {{printf "%q" .Owner}},
+			FeatureFlag:     {{printf "%q" .FeatureFlag}},
+			TenantRequired:  {{.TenantRequired}},
+			MaxRequestBytes: {{.MaxRequestBytes}},
+			{{- with .QuotedRoles }}
+			RequiredRoles: []string{ {{- . -}} },
+			{{- end }}
+			{{- with .QuotedDownstream }}
+			Downstream: []string{ {{- . -}} },
+			{{- end }}
+			{{- if .CacheControl }}
+			CacheControl: {{.CacheControlLiteral}},
+			{{- end }}
+			{{- with .QuotedBaggageFields }}
+			BaggageFields: []string{ {{- . -}} },
+			{{- end }}
+		},
+		{{- end }}
+	},
+}
+`)
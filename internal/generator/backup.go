@@ -0,0 +1,253 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// backupFiles returns, for every file about to overwrite a different file
+// in the OutputDir of a service generated with Backup, either a .bak copy
+// of the previous contents or a .diff from them to the new ones. owners[i]
+// is the owner of files[i], which also owns its backup; the owners of the
+// backups are returned alongside.
+func backupFiles(files []*plugin.CodeGeneratorResponse_File, owners []fileOwner) ([]*plugin.CodeGeneratorResponse_File, []fileOwner) {
+	var (
+		backups      []*plugin.CodeGeneratorResponse_File
+		backupOwners []fileOwner
+	)
+	for i, f := range files {
+		p := owners[i]
+		if p.Backup == "" {
+			continue
+		}
+		prev, err := ioutil.ReadFile(diskPath(p.OutputDir, f.GetName()))
+		// Files checked out with CRLF line endings, e.g. by git on Windows,
+		// are only different when their lines are.
+		if err != nil || unixNewlines(string(prev)) == f.GetContent() {
+			continue
+		}
+
+		b := &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(f.GetName() + ".bak"),
+			Content: proto.String(string(prev)),
+		}
+		if p.Backup == "diff" {
+			b.Name = proto.String(f.GetName() + ".diff")
+			b.Content = proto.String(unifiedDiff(f.GetName(), unixNewlines(string(prev)), f.GetContent()))
+		}
+		backups = append(backups, b)
+		backupOwners = append(backupOwners, p)
+	}
+	return backups, backupOwners
+}
+
+// diffOp is a line kept (' '), removed ('-') or added ('+') by a diff.
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines computes a shortest edit script from a to b through their
+// longest common subsequence, found with Hirschberg's algorithm: its memory
+// is linear in the lines of b, which matters for files of thousands of
+// lines, e.g. those merged by amalgamate.
+func diffLines(a, b []string) []diffOp {
+	// Lines are compared by number, each distinct line getting its own.
+	ids := make(map[string]int)
+	number := func(lines []string) []int {
+		ns := make([]int, len(lines))
+		for i, l := range lines {
+			n, ok := ids[l]
+			if !ok {
+				n = len(ids)
+				ids[l] = n
+			}
+			ns[i] = n
+		}
+		return ns
+	}
+	d := &differ{a: a, b: b}
+	d.diff(number(a), number(b), 0, 0)
+	return d.ops
+}
+
+// differ accumulates the edit script of diffLines.
+type differ struct {
+	a, b []string
+	ops  []diffOp
+}
+
+// diff appends the edit script from x to y, the numbered lines of a and b
+// starting at lines i and j.
+func (d *differ) diff(x, y []int, i, j int) {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		d.ops = append(d.ops, diffOp{' ', d.a[i+prefix]})
+		prefix++
+	}
+	x, y, i, j = x[prefix:], y[prefix:], i+prefix, j+prefix
+	suffix := 0
+	for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	x, y = x[:len(x)-suffix], y[:len(y)-suffix]
+
+	switch {
+	case len(x) == 0:
+		d.add(j, len(y))
+	case len(y) == 0:
+		d.remove(i, len(x))
+	case len(x) == 1:
+		k := 0
+		for k < len(y) && y[k] != x[0] {
+			k++
+		}
+		if k == len(y) {
+			d.remove(i, 1)
+			d.add(j, len(y))
+			break
+		}
+		d.add(j, k)
+		d.ops = append(d.ops, diffOp{' ', d.a[i]})
+		d.add(j+k+1, len(y)-k-1)
+	default:
+		// Split x in halves and y where the common subsequences of the
+		// halves with the parts of y are longest together.
+		mid := len(x) / 2
+		fwd := lcsLengths(x[:mid], y, false)
+		bwd := lcsLengths(x[mid:], y, true)
+		split := 0
+		for k := range fwd {
+			if fwd[k]+bwd[len(y)-k] > fwd[split]+bwd[len(y)-split] {
+				split = k
+			}
+		}
+		d.diff(x[:mid], y[:split], i, j)
+		d.diff(x[mid:], y[split:], i+mid, j+split)
+	}
+
+	for k := 0; k < suffix; k++ {
+		d.ops = append(d.ops, diffOp{' ', d.a[i+len(x)+k]})
+	}
+}
+
+// remove appends the removal of n lines of a from line i.
+func (d *differ) remove(i, n int) {
+	for _, l := range d.a[i : i+n] {
+		d.ops = append(d.ops, diffOp{'-', l})
+	}
+}
+
+// add appends the addition of n lines of b from line j.
+func (d *differ) add(j, n int) {
+	for _, l := range d.b[j : j+n] {
+		d.ops = append(d.ops, diffOp{'+', l})
+	}
+}
+
+// lcsLengths returns, for every k, the length of the longest common
+// subsequence of x and the first k lines of y, or of x and the last k lines
+// of y when reversed, keeping two rows of the table at a time.
+func lcsLengths(x, y []int, reversed bool) []int {
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for i := range x {
+		xi := x[i]
+		if reversed {
+			xi = x[len(x)-1-i]
+		}
+		for k := 1; k <= len(y); k++ {
+			yk := y[k-1]
+			if reversed {
+				yk = y[len(y)-k]
+			}
+			switch {
+			case xi == yk:
+				cur[k] = prev[k-1] + 1
+			case prev[k] >= cur[k-1]:
+				cur[k] = prev[k]
+			default:
+				cur[k] = cur[k-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// unifiedDiff renders the changes from a to b, both named name, as a
+// unified diff with three lines of context.
+func unifiedDiff(name, a, b string) string {
+	const context = 3
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// aPos[k] and bPos[k] count the lines of a and b preceding ops[k].
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	for start := 0; start < len(ops); {
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for k := first; k < len(ops) && k-last <= 2*context; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		from := first - context
+		if from < start {
+			from = start
+		}
+		to := last + context + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aPos[from], aPos[to]-aPos[from]),
+			hunkRange(bPos[from], bPos[to]-bPos[from]))
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of a hunk starting after line pos.
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
+
+// splitLines splits s into lines, dropping the final newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyDiff returns the lines ops keep or remove, and those they keep or
+// add: the two sides of the diff.
+func applyDiff(ops []diffOp) (a, b []string) {
+	for _, op := range ops {
+		if op.kind != '+' {
+			a = append(a, op.text)
+		}
+		if op.kind != '-' {
+			b = append(b, op.text)
+		}
+	}
+	return a, b
+}
+
+// countChanges returns the number of lines ops remove or add.
+func countChanges(ops []diffOp) int {
+	n := 0
+	for _, op := range ops {
+		if op.kind != ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		changes int
+	}{
+		{name: "equal", a: "a b c", b: "a b c", changes: 0},
+		{name: "empty a", a: "", b: "a b", changes: 2},
+		{name: "empty b", a: "a b", b: "", changes: 2},
+		{name: "insertion", a: "a b d", b: "a b c d", changes: 1},
+		{name: "deletion", a: "a b c d", b: "a c d", changes: 1},
+		{name: "replacement", a: "a b c", b: "a x c", changes: 2},
+		{name: "moved", a: "a b c d e", b: "c d e a b", changes: 4},
+		{name: "interleaved", a: "a b c a b b a", b: "c b a b a c", changes: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			ops := diffLines(a, b)
+			gotA, gotB := applyDiff(ops)
+			if !reflect.DeepEqual(gotA, a) && len(a)+len(gotA) > 0 {
+				t.Errorf("diff removes from %v, want %v", gotA, a)
+			}
+			if !reflect.DeepEqual(gotB, b) && len(b)+len(gotB) > 0 {
+				t.Errorf("diff adds to %v, want %v", gotB, b)
+			}
+			if n := countChanges(ops); n != tt.changes {
+				t.Errorf("diff changes %d lines, want %d", n, tt.changes)
+			}
+		})
+	}
+}
+
+// TestDiffLinesLarge diffs files of the size amalgamate=true produces,
+// whose quadratic table would take gigabytes.
+func TestDiffLinesLarge(t *testing.T) {
+	const n = 20000
+	var a, b []string
+	changes := 0
+	for i := 0; i < n; i++ {
+		line := fmt.Sprintf("line %d", i%500)
+		a = append(a, line)
+		switch {
+		case i%1000 == 0:
+			b = append(b, "changed "+line)
+			changes += 2
+		case i%1500 == 0:
+			changes++
+		default:
+			b = append(b, line)
+		}
+	}
+	ops := diffLines(a, b)
+	gotA, gotB := applyDiff(ops)
+	if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+		t.Fatal("diff does not turn a into b")
+	}
+	if got := countChanges(ops); got > changes {
+		t.Errorf("diff changes %d lines, want at most %d", got, changes)
+	}
+}
@@ -0,0 +1,240 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// baggageField is an input field of (service_gen.baggage_fields).
+type baggageField struct {
+	// Key is the baggage key, the path of the field.
+	Key string
+	// Value is the Go expression of the field of in as a string.
+	Value string
+}
+
+// Baggage returns the fields of the method put into baggage. They must be
+// singular scalar fields, reached through singular message fields, of the
+// input of a method with a single input, and not be sensitive.
+func (m method) Baggage() ([]baggageField, error) {
+	var fields []baggageField
+	for _, path := range m.BaggageFields() {
+		invalid := func(why string) error {
+			return errors.New("invalid baggage_fields option on " + m.GetName() + ": " + why)
+		}
+		if m.GetClientStreaming() {
+			return nil, invalid("client streams have no single input")
+		}
+		msg, ok := m.messages[m.GetInputType()]
+		if !ok {
+			return nil, invalid("unknown message " + m.GetInputType())
+		}
+		getter := "in"
+		var field *descriptor.FieldDescriptorProto
+		for i, name := range strings.Split(path, ".") {
+			if i > 0 {
+				if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+					return nil, invalid(path + " goes through a field that is not a message")
+				}
+				if msg, ok = m.messages[field.GetTypeName()]; !ok {
+					return nil, invalid("unknown message " + field.GetTypeName())
+				}
+			}
+			field = nil
+			for _, f := range msg.GetField() {
+				if f.GetName() == name {
+					field = f
+				}
+			}
+			if field == nil {
+				return nil, invalid(msg.GoName + " has no field " + name)
+			}
+			if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				return nil, invalid(path + " goes through a repeated field")
+			}
+			if sensitive(field) {
+				return nil, invalid(path + " is sensitive, baggage is sent in the clear")
+			}
+			getter += ".Get" + goFieldName(name) + "()"
+		}
+
+		var value string
+		switch field.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			value = getter
+		case descriptor.FieldDescriptorProto_TYPE_BOOL:
+			value = "strconv.FormatBool(" + getter + ")"
+		case descriptor.FieldDescriptorProto_TYPE_ENUM:
+			value = getter + ".String()"
+		case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+			descriptor.FieldDescriptorProto_TYPE_SFIXED32, descriptor.FieldDescriptorProto_TYPE_INT64,
+			descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+			value = "strconv.FormatInt(int64(" + getter + "), 10)"
+		case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+			descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+			value = "strconv.FormatUint(uint64(" + getter + "), 10)"
+		default:
+			return nil, invalid(path + " must be a string, bool, enum or integer field")
+		}
+		fields = append(fields, baggageField{Key: path, Value: value})
+	}
+	return fields, nil
+}
+
+var baggageTmpl = newTemplate("baggage", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"strconv"
+
+	"go.opentelemetry.io/otel/baggage"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	{{.GoImport}}
+)
+
+// {{.LowerName}}BaggageFields return the input fields put into baggage, by
+// baggage key, for each method with (service_gen.baggage_fields), by full
+// method name.
+var {{.LowerName}}BaggageFields = map[string]func(req interface{}) map[string]string{
+	{{- range .Methods }}
+	{{- if .BaggageFields }}
+	"/{{$.FullName}}/{{.WireName}}": func(req interface{}) map[string]string {
+		in, ok := req.(*{{$.GoPrefix}}.{{.InputGoName}})
+		if !ok {
+			return nil
+		}
+		return map[string]string{
+			{{- range .Baggage }}
+			"{{.Key}}": {{.Value}},
+			{{- end }}
+		}
+	},
+	{{- end }}
+	{{- end }}
+}
+
+// With{{.Name}}Baggage returns a copy of ctx whose baggage also holds the
+// input fields of method found in req, empty ones left out.
+func With{{.Name}}Baggage(ctx context.Context, method string, req interface{}) context.Context {
+	fields, ok := {{.LowerName}}BaggageFields[method]
+	if !ok {
+		return ctx
+	}
+	bag := baggage.FromContext(ctx)
+	for key, value := range fields(req) {
+		if value == "" {
+			continue
+		}
+		member, err := baggage.NewMemberRaw(key, value)
+		if err != nil {
+			continue
+		}
+		if b, err := bag.SetMember(member); err == nil {
+			bag = b
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// {{.LowerName}}IncomingBaggage returns a copy of ctx holding the baggage
+// header of the incoming call, merged into the baggage ctx may already have.
+func {{.LowerName}}IncomingBaggage(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	bag := baggage.FromContext(ctx)
+	for _, v := range md.Get("baggage") {
+		parsed, err := baggage.Parse(v)
+		if err != nil {
+			continue
+		}
+		for _, member := range parsed.Members() {
+			if b, err := bag.SetMember(member); err == nil {
+				bag = b
+			}
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// {{.LowerName}}OutgoingBaggage returns ctx with its baggage added to the
+// outgoing metadata, unless already set, e.g. by an OpenTelemetry
+// propagator.
+func {{.LowerName}}OutgoingBaggage(ctx context.Context) context.Context {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return ctx
+	}
+	if out, _ := metadata.FromOutgoingContext(ctx); len(out.Get("baggage")) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "baggage", bag.String())
+}
+
+// {{.Name}}BaggagePropagation carries OpenTelemetry baggage through calls:
+// the server interceptors extract the baggage header of incoming calls and
+// add the input fields of (service_gen.baggage_fields) to it, and the client
+// interceptors send the baggage of the context, plus those fields of unary
+// calls, to downstream services.
+type {{.Name}}BaggagePropagation struct{}
+
+// UnaryInterceptor extracts the baggage of unary calls.
+func ({{.Name}}BaggagePropagation) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = {{.LowerName}}IncomingBaggage(ctx)
+		return handler(With{{.Name}}Baggage(ctx, info.FullMethod, req), req)
+	}
+}
+
+// StreamInterceptor extracts the baggage of stream calls, adding the input
+// fields of server streams once their input is received.
+func ({{.Name}}BaggagePropagation) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &{{.LowerName}}BaggageStream{
+			ServerStream: ss,
+			ctx:          {{.LowerName}}IncomingBaggage(ss.Context()),
+			method:       info.FullMethod,
+		})
+	}
+}
+
+// {{.LowerName}}BaggageStream overrides the context of a stream.
+type {{.LowerName}}BaggageStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	method   string
+	received bool
+}
+
+func (s *{{.LowerName}}BaggageStream) Context() context.Context { return s.ctx }
+
+func (s *{{.LowerName}}BaggageStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil && !s.received {
+		s.received = true
+		s.ctx = With{{.Name}}Baggage(s.ctx, s.method, msg)
+	}
+	return err
+}
+
+// UnaryClientInterceptor sends the baggage of the context on unary calls,
+// with the input fields of the method.
+func ({{.Name}}BaggagePropagation) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = {{.LowerName}}OutgoingBaggage(With{{.Name}}Baggage(ctx, method, req))
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor sends the baggage of the context on stream calls.
+// Their headers leave before the input, whose fields are not added.
+func ({{.Name}}BaggagePropagation) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer({{.LowerName}}OutgoingBaggage(ctx), desc, cc, method, opts...)
+	}
+}
+`)
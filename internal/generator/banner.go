@@ -0,0 +1,92 @@
+package generator
+
+// Middleware returns the names of the interceptors the server bootstrap
+// installs, in the order they run, along with the stats handler.
+func (p Service) Middleware() []string {
+	var names []string
+	if p.GenOps && p.GenMaintenance {
+		names = append(names, "maintenance")
+	}
+	if p.GenBuildInfo {
+		names = append(names, "build_info")
+	}
+	if p.GenEvents {
+		names = append(names, "events")
+	}
+	if p.HasCacheControl() {
+		names = append(names, "cache_control")
+	}
+	if p.GenBaggage {
+		names = append(names, "baggage")
+	}
+	if p.HasSunsets() {
+		names = append(names, "sunset")
+	}
+	if p.GenStreamMetrics {
+		names = append(names, "stream_metrics")
+	}
+	if p.GenStats {
+		names = append(names, "stats")
+	}
+	return names
+}
+
+// QuotedMiddleware returns the Middleware as Go literals separated by
+// commas.
+func (p Service) QuotedMiddleware() string {
+	return quotedList(p.Middleware())
+}
+
+var bannerTmpl = newTemplate("banner", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// {{.Name}}Middleware are the interceptors Run{{.Name}} installs, in the
+// order they run, before those passed as options.
+var {{.Name}}Middleware = []string{ {{- .QuotedMiddleware -}} }
+
+// {{.Name}}Startup is the banner Run{{.Name}} logs once it serves, for fleet
+// tooling to inventory the API versions deployed.
+type {{.Name}}Startup struct {
+	{{.Name}}Info
+	Middleware []string `+"`"+`json:"middleware"`+"`"+`
+	// Addrs are the addresses listened on by listener: grpc, metrics, debug
+	{{- if .GenOps }} and
+	// ops{{ end }}.
+	Addrs map[string]string `+"`"+`json:"addrs"`+"`"+`
+}
+
+// {{.LowerName}}LogStartup logs the startup banner of a server listening on
+// grpcAddr, configured by cfg, as a single line: "startup" followed by the
+// banner in JSON.
+func {{.LowerName}}LogStartup(cfg {{.Name}}Config, grpcAddr string) {
+	s := {{.Name}}Startup{
+		{{.Name}}Info: {{.Name}}BuildInfo(),
+		Middleware:     {{.Name}}Middleware,
+		Addrs:          map[string]string{"grpc": grpcAddr},
+	}
+	if cfg.MetricsAddr != "" {
+		s.Addrs["metrics"] = cfg.MetricsAddr
+	}
+	if cfg.DebugAddr != "" {
+		s.Addrs["debug"] = cfg.DebugAddr
+	}
+	{{- if .GenOps }}
+	if cfg.OpsAddr != "" {
+		s.Addrs["ops"] = cfg.OpsAddr
+	}
+	{{- end }}
+	b, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("{{.Name}}: unable to log the startup banner: %v", err)
+		return
+	}
+	log.Printf("startup %s", b)
+}
+`)
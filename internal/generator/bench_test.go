@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+var benchParam = flag.String("bench.param", "", "plugin parameter string of BenchmarkGenerate")
+
+// BenchmarkGenerate measures the generation of synthetic requests of 10 and
+// 100 services, to quantify the cost of template and pipeline changes with
+// benchstat:
+//
+//	go test -run '^$' -bench Generate -benchmem -count 10 -bench.param gen_client=true
+func BenchmarkGenerate(b *testing.B) {
+	for _, n := range []int{10, 100} {
+		req := syntheticRequest(n, 4, *benchParam)
+		b.Run(fmt.Sprintf("services=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ps, err := Parse(req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := Generate(ps); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// syntheticRequest returns a request for one file holding n services of m
+// methods each, cycling through the streaming shapes.
+func syntheticRequest(n, m int, param string) *plugin.CodeGeneratorRequest {
+	f := &descriptor.FileDescriptorProto{
+		Name:   proto.String("bench.proto"),
+		Syntax: proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("Request")},
+			{Name: proto.String("Response")},
+		},
+	}
+	for i := 0; i < n; i++ {
+		svc := &descriptor.ServiceDescriptorProto{Name: proto.String(fmt.Sprintf("Service%d", i))}
+		for j := 0; j < m; j++ {
+			svc.Method = append(svc.Method, &descriptor.MethodDescriptorProto{
+				Name:            proto.String(fmt.Sprintf("Method%d", j)),
+				InputType:       proto.String(".Request"),
+				OutputType:      proto.String(".Response"),
+				ClientStreaming: proto.Bool(j%4 == 1 || j%4 == 3),
+				ServerStreaming: proto.Bool(j%4 == 2 || j%4 == 3),
+			})
+		}
+		f.Service = append(f.Service, svc)
+	}
+	return &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{f.GetName()},
+		Parameter:      proto.String(param),
+		ProtoFile:      []*descriptor.FileDescriptorProto{f},
+	}
+}
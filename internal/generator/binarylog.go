@@ -0,0 +1,147 @@
+package generator
+
+var binaryLogTmpl = newTemplate("binarylog", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+)
+
+// {{.Name}}BinaryLogSink writes gRPC binary log entries to a file, rotating
+// it once it reaches MaxBytes. Entries are written as by grpc's own sinks:
+// each is prefixed with its length as a big-endian uint32. Only the calls to
+// Methods are logged.
+//
+// gRPC only produces entries when the GRPC_BINARY_LOG_FILTER environment
+// variable is set when the process starts, e.g. to "*": the sink narrows the
+// calls down further.
+type {{.Name}}BinaryLogSink struct {
+	// Path is the file written to. Rotated files get the suffixes .1, .2...
+	Path string
+	// MaxBytes is the size the file is rotated at, 64 MiB when 0.
+	MaxBytes int64
+	// MaxFiles is the number of rotated files kept, 5 when 0.
+	MaxFiles int
+	// Methods filter the calls logged by full method name, e.g.
+	// "/{{.FullName}}/Get", or by service, e.g. "/{{.FullName}}/*". Every
+	// call is logged when empty.
+	Methods []string
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	logged map[uint64]bool
+}
+
+// Write writes e, unless its call is filtered out.
+func (s *{{.Name}}BinaryLogSink) Write(e *binlogpb.GrpcLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logged == nil {
+		s.logged = make(map[uint64]bool)
+	}
+	if h := e.GetClientHeader(); h != nil {
+		s.logged[e.GetCallId()] = s.match(h.GetMethodName())
+	}
+	logged := s.logged[e.GetCallId()]
+	switch e.GetType() {
+	case binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER, binlogpb.GrpcLogEntry_EVENT_TYPE_CANCEL:
+		delete(s.logged, e.GetCallId())
+	}
+	if !logged {
+		return nil
+	}
+
+	b, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := s.rotate(int64(4 + len(b))); err != nil {
+		return err
+	}
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(b)))
+	n, err := s.f.Write(append(prefix[:], b...))
+	s.size += int64(n)
+	return err
+}
+
+// match reports whether calls to method are logged.
+func (s *{{.Name}}BinaryLogSink) match(method string) bool {
+	if len(s.Methods) == 0 {
+		return true
+	}
+	for _, m := range s.Methods {
+		if m == "*" || m == method {
+			return true
+		}
+		if strings.HasSuffix(m, "/*") && strings.HasPrefix(method, strings.TrimSuffix(m, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// rotate opens the file, rotating it first when writing n more bytes would
+// take it past MaxBytes.
+func (s *{{.Name}}BinaryLogSink) rotate(n int64) error {
+	max := s.MaxBytes
+	if max == 0 {
+		max = 64 << 20
+	}
+	if s.f != nil && s.size+n <= max {
+		return nil
+	}
+	if s.f != nil {
+		if err := s.f.Close(); err != nil {
+			return err
+		}
+		s.f = nil
+		keep := s.MaxFiles
+		if keep == 0 {
+			keep = 5
+		}
+		os.Remove(fmt.Sprintf("%s.%d", s.Path, keep))
+		for i := keep - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1))
+		}
+		if err := os.Rename(s.Path, s.Path+".1"); err != nil {
+			return fmt.Errorf("unable to rotate %s: %v", s.Path, err)
+		}
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", s.Path, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f, s.size = f, fi.Size()
+	return nil
+}
+
+// Close closes the file.
+func (s *{{.Name}}BinaryLogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+`)
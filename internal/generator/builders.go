@@ -0,0 +1,218 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// builder is a constructor with functional options for a response type.
+type builder struct {
+	// GoName is the Go type name of the message.
+	GoName string
+	Fields []builderField
+}
+
+// builderField is a top-level field a builder option sets.
+type builderField struct {
+	// Name is the Go name of the field.
+	Name string
+	// Type is the Go type of the option's argument v.
+	Type string
+	// Assign is the statement setting the field of a message m to v.
+	Assign string
+}
+
+// Builders returns the builders emitted in the service's file.
+func (p Service) Builders() []builder {
+	return p.builders
+}
+
+// assignBuilders gives every service with GenBuilders a builder for each of
+// its output messages, unless a service generated earlier into the same Go
+// package already has one.
+func assignBuilders(ps []*Service) {
+	claimed := make(map[string]bool)
+	for _, p := range ps {
+		if !p.GenBuilders {
+			continue
+		}
+		for _, m := range p.Methods {
+			msg, ok := p.messages[m.GetOutputType()]
+			if !ok || msg.Package != p.PackageName {
+				continue
+			}
+			key := p.outputDir() + "\x00" + p.GoPackageName + "\x00" + m.GetOutputType()
+			if claimed[key] {
+				continue
+			}
+			claimed[key] = true
+			p.builders = append(p.builders, p.builderFor(msg))
+		}
+	}
+}
+
+// builderFor returns the builder of msg. Fields whose types live outside
+// the service's proto package are left out, their Go import being unknown.
+func (p Service) builderFor(msg *message) builder {
+	b := builder{GoName: msg.GoName}
+	for _, f := range msg.GetField() {
+		typ, ok := p.fieldType(f)
+		if !ok {
+			continue
+		}
+		name := goFieldName(f.GetName())
+		assign := fmt.Sprintf("m.%s = v", name)
+		switch {
+		case f.OneofIndex != nil:
+			oneof := goFieldName(msg.GetOneofDecl()[f.GetOneofIndex()].GetName())
+			assign = fmt.Sprintf("m.%s = &%s.%s_%s{%s: v}", oneof, p.GoPrefix, msg.GoName, name, name)
+		case msg.Syntax != "proto3" &&
+			f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED &&
+			f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+			f.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES:
+			assign = fmt.Sprintf("m.%s = &v", name)
+		}
+		b.Fields = append(b.Fields, builderField{Name: name, Type: typ, Assign: assign})
+	}
+	return b
+}
+
+// fieldType returns the Go type of f, and false when it cannot be named.
+func (p Service) fieldType(f *descriptor.FieldDescriptorProto) (string, bool) {
+	if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		if entry, ok := p.messages[f.GetTypeName()]; ok && entry.GetOptions().GetMapEntry() {
+			var key, value string
+			for _, ef := range entry.GetField() {
+				t, ok := p.fieldType(ef)
+				if !ok {
+					return "", false
+				}
+				if ef.GetName() == "key" {
+					key = t
+				} else {
+					value = t
+				}
+			}
+			return "map[" + key + "]" + value, true
+		}
+	}
+	elem, ok := p.elemType(f)
+	if !ok {
+		return "", false
+	}
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "[]" + elem, true
+	}
+	return elem, true
+}
+
+// elemType returns the Go type of a single value of f.
+func (p Service) elemType(f *descriptor.FieldDescriptorProto) (string, bool) {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64", true
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32", true
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64", true
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64", true
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32", true
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32", true
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool", true
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string", true
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte", true
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		name, ok := p.enumGoName(f.GetTypeName())
+		return p.GoPrefix + "." + name, ok
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		msg, ok := p.messages[f.GetTypeName()]
+		if !ok || msg.Package != p.PackageName {
+			return "", false
+		}
+		return "*" + p.GoPrefix + "." + msg.GoName, true
+	}
+	return "", false
+}
+
+// enumGoName returns the Go name of an enum of the service's proto package.
+func (p Service) enumGoName(typeName string) (string, bool) {
+	prefix := "."
+	if p.PackageName != "" {
+		prefix = "." + p.PackageName + "."
+	}
+	if !strings.HasPrefix(typeName, prefix) {
+		return "", false
+	}
+	i := strings.LastIndex(typeName, ".")
+	name := camelCase(typeName[i+1:])
+	if i+1 == len(prefix) {
+		return name, true
+	}
+	parent, ok := p.messages[typeName[:i]]
+	if !ok || parent.Package != p.PackageName {
+		return "", false
+	}
+	return parent.GoName + "_" + name, true
+}
+
+// goFieldName returns the Go name protoc-gen-go gives the field, which is
+// suffixed with an underscore when it clashes with a generated method.
+func goFieldName(name string) string {
+	n := camelCase(name)
+	switch n {
+	case "Reset", "String", "ProtoMessage", "Marshal", "Unmarshal", "ExtensionRangeArray", "ExtensionMap", "Descriptor":
+		n += "_"
+	}
+	return n
+}
+
+var buildersTmpl = newTemplate("builders", `
+{{template "header" .}}
+`+buildersBody)
+
+// buildersBody declares the builders of a service, after the header of the
+// file.
+var buildersBody = `
+package {{.GoPackageName}}
+{{ if .Builders }}
+import (
+	{{.GoImport}}
+)
+{{ end }}
+{{- range .Builders }}
+{{- $msg := . }}
+// {{.GoName}}Option sets a field of the {{.GoName}} built by New{{.GoName}}.
+type {{.GoName}}Option func(*{{$.GoPrefix}}.{{.GoName}})
+
+// New{{.GoName}} builds a {{.GoName}}, applying opts in order.
+func New{{.GoName}}(opts ...{{.GoName}}Option) *{{$.GoPrefix}}.{{.GoName}} {
+	m := &{{$.GoPrefix}}.{{.GoName}}{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+{{ range .Fields }}
+// With{{$msg.GoName}}{{.Name}} sets {{.Name}}.
+func With{{$msg.GoName}}{{.Name}}(v {{.Type}}) {{$msg.GoName}}Option {
+	return func(m *{{$.GoPrefix}}.{{$msg.GoName}}) {
+		{{.Assign}}
+	}
+}
+{{ end }}
+{{- end }}
+`
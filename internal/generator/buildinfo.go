@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// DescriptorHash returns the SHA-256 of the proto file of the service,
+// without its comments, so that it only changes with the contract.
+func (p Service) DescriptorHash() (string, error) {
+	f := proto.Clone(p.file).(*descriptor.FileDescriptorProto)
+	f.SourceCodeInfo = nil
+	b, err := proto.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+var buildInfoTmpl = newTemplate("buildinfo", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// The build of the binary, set at link time with e.g.
+//
+//	go build -ldflags "-X <package>.{{.Name}}BuildVersion=v1.2.3 -X <package>.{{.Name}}BuildCommit=$(git rev-parse HEAD)"
+var (
+	{{.Name}}BuildVersion = "dev"
+	{{.Name}}BuildCommit  = "unknown"
+)
+
+// {{.Name}}DescriptorHash is the SHA-256 of {{.ProtoName}}, without its
+// comments, the service was generated from. It identifies the revision of
+// the contract served.
+const {{.Name}}DescriptorHash = "{{.DescriptorHash}}"
+
+// {{.Name}}Info describes the binary serving {{.Name}}.
+type {{.Name}}Info struct {
+	Service        string `+"`"+`json:"service"`+"`"+`
+	Version        string `+"`"+`json:"version"`+"`"+`
+	Commit         string `+"`"+`json:"commit"`+"`"+`
+	DescriptorHash string `+"`"+`json:"descriptor_hash"`+"`"+`
+}
+
+// {{.Name}}BuildInfo returns the build and contract revision of the binary.
+func {{.Name}}BuildInfo() {{.Name}}Info {
+	return {{.Name}}Info{
+		Service:        "{{.FullName}}",
+		Version:        {{.Name}}BuildVersion,
+		Commit:         {{.Name}}BuildCommit,
+		DescriptorHash: {{.Name}}DescriptorHash,
+	}
+}
+
+// {{.LowerName}}InfoHeader returns the build info as response metadata.
+func {{.LowerName}}InfoHeader() metadata.MD {
+	return metadata.Pairs(
+		"x-build-version", {{.Name}}BuildVersion,
+		"x-build-commit", {{.Name}}BuildCommit,
+		"x-descriptor-hash", {{.Name}}DescriptorHash,
+	)
+}
+
+// {{.LowerName}}IsHealth reports whether method belongs to the health service.
+func {{.LowerName}}IsHealth(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.Health/")
+}
+
+// {{.Name}}InfoUnaryInterceptor adds the build info to the header metadata
+// of health checks, so that probes can tell which revision is serving.
+func {{.Name}}InfoUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if {{.LowerName}}IsHealth(info.FullMethod) {
+			grpc.SetHeader(ctx, {{.LowerName}}InfoHeader())
+		}
+		return handler(ctx, req)
+	}
+}
+
+// {{.Name}}InfoStreamInterceptor adds the build info to the header metadata
+// of health watches.
+func {{.Name}}InfoStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if {{.LowerName}}IsHealth(info.FullMethod) {
+			ss.SetHeader({{.LowerName}}InfoHeader())
+		}
+		return handler(srv, ss)
+	}
+}
+
+// {{.Name}}InfoHandler serves the build info as JSON.
+func {{.Name}}InfoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode({{.Name}}BuildInfo())
+	})
+}
+`)
@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// HasCacheControl reports whether any method of the service has a
+// Cache-Control directive.
+func (p Service) HasCacheControl() bool {
+	for _, m := range p.Methods {
+		if m.CacheControl() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheControlLiteral returns the Go literal of the Cache-Control directive
+// of the method, which only unary methods may have.
+func (m method) CacheControlLiteral() (string, error) {
+	cc := m.CacheControl()
+	if m.GetClientStreaming() || m.GetServerStreaming() {
+		return "", errors.New("invalid cache_control option on " + m.GetName() + ": only unary responses are cacheable")
+	}
+	if strings.ContainsAny(cc, "\r\n") {
+		return "", errors.New("invalid cache_control option on " + m.GetName() + ": line breaks are not allowed")
+	}
+	return strconv.Quote(cc), nil
+}
+
+// cacheTmpl declares the caching interceptor in the service file of
+// services with methods annotated with (service_gen.cache_control).
+var cacheTmpl = `
+{{- define "cache_interceptor" }}
+// {{.Name}}CacheControl are the Cache-Control directives of the responses of
+// cacheable methods, by full method name.
+var {{.Name}}CacheControl = map[string]string{
+	{{- range .Methods }}
+	{{- if .CacheControl }}
+	"/{{$.FullName}}/{{.WireName}}": {{.CacheControlLiteral}},
+	{{- end }}
+	{{- end }}
+}
+
+// {{.Name}}CacheUnaryInterceptor sends the Cache-Control directive of
+// successful calls to cacheable methods as cache-control header metadata.
+// Failed calls are left without, so that errors are not cached.
+func {{.Name}}CacheUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if cc, ok := {{.Name}}CacheControl[info.FullMethod]; ok && err == nil {
+			grpc.SetHeader(ctx, metadata.Pairs("cache-control", cc))
+		}
+		return resp, err
+	}
+}
+
+// {{.Name}}GatewayHeaderMatcher maps the header metadata of responses to
+// HTTP headers for grpc-gateway, e.g. with
+//
+//	runtime.NewServeMux(runtime.WithOutgoingHeaderMatcher({{.Name}}GatewayHeaderMatcher))
+//
+// cache-control becomes Cache-Control, for CDNs to honor, while other keys
+// keep the Grpc-Metadata- prefix the gateway adds by default.
+func {{.Name}}GatewayHeaderMatcher(key string) (string, bool) {
+	if key == "cache-control" {
+		return "Cache-Control", true
+	}
+	return "Grpc-Metadata-" + key, true
+}
+{{- end }}
+`
@@ -0,0 +1,184 @@
+package generator
+
+var canaryTmpl = newTemplate("canary", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	{{.GoImport}}
+)
+
+// {{.Name}}CanaryConfig controls the share of calls sent to the canary.
+type {{.Name}}CanaryConfig struct {
+	// Percent is the share of calls sent to the canary, from 0 to 100.
+	Percent float64
+	// Methods overrides Percent for the named methods.
+	Methods map[string]float64
+	// OnResult, if set, is called once every call, or stream creation, has
+	// completed.
+	OnResult func(method string, canary bool, err error, latency time.Duration)
+}
+
+// {{.Name}}CanaryTargetStats counts the calls routed to one backend.
+type {{.Name}}CanaryTargetStats struct {
+	Calls   uint64
+	Errors  uint64
+	Latency time.Duration
+}
+
+// ErrorRate returns the share of calls that failed.
+func (s {{.Name}}CanaryTargetStats) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// MeanLatency returns the mean latency of the calls.
+func (s {{.Name}}CanaryTargetStats) MeanLatency() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Calls)
+}
+
+// {{.Name}}CanaryStats compares the stable and canary backends.
+type {{.Name}}CanaryStats struct {
+	Stable {{.Name}}CanaryTargetStats
+	Canary {{.Name}}CanaryTargetStats
+}
+
+// Divergence returns how much more often the canary fails than the stable
+// backend; a negative value means it fails less often.
+func (s {{.Name}}CanaryStats) Divergence() float64 {
+	return s.Canary.ErrorRate() - s.Stable.ErrorRate()
+}
+
+// {{.Name}}Canary is a {{.Name}} client routing each call to either the
+// stable or the canary backend. Streams stay on the backend they were opened
+// with. It is safe for concurrent use.
+type {{.Name}}Canary struct {
+	stable, canary {{.GoPrefix}}.{{.Name}}Client
+	cfg            {{.Name}}CanaryConfig
+
+	mu    sync.Mutex
+	stats map[string]*{{.Name}}CanaryStats
+}
+
+var _ {{.GoPrefix}}.{{.Name}}Client = (*{{.Name}}Canary)(nil)
+
+// New{{.Name}}Canary returns a client splitting calls between stable and
+// canary as configured by cfg.
+func New{{.Name}}Canary(stable, canary {{.GoPrefix}}.{{.Name}}Client, cfg {{.Name}}CanaryConfig) *{{.Name}}Canary {
+	return &{{.Name}}Canary{
+		stable: stable,
+		canary: canary,
+		cfg:    cfg,
+		stats:  make(map[string]*{{.Name}}CanaryStats),
+	}
+}
+
+// Stats returns the counts of calls, summed over every method.
+func (c *{{.Name}}Canary) Stats() {{.Name}}CanaryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total {{.Name}}CanaryStats
+	for _, s := range c.stats {
+		total.Stable.Calls += s.Stable.Calls
+		total.Stable.Errors += s.Stable.Errors
+		total.Stable.Latency += s.Stable.Latency
+		total.Canary.Calls += s.Canary.Calls
+		total.Canary.Errors += s.Canary.Errors
+		total.Canary.Latency += s.Canary.Latency
+	}
+	return total
+}
+
+// MethodStats returns the counts of calls to a single method.
+func (c *{{.Name}}Canary) MethodStats(method string) {{.Name}}CanaryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.stats[method]; ok {
+		return *s
+	}
+	return {{.Name}}CanaryStats{}
+}
+
+// pick returns the client to call method with and whether it is the canary.
+func (c *{{.Name}}Canary) pick(method string) ({{.GoPrefix}}.{{.Name}}Client, bool) {
+	percent := c.cfg.Percent
+	if p, ok := c.cfg.Methods[method]; ok {
+		percent = p
+	}
+	if percent > 0 && rand.Float64()*100 < percent {
+		return c.canary, true
+	}
+	return c.stable, false
+}
+
+// record accounts for a call to method started at start.
+func (c *{{.Name}}Canary) record(method string, canary bool, err error, start time.Time) {
+	latency := time.Since(start)
+
+	c.mu.Lock()
+	s, ok := c.stats[method]
+	if !ok {
+		s = &{{.Name}}CanaryStats{}
+		c.stats[method] = s
+	}
+	t := &s.Stable
+	if canary {
+		t = &s.Canary
+	}
+	t.Calls++
+	t.Latency += latency
+	if err != nil {
+		t.Errors++
+	}
+	c.mu.Unlock()
+
+	if c.cfg.OnResult != nil {
+		c.cfg.OnResult(method, canary, err, latency)
+	}
+}
+{{ range .Methods }}
+	{{- if .GetClientStreaming }}
+
+// {{.Name}} opens the stream on the backend picked for it.
+func (c *{{$.Name}}Canary) {{.Name}}(ctx context.Context, opts ...grpc.CallOption) ({{$.GoPrefix}}.{{.ClientStreamName}}, error) {
+	client, canary := c.pick("{{.Name}}")
+	start := time.Now()
+	stream, err := client.{{.Name}}(ctx, opts...)
+	c.record("{{.Name}}", canary, err, start)
+	return stream, err
+}
+	{{- else if .GetServerStreaming }}
+
+// {{.Name}} opens the stream on the backend picked for it.
+func (c *{{$.Name}}Canary) {{.Name}}(ctx context.Context, in *{{$.GoPrefix}}.{{.InputGoName}}, opts ...grpc.CallOption) ({{$.GoPrefix}}.{{.ClientStreamName}}, error) {
+	client, canary := c.pick("{{.Name}}")
+	start := time.Now()
+	stream, err := client.{{.Name}}(ctx, in, opts...)
+	c.record("{{.Name}}", canary, err, start)
+	return stream, err
+}
+	{{- else }}
+
+// {{.Name}} calls the backend picked for it.
+func (c *{{$.Name}}Canary) {{.Name}}(ctx context.Context, in *{{$.GoPrefix}}.{{.InputGoName}}, opts ...grpc.CallOption) (*{{$.GoPrefix}}.{{.OutputGoName}}, error) {
+	client, canary := c.pick("{{.Name}}")
+	start := time.Now()
+	out, err := client.{{.Name}}(ctx, in, opts...)
+	c.record("{{.Name}}", canary, err, start)
+	return out, err
+}
+	{{- end }}
+{{- end }}
+`)
@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// catalogMessage is a message of the catalog generated with gen_catalog.
+type catalogMessage struct {
+	Key string
+	// Format is the English message, formatted with the method name first.
+	Format string
+}
+
+// catalogMessages are the messages of the errors generated code fails
+// calls with.
+var catalogMessages = []catalogMessage{
+	{Key: "not_implemented", Format: "%s is not implemented"},
+	{Key: "no_handler", Format: "%s has no handler"},
+	{Key: "not_enabled", Format: "%s is not enabled"},
+	{Key: "input_too_large", Format: "%s input of %d bytes exceeds the limit of %d bytes"},
+	{Key: "inputs_too_large", Format: "%s inputs of %d bytes exceed the limit of %d bytes"},
+	{Key: "no_time_left", Format: "no time left for downstream calls"},
+}
+
+// Const returns the name of the constant of the message key.
+func (c catalogMessage) Const() string {
+	return camelCase(c.Key)
+}
+
+// Quoted returns the Go literal of the message format.
+func (c catalogMessage) Quoted() string {
+	return strconv.Quote(c.Format)
+}
+
+// CatalogMessages returns the messages of the catalog.
+func (p Service) CatalogMessages() []catalogMessage {
+	return catalogMessages
+}
+
+// Status returns the Go expression of the error failing a call with code
+// and the catalog message of key, formatted with the method name and args,
+// Go expressions, when gen_catalog is set, and fallback otherwise.
+func (m method) Status(code, key, fallback string, args ...string) string {
+	if !m.service.GenCatalog {
+		return fallback
+	}
+	name := m.service.GetName()
+	args = append([]string{strconv.Quote(m.GetName())}, args...)
+	return name + "Errorf(" + m.Ctx() + ", codes." + code + ", " + name + "Msg" + camelCase(key) + ", " + strings.Join(args, ", ") + ")"
+}
+
+var catalogTmpl = newTemplate("catalog", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}MessageKey identifies a user-facing message of {{.Name}}.
+type {{.Name}}MessageKey string
+
+// The messages of the errors the generated code fails calls with.
+const (
+	{{- range .CatalogMessages }}
+	{{$.Name}}Msg{{.Const}} {{$.Name}}MessageKey = "{{.Key}}"
+	{{- end }}
+)
+
+// {{.Name}}DefaultLocale is the locale of status messages, and of the
+// localized messages of calls asking for no locale of the catalog.
+var {{.Name}}DefaultLocale = "en"
+
+// {{.Name}}Catalog holds the messages of each locale as fmt formats, most
+// taking the method name as first argument. Translations reordering the
+// arguments use explicit indexes, e.g. %[2]d. Messages missing from a locale
+// fall back to {{.Name}}DefaultLocale.
+var {{.Name}}Catalog = map[string]map[{{.Name}}MessageKey]string{
+	"en": {
+		{{- range .CatalogMessages }}
+		{{$.Name}}Msg{{.Const}}: {{.Quoted}},
+		{{- end }}
+	},
+}
+
+// {{.Name}}Locale returns the locale of the call: the first language of its
+// accept-language metadata, or of the one grpc-gateway forwards, with
+// messages in {{.Name}}Catalog, or else {{.Name}}DefaultLocale. Regional
+// variants fall back to their language, e.g. "fr-CA" to "fr".
+func {{.Name}}Locale(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{"accept-language", "grpcgateway-accept-language"} {
+		for _, v := range md.Get(key) {
+			for _, tag := range strings.Split(v, ",") {
+				// Drop the quality, e.g. ";q=0.8": languages are taken in order.
+				tag = strings.TrimSpace(strings.SplitN(tag, ";", 2)[0])
+				for tag != "" {
+					if _, ok := {{.Name}}Catalog[tag]; ok {
+						return tag
+					}
+					i := strings.LastIndex(tag, "-")
+					if i < 0 {
+						break
+					}
+					tag = tag[:i]
+				}
+			}
+		}
+	}
+	return {{.Name}}DefaultLocale
+}
+
+// {{.Name}}Message formats the message of key in locale.
+func {{.Name}}Message(locale string, key {{.Name}}MessageKey, args ...interface{}) string {
+	format, ok := {{.Name}}Catalog[locale][key]
+	if !ok {
+		format, ok = {{.Name}}Catalog[{{.Name}}DefaultLocale][key]
+	}
+	if !ok {
+		format = string(key)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// {{.Name}}Errorf returns an error with code and the message of key in
+// {{.Name}}DefaultLocale, for logs and developers, carrying a
+// LocalizedMessage detail in the locale of the call for clients to show
+// users.
+func {{.Name}}Errorf(ctx context.Context, code codes.Code, key {{.Name}}MessageKey, args ...interface{}) error {
+	st := status.New(code, {{.Name}}Message({{.Name}}DefaultLocale, key, args...))
+	locale := {{.Name}}Locale(ctx)
+	if detailed, err := st.WithDetails(&errdetails.LocalizedMessage{
+		Locale:  locale,
+		Message: {{.Name}}Message(locale, key, args...),
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+`)
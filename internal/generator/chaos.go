@@ -0,0 +1,189 @@
+package generator
+
+var chaosTmpl = newTemplate("chaos", `// +build chaos
+
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}ChaosEnv names the environment variable holding the faults to
+// inject into {{.Name}}: a JSON object keyed by method name, where "*"
+// applies to every method without a rule of its own, e.g.
+//
+//	{"*": {"latency": "50ms"}, "Get": {"error_rate": 0.1, "error_code": "UNAVAILABLE"}}
+const {{.Name}}ChaosEnv = "{{.EnvPrefix}}_CHAOS"
+
+// {{.Name}}ChaosRule describes the faults injected into one method.
+type {{.Name}}ChaosRule struct {
+	// Latency is added before the handler runs, e.g. "200ms".
+	Latency string `+"`"+`json:"latency"`+"`"+`
+	// ErrorRate is the probability of failing a call with ErrorCode.
+	ErrorRate float64 `+"`"+`json:"error_rate"`+"`"+`
+	// ErrorCode is the status code of injected errors, Unavailable by default.
+	ErrorCode codes.Code `+"`"+`json:"error_code"`+"`"+`
+	// ResetRate is the probability of aborting a stream on each message.
+	ResetRate float64 `+"`"+`json:"reset_rate"`+"`"+`
+
+	latency time.Duration
+}
+
+// {{.Name}}Chaos injects faults into {{.Name}} calls.
+type {{.Name}}Chaos struct {
+	rules map[string]{{.Name}}ChaosRule
+}
+
+// {{.Name}}ChaosInterceptors returns interceptors injecting the faults
+// configured in {{.Name}}ChaosEnv.
+func {{.Name}}ChaosInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor, error) {
+	rules := make(map[string]{{.Name}}ChaosRule)
+	if v := os.Getenv({{.Name}}ChaosEnv); v != "" {
+		if err := json.Unmarshal([]byte(v), &rules); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %v", {{.Name}}ChaosEnv, err)
+		}
+	}
+	c, err := New{{.Name}}Chaos(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.UnaryInterceptor(), c.StreamInterceptor(), nil
+}
+
+// New{{.Name}}Chaos returns a fault injector for the rules keyed by method
+// name, "*" being the fallback rule.
+func New{{.Name}}Chaos(rules map[string]{{.Name}}ChaosRule) (*{{.Name}}Chaos, error) {
+	c := &{{.Name}}Chaos{rules: make(map[string]{{.Name}}ChaosRule, len(rules))}
+	for name, r := range rules {
+		if r.Latency != "" {
+			d, err := time.ParseDuration(r.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("invalid latency for %s: %v", name, err)
+			}
+			r.latency = d
+		}
+		if r.ErrorCode == codes.OK {
+			r.ErrorCode = codes.Unavailable
+		}
+		c.rules[name] = r
+	}
+	return c, nil
+}
+
+// rule returns the rule applying to the full method name, if any.
+func (c *{{.Name}}Chaos) rule(fullMethod string) ({{.Name}}ChaosRule, bool) {
+	if !strings.HasPrefix(fullMethod, "/{{.FullName}}/") {
+		return {{.Name}}ChaosRule{}, false
+	}
+	r, ok := c.rules[fullMethod[strings.LastIndex(fullMethod, "/")+1:]]
+	if !ok {
+		r, ok = c.rules["*"]
+	}
+	return r, ok
+}
+
+// inject applies the latency and error faults of r.
+func (r {{.Name}}ChaosRule) inject(ctx context.Context) error {
+	if r.latency > 0 {
+		select {
+		case <-time.After(r.latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if r.ErrorRate > 0 && rand.Float64() < r.ErrorRate {
+		return status.Error(r.ErrorCode, "chaos: injected error")
+	}
+	return nil
+}
+
+// UnaryInterceptor injects faults into unary calls.
+func (c *{{.Name}}Chaos) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if r, ok := c.rule(info.FullMethod); ok {
+			if err := r.inject(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor injects faults into streams, including resets while
+// messages are flowing.
+func (c *{{.Name}}Chaos) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		r, ok := c.rule(info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+		if err := r.inject(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, &{{.LowerName}}ChaosStream{ServerStream: ss, resetRate: r.ResetRate})
+	}
+}
+
+// {{.LowerName}}ChaosStream aborts the stream at random.
+type {{.LowerName}}ChaosStream struct {
+	grpc.ServerStream
+	resetRate float64
+}
+
+func (s *{{.LowerName}}ChaosStream) reset() error {
+	if s.resetRate > 0 && rand.Float64() < s.resetRate {
+		return status.Error(codes.Aborted, "chaos: stream reset")
+	}
+	return nil
+}
+
+func (s *{{.LowerName}}ChaosStream) SendMsg(m interface{}) error {
+	if err := s.reset(); err != nil {
+		return err
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *{{.LowerName}}ChaosStream) RecvMsg(m interface{}) error {
+	if err := s.reset(); err != nil {
+		return err
+	}
+	return s.ServerStream.RecvMsg(m)
+}
+`)
+
+var chaosOffTmpl = newTemplate("chaosoff", `// +build !chaos
+
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// {{.Name}}ChaosInterceptors returns pass-through interceptors. Build with
+// -tags chaos to inject the faults configured in the environment.
+func {{.Name}}ChaosInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor, error) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, ss)
+	}
+	return unary, stream, nil
+}
+`)
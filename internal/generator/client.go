@@ -0,0 +1,374 @@
+package generator
+
+var clientTmpl = newTemplate("client", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	{{.GoImport}}
+)
+
+// {{.Name}}DialConfig describes how to reach {{.Name}} backends.
+type {{.Name}}DialConfig struct {
+	// Target is the backend address, e.g. "orders.internal:8443", or a unix
+	// socket such as "unix:///run/orders.sock".
+	Target string
+	// Scheme is the resolver used for Target: "dns", "xds" or "passthrough"
+	// (the default). It is ignored when Target already carries a scheme.
+	Scheme string
+	// ServiceConfig overrides the default service config JSON.
+	ServiceConfig string
+	// TLS configures transport security. A nil TLS uses the system roots.
+	TLS *tls.Config
+	// Insecure disables transport security altogether.
+	Insecure bool
+	// Keepalive, when set, pings idle connections so that dead ones are
+	// noticed. Servers reject pings more frequent than their policy allows.
+	Keepalive *keepalive.ClientParameters
+	// UnaryInterceptors and StreamInterceptors are chained, in order, onto
+	// every call made through the connection.
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
+	// DialOptions are applied after the options derived from the fields above.
+	DialOptions []grpc.DialOption
+}
+
+{{ if .GenServiceConfig }}
+// default{{.Name}}ServiceConfig is derived from the method options.
+const default{{.Name}}ServiceConfig = {{.Name}}ServiceConfig
+{{ else }}
+// default{{.Name}}ServiceConfig balances calls across every resolved address.
+const default{{.Name}}ServiceConfig = `+"`"+`{"loadBalancingConfig": [{"round_robin": {}}]}`+"`"+`
+{{ end }}
+
+// Dial{{.Name}} dials {{.Name}} as described by cfg. Every
+// consumer should dial through here so that resolution, load balancing,
+// security and interceptors stay consistent.
+{{- if .GenBaggage }} The baggage of the context
+// of calls is sent along, as the configured interceptors leave it.
+{{- end }}
+func Dial{{.Name}}(ctx context.Context, cfg {{.Name}}DialConfig) (*grpc.ClientConn, error) {
+	target, err := dial{{.Name}}Target(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceConfig := cfg.ServiceConfig
+	if serviceConfig == "" {
+		serviceConfig = default{{.Name}}ServiceConfig
+	}
+	opts := []grpc.DialOption{grpc.WithDefaultServiceConfig(serviceConfig)}
+
+	if cfg.Insecure {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		tlsConfig := cfg.TLS
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if cfg.Keepalive != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*cfg.Keepalive))
+	}
+	if len(cfg.UnaryInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(cfg.UnaryInterceptors...))
+	}
+	if len(cfg.StreamInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainStreamInterceptor(cfg.StreamInterceptors...))
+	}
+	{{- if .GenBaggage }}
+	opts = append(opts,
+		grpc.WithChainUnaryInterceptor({{.Name}}BaggagePropagation{}.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor({{.Name}}BaggagePropagation{}.StreamClientInterceptor()),
+	)
+	{{- end }}
+	opts = append(opts, cfg.DialOptions...)
+
+	return grpc.DialContext(ctx, target, opts...)
+}
+
+// dial{{.Name}}Target applies the configured resolver scheme to the target.
+func dial{{.Name}}Target(cfg {{.Name}}DialConfig) (string, error) {
+	if cfg.Target == "" {
+		return "", fmt.Errorf("{{.Name}}: empty dial target")
+	}
+	if strings.Contains(cfg.Target, ":///") || strings.HasPrefix(cfg.Target, "unix:") {
+		return cfg.Target, nil
+	}
+
+	switch cfg.Scheme {
+	case "", "passthrough":
+		return "passthrough:///" + cfg.Target, nil
+	case "dns", "xds":
+		return cfg.Scheme + ":///" + cfg.Target, nil
+	default:
+		return "", fmt.Errorf("{{.Name}}: unsupported resolver scheme %q", cfg.Scheme)
+	}
+}
+
+// {{.Name}}Clients dials {{.Name}} connections and tracks the calls in flight
+// on them, so that consumers can shut down as cleanly as servers: CloseAll
+// stops new calls, waits for the ones in flight and closes every
+// connection. It is safe for concurrent use.
+type {{.Name}}Clients struct {
+	mu       sync.Mutex
+	conns    []*grpc.ClientConn
+	inflight int
+	idle     chan struct{}
+	closing  bool
+}
+
+// Dial dials {{.Name}} as Dial{{.Name}} does, tracking the calls made through
+// the connection.
+func (c *{{.Name}}Clients) Dial(ctx context.Context, cfg {{.Name}}DialConfig) (*grpc.ClientConn, error) {
+	cfg.UnaryInterceptors = append([]grpc.UnaryClientInterceptor{c.unaryInterceptor}, cfg.UnaryInterceptors...)
+	cfg.StreamInterceptors = append([]grpc.StreamClientInterceptor{c.streamInterceptor}, cfg.StreamInterceptors...)
+	cc, err := Dial{{.Name}}(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		cc.Close()
+		return nil, fmt.Errorf("{{.Name}}: clients are closed")
+	}
+	c.conns = append(c.conns, cc)
+	return cc, nil
+}
+
+// begin counts a call in flight, unless the clients are closing.
+func (c *{{.Name}}Clients) begin() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return status.Error(codes.Unavailable, "{{.Name}}: clients are closing")
+	}
+	c.inflight++
+	return nil
+}
+
+// end counts a call out.
+func (c *{{.Name}}Clients) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if c.inflight == 0 && c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+func (c *{{.Name}}Clients) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if err := c.begin(); err != nil {
+		return err
+	}
+	defer c.end()
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (c *{{.Name}}Clients) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		c.end()
+		return nil, err
+	}
+	s := &{{.LowerName}}TrackedStream{ClientStream: cs, serverStreams: desc.ServerStreams, finished: make(chan struct{})}
+	// Streams end when RecvMsg fails, io.EOF included, once the single
+	// output of a client stream is received, or when their context is done.
+	go func() {
+		select {
+		case <-s.finished:
+		case <-ctx.Done():
+		}
+		c.end()
+	}()
+	return s, nil
+}
+
+// {{.LowerName}}TrackedStream reports the end of a client stream.
+type {{.LowerName}}TrackedStream struct {
+	grpc.ClientStream
+	// serverStreams is false for client streams, which end with the output
+	// received by CloseAndRecv.
+	serverStreams bool
+	once          sync.Once
+	finished      chan struct{}
+}
+
+func (s *{{.LowerName}}TrackedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.once.Do(func() { close(s.finished) })
+	}
+	return err
+}
+
+// Wait blocks until no call is in flight or ctx is done.
+func (c *{{.Name}}Clients) Wait(ctx context.Context) error {
+	c.mu.Lock()
+	if c.inflight == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.idle == nil {
+		c.idle = make(chan struct{})
+	}
+	idle := c.idle
+	c.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseAll fails new calls with Unavailable, waits for the calls in flight
+// until ctx is done, then closes every connection. It returns the error of
+// ctx when calls were still in flight, or the first error closing a
+// connection.
+func (c *{{.Name}}Clients) CloseAll(ctx context.Context) error {
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+
+	err := c.Wait(ctx)
+
+	c.mu.Lock()
+	conns := c.conns
+	c.conns = nil
+	c.mu.Unlock()
+	for _, cc := range conns {
+		if cerr := cc.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+{{- if .GenServiceConfig }}
+// {{.Name}}SafeClient wraps a {{.Name}} client to call each method as its
+// idempotency_level allows: calls to idempotent methods wait for the
+// connection to be ready, while calls to the other methods fail fast. Call
+// options and the retry policy of the channel, e.g. set by the
+// ServiceConfig of {{.Name}}DialConfig, are passed through and apply to
+// every method.
+{{- else }}
+// {{.Name}}SafeClient wraps a {{.Name}} client to call each method as its
+// idempotency_level allows: calls to idempotent methods wait for the
+// connection to be ready and unary ones are retried on their retryable
+// codes, while calls to the other methods fail fast and are not retried by
+// the wrapper. Call options and the retry policy of the channel, e.g. set
+// by the ServiceConfig of {{.Name}}DialConfig, are passed through and apply
+// to every method.
+{{- end }}
+type {{.Name}}SafeClient struct {
+	client {{.GoPrefix}}.{{.Name}}Client
+}
+
+// New{{.Name}}SafeClient wraps client.
+func New{{.Name}}SafeClient(client {{.GoPrefix}}.{{.Name}}Client) *{{.Name}}SafeClient {
+	return &{{.Name}}SafeClient{client: client}
+}
+{{ range .Methods }}
+	{{- if .GetClientStreaming }}
+
+// {{.Name}} opens the stream{{ if .Idempotent }} once the connection is ready{{ end }}.
+func (c *{{$.Name}}SafeClient) {{.Name}}(ctx context.Context, opts ...grpc.CallOption) ({{$.GoPrefix}}.{{.ClientStreamName}}, error) {
+	{{- if .Idempotent }}
+	opts = append([]grpc.CallOption{grpc.WaitForReady(true)}, opts...)
+	{{- end }}
+	return c.client.{{.Name}}(ctx, opts...)
+}
+	{{- else if .GetServerStreaming }}
+
+// {{.Name}} opens the stream{{ if .Idempotent }} once the connection is ready{{ end }}.
+func (c *{{$.Name}}SafeClient) {{.Name}}(ctx context.Context, in *{{$.GoPrefix}}.{{.InputGoName}}, opts ...grpc.CallOption) ({{$.GoPrefix}}.{{.ClientStreamName}}, error) {
+	{{- if .Idempotent }}
+	opts = append([]grpc.CallOption{grpc.WaitForReady(true)}, opts...)
+	{{- end }}
+	return c.client.{{.Name}}(ctx, in, opts...)
+}
+	{{- else }}
+	{{- $retry := .ClientRetry }}
+
+// {{.Name}} calls the method
+{{- if $retry }} up to {{$retry.MaxAttempts}} times once the connection is ready.
+{{- else if .Idempotent }} once the connection is ready.
+{{- else }} without waiting for the connection or
+// retrying it: it is not idempotent.
+{{- end }}
+func (c *{{$.Name}}SafeClient) {{.Name}}(ctx context.Context, in *{{$.GoPrefix}}.{{.InputGoName}}, opts ...grpc.CallOption) (*{{$.GoPrefix}}.{{.OutputGoName}}, error) {
+	{{- if .Idempotent }}
+	opts = append([]grpc.CallOption{grpc.WaitForReady(true)}, opts...)
+	{{- end }}
+	{{- if $retry }}
+	var out *{{$.GoPrefix}}.{{.OutputGoName}}
+	err := {{$.LowerName}}Retry(ctx, {{$retry.MaxAttempts}}, {{$retry.Codes}}, func() (err error) {
+		out, err = c.client.{{.Name}}(ctx, in, opts...)
+		return err
+	})
+	return out, err
+	{{- else }}
+	return c.client.{{.Name}}(ctx, in, opts...)
+	{{- end }}
+}
+	{{- end }}
+{{- end }}
+{{- if .HasClientRetries }}
+
+// {{.LowerName}}Retry calls call up to attempts times while it fails with
+// a retryable code, backing off 100ms, doubled up to 1s, with full jitter
+// between attempts. It gives up early when ctx is done.
+func {{.LowerName}}Retry(ctx context.Context, attempts int, retryable []codes.Code, call func() error) error {
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= attempts {
+			return err
+		}
+		retry := false
+		for _, c := range retryable {
+			if status.Code(err) == c {
+				retry = true
+			}
+		}
+		if !retry {
+			return err
+		}
+		t := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > time.Second {
+			backoff = time.Second
+		}
+	}
+}
+{{- end }}
+`)
@@ -0,0 +1,29 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestClientTrackedStream checks that the streams tracked by the clients of
+// gen_client count out of the calls in flight once they end, client streams
+// included: their CloseAndRecv makes a single successful RecvMsg, after
+// which CloseAll would otherwise wait for them until its deadline.
+func TestClientTrackedStream(t *testing.T) {
+	files := generateFiles(t, testRequest("gen_client=true", testFile("echo.proto", testService("Echo",
+		testMethod("Upload", true, false),
+		testMethod("Watch", false, true),
+	))))
+	client, ok := files["echo_client.go"]
+	if !ok {
+		t.Fatal("echo_client.go is not generated")
+	}
+	for _, want := range []string{
+		"s := &echoTrackedStream{ClientStream: cs, serverStreams: desc.ServerStreams, finished: make(chan struct{})}",
+		"err := s.ClientStream.RecvMsg(m)\n\tif err != nil || !s.serverStreams {\n\t\ts.once.Do(func() { close(s.finished) })",
+	} {
+		if !strings.Contains(client, want) {
+			t.Errorf("echo_client.go does not contain %q:\n%s", want, client)
+		}
+	}
+}
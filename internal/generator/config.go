@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// config is a parsed config file. It holds the same keys as the parameter
+// string, e.g.
+//
+//	GoPrefix: pb
+//	gen_client: true
+//	overrides:
+//	  StoreService:
+//	    gen_chaos: true
+type config struct {
+	options   url.Values
+	overrides map[string]url.Values
+}
+
+// loadConfig reads the YAML config file at path.
+func loadConfig(path string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	cfg := &config{overrides: make(map[string]url.Values)}
+	if ov, ok := raw["overrides"]; ok {
+		services, ok := ov.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: overrides must map service names to options", path)
+		}
+		for name, v := range services {
+			m, ok := v.(map[interface{}]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: overrides of %v must be a map of options", path, name)
+			}
+			vals, err := configValues(m)
+			if err != nil {
+				return nil, fmt.Errorf("%s: overrides of %v: %v", path, name, err)
+			}
+			cfg.overrides[fmt.Sprint(name)] = vals
+		}
+		delete(raw, "overrides")
+	}
+
+	m := make(map[interface{}]interface{}, len(raw))
+	for k, v := range raw {
+		m[k] = v
+	}
+	if cfg.options, err = configValues(m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// configValues converts YAML options to their parameter string form. Lists
+// are joined with commas.
+func configValues(m map[interface{}]interface{}) (url.Values, error) {
+	vals := url.Values{}
+	for k, v := range m {
+		key := fmt.Sprint(k)
+		switch v := v.(type) {
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			vals.Set(key, strings.Join(items, ","))
+		case map[interface{}]interface{}:
+			return nil, fmt.Errorf("option %s must not be a map", key)
+		case nil:
+			vals.Set(key, "")
+		default:
+			vals.Set(key, fmt.Sprint(v))
+		}
+	}
+	return vals, nil
+}
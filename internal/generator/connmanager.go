@@ -0,0 +1,150 @@
+package generator
+
+var connManagerTmpl = newTemplate("connmanager", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	{{.GoImport}}
+)
+
+// {{.Name}}ConnManager lazily dials and reuses one connection per {{.Name}}
+// target, which suits fan-out callers talking to many instances. It is safe
+// for concurrent use.
+type {{.Name}}ConnManager struct {
+	// Config is the template used to dial every target; its Target is
+	// replaced by the target requested from Conn.
+	Config {{.Name}}DialConfig
+	// HealthService is the service name sent in health checks. Leave it
+	// empty to check the overall server health.
+	HealthService string
+
+	mu     sync.Mutex
+	conns  map[string]*{{.LowerName}}Conn
+	closed bool
+}
+
+// {{.LowerName}}Conn guards the dialing of a single target.
+type {{.LowerName}}Conn struct {
+	mu sync.Mutex
+	cc *grpc.ClientConn
+}
+
+// New{{.Name}}ConnManager returns a connection manager dialing with cfg.
+func New{{.Name}}ConnManager(cfg {{.Name}}DialConfig) *{{.Name}}ConnManager {
+	return &{{.Name}}ConnManager{
+		Config: cfg,
+		conns:  make(map[string]*{{.LowerName}}Conn),
+	}
+}
+
+// Conn returns the connection to target, dialing and health checking it on
+// first use. A connection that has failed is closed and dialed again.
+func (m *{{.Name}}ConnManager) Conn(ctx context.Context, target string) (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errors.New("{{.Name}}: connection manager is closed")
+	}
+	c, ok := m.conns[target]
+	if !ok {
+		c = &{{.LowerName}}Conn{}
+		m.conns[target] = c
+	}
+	m.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cc != nil {
+		switch c.cc.GetState() {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			c.cc.Close()
+			c.cc = nil
+		default:
+			return c.cc, nil
+		}
+	}
+
+	cfg := m.Config
+	cfg.Target = target
+	cc, err := Dial{{.Name}}(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkHealth(ctx, cc); err != nil {
+		cc.Close()
+		return nil, err
+	}
+
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		cc.Close()
+		return nil, errors.New("{{.Name}}: connection manager is closed")
+	}
+	c.cc = cc
+
+	return cc, nil
+}
+
+// Client returns a {{.Name}} client using the managed connection to target.
+func (m *{{.Name}}ConnManager) Client(ctx context.Context, target string) ({{.GoPrefix}}.{{.Name}}Client, error) {
+	cc, err := m.Conn(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return {{.GoPrefix}}.New{{.Name}}Client(cc), nil
+}
+
+// checkHealth asks the standard health service whether the backend is
+// serving. Backends that do not implement health checking are assumed to be.
+func (m *{{.Name}}ConnManager) checkHealth(ctx context.Context, cc *grpc.ClientConn) error {
+	resp, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+		Service: m.HealthService,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return status.Errorf(codes.Unavailable, "{{.Name}}: backend is %s", resp.GetStatus())
+	}
+	return nil
+}
+
+// Close closes every managed connection. Conn fails once Close was called.
+func (m *{{.Name}}ConnManager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	conns := m.conns
+	m.conns = make(map[string]*{{.LowerName}}Conn)
+	m.mu.Unlock()
+
+	var firstErr error
+	for _, c := range conns {
+		c.mu.Lock()
+		if c.cc != nil {
+			if err := c.cc.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			c.cc = nil
+		}
+		c.mu.Unlock()
+	}
+	return firstErr
+}
+`)
@@ -0,0 +1,97 @@
+package generator
+
+var ctxKeysTmpl = newTemplate("ctxkeys", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"database/sql"
+	"log"
+	"os"
+
+	"golang.org/x/net/context"
+)
+
+// The context values of {{.Name}} are keyed by unexported types, so that
+// they can only be reached through the accessors below.
+type (
+	{{.LowerName}}PrincipalKey struct{}
+	{{.LowerName}}TenantKey    struct{}
+	{{.LowerName}}RequestIDKey struct{}
+	{{.LowerName}}LoggerKey    struct{}
+	{{.LowerName}}TxKey        struct{}
+)
+
+// With{{.Name}}Principal returns a copy of ctx carrying the authenticated
+// principal.
+func With{{.Name}}Principal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}PrincipalKey{}, principal)
+}
+
+// {{.Name}}PrincipalFromContext returns the principal stored in ctx, or ""
+// when there is none.
+func {{.Name}}PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value({{.LowerName}}PrincipalKey{}).(string)
+	return principal
+}
+
+// With{{.Name}}Tenant returns a copy of ctx carrying tenant.
+func With{{.Name}}Tenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}TenantKey{}, tenant)
+}
+
+// {{.Name}}TenantFromContext returns the tenant stored in ctx
+{{- if .GenTenancy }} by the
+// {{.Name}}Tenancy interceptors{{ end }}, or "" when there is none.
+func {{.Name}}TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value({{.LowerName}}TenantKey{}).(string)
+	return tenant
+}
+
+// With{{.Name}}RequestID returns a copy of ctx carrying the ID of the
+// request being served.
+func With{{.Name}}RequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}RequestIDKey{}, id)
+}
+
+// {{.Name}}RequestIDFromContext returns the request ID stored in ctx, or ""
+// when there is none.
+func {{.Name}}RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value({{.LowerName}}RequestIDKey{}).(string)
+	return id
+}
+
+// {{.LowerName}}DefaultLogger is returned by {{.Name}}LoggerFromContext when
+// ctx carries no logger.
+var {{.LowerName}}DefaultLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+// ContextWith{{.Name}}Logger returns a copy of ctx carrying a logger scoped
+// to the request. It is not named like the other accessors, which would
+// collide with the Logger option of constructor_style=options.
+func ContextWith{{.Name}}Logger(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}LoggerKey{}, logger)
+}
+
+// {{.Name}}LoggerFromContext returns the logger stored in ctx, or a logger
+// writing to stderr when there is none.
+func {{.Name}}LoggerFromContext(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value({{.LowerName}}LoggerKey{}).(*log.Logger); ok && logger != nil {
+		return logger
+	}
+	return {{.LowerName}}DefaultLogger
+}
+
+// With{{.Name}}Tx returns a copy of ctx carrying the transaction the
+// request runs in.
+func With{{.Name}}Tx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}TxKey{}, tx)
+}
+
+// {{.Name}}TxFromContext returns the transaction stored in ctx, and whether
+// there is one.
+func {{.Name}}TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value({{.LowerName}}TxKey{}).(*sql.Tx)
+	return tx, ok && tx != nil
+}
+`)
@@ -0,0 +1,69 @@
+package generator
+
+import "strings"
+
+// HasDownstream reports whether any method of the service calls downstream
+// services.
+func (p Service) HasDownstream() bool {
+	for _, m := range p.Methods {
+		if len(m.Downstream()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DownstreamList returns the downstream services of the method, separated
+// by commas.
+func (m method) DownstreamList() string {
+	return strings.Join(m.Downstream(), ", ")
+}
+
+// DeadlineReserveExpr returns the Go expression of the deadline reserve.
+func (p Service) DeadlineReserveExpr() string {
+	return durationExpr(p.DeadlineReserve)
+}
+
+// deadlineTmpl declares the deadline propagation helper in the service file
+// of services calling downstream services.
+var deadlineTmpl = `
+{{- define "deadline_helper" }}
+// {{.Name}}DeadlineReserve is the time kept from the deadline of incoming
+// calls for the work left after their downstream calls return.
+var {{.Name}}DeadlineReserve = {{.DeadlineReserveExpr}}
+
+// {{.Name}}ChildContext derives the context of a downstream call from ctx,
+// with a deadline {{.Name}}DeadlineReserve before the deadline of ctx. It
+// fails with DeadlineExceeded when the remaining time does not cover the
+// reserve. The deadline is left unset when ctx has none.
+func {{.Name}}ChildContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		child, cancel := context.WithCancel(ctx)
+		return child, cancel, nil
+	}
+	budget := time.Until(deadline) - {{.Name}}DeadlineReserve
+	if budget <= 0 {
+		{{- if .GenCatalog }}
+		return nil, nil, {{.Name}}Errorf(ctx, codes.DeadlineExceeded, {{.Name}}MsgNoTimeLeft)
+		{{- else }}
+		return nil, nil, status.Error(codes.DeadlineExceeded, "no time left for downstream calls")
+		{{- end }}
+	}
+	child, cancel := context.WithTimeout(ctx, budget)
+	return child, cancel, nil
+}
+{{- end }}
+
+{{- define "downstream" }}
+	{{- if .Downstream }}
+	callCtx, cancel, err := {{.Service.Name}}ChildContext({{.Ctx}})
+	if err != nil {
+		{{.Return "err"}}
+	}
+	defer cancel()
+	// {{.TodoNote (printf "Call %s with callCtx" .DownstreamList)}}
+	_ = callCtx
+	{{ end }}
+{{- end }}
+`
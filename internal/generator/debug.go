@@ -0,0 +1,58 @@
+package generator
+
+// debugMessage is a message of the service given a debug string helper.
+type debugMessage struct {
+	// GoName is the Go type name of the message.
+	GoName string
+}
+
+// DebugMessages returns the inputs and outputs of the methods declared in
+// the service's own proto package, each once, in the order of the methods.
+func (p Service) DebugMessages() []debugMessage {
+	var ms []debugMessage
+	seen := make(map[string]bool)
+	for _, m := range p.Methods {
+		for _, name := range []string{m.GetInputType(), m.GetOutputType()} {
+			msg, ok := p.messages[name]
+			if !ok || seen[name] || msg.Package != p.PackageName {
+				continue
+			}
+			seen[name] = true
+			ms = append(ms, debugMessage{GoName: msg.GoName})
+		}
+	}
+	return ms
+}
+
+var debugTmpl = newTemplate("debug", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	{{- if .DebugMessages }}
+	{{.GoImport}}
+	{{- end }}
+)
+
+// {{.LowerName}}DebugString returns m as single-line protojson, with the
+// fields marked (service_gen.sensitive) cleared, for logs to read as JSON
+// rather than the text format with its escaped bytes. It falls back to the
+// text format when m cannot be marshaled.
+func {{.LowerName}}DebugString(m proto.Message) string {
+	m = {{.LowerName}}Redact(m)
+	s, err := (&jsonpb.Marshaler{}).MarshalToString(m)
+	if err != nil {
+		return proto.CompactTextString(m)
+	}
+	return s
+}
+{{ range .DebugMessages }}
+// {{$.Name}}Debug{{.GoName}} returns the redacted protojson of m for logs.
+func {{$.Name}}Debug{{.GoName}}(m *{{$.GoPrefix}}.{{.GoName}}) string {
+	return {{$.LowerName}}DebugString(m)
+}
+{{ end }}
+`)
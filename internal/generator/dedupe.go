@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// dedupeKey is the key the inputs of a stream are deduplicated on.
+type dedupeKey struct {
+	// Expr is the Go expression of the key of input, a string.
+	Expr string
+	// Zero is the key of inputs leaving the field unset, which are never
+	// skipped.
+	Zero string
+}
+
+// Dedupe returns the key the inputs of the method are deduplicated on, or
+// nil when it is no client or bidi stream with (service_gen.dedupe_by).
+// The field must be a string, bytes, integer or enum field of the input.
+func (m method) Dedupe() (*dedupeKey, error) {
+	if !m.GetClientStreaming() || m.DedupeBy() == "" {
+		return nil, nil
+	}
+	in, ok := m.messages[m.GetInputType()]
+	if !ok {
+		return nil, errors.New("invalid dedupe_by option on " + m.GetName() + ": the input is declared in no file of the request")
+	}
+	for _, f := range in.GetField() {
+		if f.GetName() != m.DedupeBy() {
+			continue
+		}
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return nil, errors.New("invalid dedupe_by option on " + m.GetName() + ": " + f.GetName() + " is repeated")
+		}
+		getter := "input.Get" + goFieldName(f.GetName()) + "()"
+		switch f.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			return &dedupeKey{Expr: getter, Zero: `""`}, nil
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			return &dedupeKey{Expr: "string(" + getter + ")", Zero: `""`}, nil
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+			descriptor.FieldDescriptorProto_TYPE_GROUP,
+			descriptor.FieldDescriptorProto_TYPE_BOOL,
+			descriptor.FieldDescriptorProto_TYPE_FLOAT,
+			descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+			return nil, errors.New("invalid dedupe_by option on " + m.GetName() + ": " + f.GetName() + " is no string, bytes, integer or enum field")
+		}
+		return &dedupeKey{Expr: "fmt.Sprint(" + getter + ")", Zero: `"0"`}, nil
+	}
+	return nil, errors.New("invalid dedupe_by option on " + m.GetName() + ": " + m.TrimmedInput() + " has no field " + m.DedupeBy())
+}
+
+// HasDedupe reports whether any method of the service deduplicates its
+// inputs.
+func (p Service) HasDedupe() bool {
+	for _, m := range p.Methods {
+		if m.GetClientStreaming() && m.DedupeBy() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupeTmpl declares the deduplication of the inputs of streams annotated
+// with (service_gen.dedupe_by): "dedupe_window" the helper in the service
+// file, "dedupe_start", "dedupe" and "dedupe_mark" its use in the stream
+// loops.
+var dedupeTmpl = `
+{{- define "dedupe_window" }}
+// {{.Name}}Dedupe remembers the last keys of the inputs handled by a
+// stream, up to a window of them, for it to skip duplicate inputs, e.g.
+// events resent by a client unsure they were received. Memory is bounded by
+// the window. It is safe for concurrent use.
+type {{.Name}}Dedupe struct {
+	mu     sync.Mutex
+	window int
+	seen   map[string]struct{}
+	// ring holds the keys of seen in the order they were added, next being
+	// the oldest once the ring is full.
+	ring []string
+	next int
+}
+
+// New{{.Name}}Dedupe returns a dedupe remembering the last window keys.
+func New{{.Name}}Dedupe(window int) *{{.Name}}Dedupe {
+	return &{{.Name}}Dedupe{window: window, seen: make(map[string]struct{})}
+}
+
+// Check reports whether key is among the keys remembered.
+func (d *{{.Name}}Dedupe) Check(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.seen[key]
+	return ok
+}
+
+// Mark remembers key, once the input it belongs to is handled, forgetting
+// the oldest key when the window is full. Inputs whose handling failed are
+// left unmarked, so that they are handled again when resent.
+func (d *{{.Name}}Dedupe) Mark(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[key]; ok || d.window <= 0 {
+		return
+	}
+	if len(d.ring) < d.window {
+		d.ring = append(d.ring, key)
+	} else {
+		delete(d.seen, d.ring[d.next])
+		d.ring[d.next] = key
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	d.seen[key] = struct{}{}
+}
+{{- end }}
+
+{{- define "dedupe_start" }}
+	{{- if .Dedupe }}
+
+	// dedupe skips the inputs whose {{.DedupeBy}} is among the last
+	// {{.DedupeWindow}} handled by the stream.
+	dedupe := New{{.Service.Name}}Dedupe({{.DedupeWindow}})
+	{{- end }}
+{{- end }}
+
+{{- define "dedupe" }}
+		{{- with .Dedupe }}
+		key := {{.Expr}}
+		if key != {{.Zero}} && dedupe.Check(key) {
+			continue
+		}
+		{{- end }}
+{{- end }}
+
+{{- define "dedupe_mark" }}
+		{{- with .Dedupe }}
+		if key != {{.Zero}} {
+			dedupe.Mark(key)
+		}
+		{{- end }}
+{{- end }}
+`
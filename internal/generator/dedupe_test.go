@@ -0,0 +1,38 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/nstogner/protoc-gen-grpc-go-service/servicegen"
+)
+
+// TestDedupe checks that the streams of methods with dedupe_by remember
+// the keys of the inputs they handled, in a window of their own, only once
+// handled: inputs whose handling failed are handled again when resent.
+func TestDedupe(t *testing.T) {
+	chat := testMethod("Chat", true, true)
+	chat.Options = &descriptor.MethodOptions{}
+	if err := proto.SetExtension(chat.Options, servicegen.E_DedupeBy, proto.String("id")); err != nil {
+		t.Fatal(err)
+	}
+	files := generateFiles(t, testRequest("", testFile("echo.proto", testService("Echo", chat))))
+	service, ok := files["echo_service.go"]
+	if !ok {
+		t.Fatal("echo_service.go is not generated")
+	}
+	for _, want := range []string{
+		"\tdedupe := NewEchoDedupe(10000)\n\tfor {\n",
+		"\t\tkey := input.GetId()\n\t\tif key != \"\" && dedupe.Check(key) {\n\t\t\tcontinue\n\t\t}\n",
+		"\t\tif err := stream.Send(&protos.Response{}); err != nil {\n\t\t\treturn err\n\t\t}\n\t\tif key != \"\" {\n\t\t\tdedupe.Mark(key)\n\t\t}\n",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("echo_service.go does not contain %q:\n%s", want, service)
+		}
+	}
+	if strings.Contains(service, "var echoChatDedupe") {
+		t.Errorf("echo_service.go shares a dedupe window across streams:\n%s", service)
+	}
+}
@@ -0,0 +1,162 @@
+package generator
+
+// depHealthTmpl declares the dependency checks in the service file of
+// services generated with gen_dep_health.
+var depHealthTmpl = `
+{{- define "dep_checks" }}
+// {{.Name}}Pinger is implemented by the dependencies able to tell whether
+// they are usable, e.g. database or cache clients.
+type {{.Name}}Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// CheckAll pings the dependencies of the service implementing
+// {{.Name}}Pinger concurrently, returning the error of each by name, nil
+// for the healthy ones. Dependencies without Ping are left out.
+func (s {{.Name}}Service) CheckAll(ctx context.Context) map[string]error {
+	deps := map[string]interface{}{
+		{{- range .Deps }}
+		"{{.Name}}": s.{{.Name}},
+		{{- end }}
+	}
+	// {{.TodoNote "List the dependencies added to the service"}}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error)
+	)
+	for name, dep := range deps {
+		p, ok := dep.({{.Name}}Pinger)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, p {{.Name}}Pinger) {
+			defer wg.Done()
+			err := p.Ping(ctx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, p)
+	}
+	wg.Wait()
+	return results
+}
+{{- end }}
+`
+
+var depHealthFileTmpl = newTemplate("dep-health", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// {{.Name}}HealthChecker checks the dependencies of a service, returning
+// the error of each by name. {{.Name}}Service implements it.
+type {{.Name}}HealthChecker interface {
+	CheckAll(ctx context.Context) map[string]error
+}
+
+// {{.Name}}Health periodically checks the dependencies of the service and
+// reports the service as NOT_SERVING on the health server while any of them
+// fails, and as not ready on its readiness endpoint.
+type {{.Name}}Health struct {
+	// Checker checks the dependencies. The service is always healthy
+	// without it.
+	Checker {{.Name}}HealthChecker
+	// Server is the health server to update, if any.
+	Server *health.Server
+	// Interval is the time between checks, 10s when zero.
+	Interval time.Duration
+	// Timeout bounds each check, 5s when zero.
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	checked  bool
+	failures map[string]string
+}
+
+// Check checks the dependencies once and reports the result.
+func (h *{{.Name}}Health) Check(ctx context.Context) {
+	failures := make(map[string]string)
+	if h.Checker != nil {
+		timeout := h.Timeout
+		if timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		for name, err := range h.Checker.CheckAll(ctx) {
+			if err != nil {
+				failures[name] = err.Error()
+			}
+		}
+		cancel()
+	}
+
+	h.mu.Lock()
+	h.checked = true
+	h.failures = failures
+	h.mu.Unlock()
+
+	if h.Server != nil {
+		status := grpc_health_v1.HealthCheckResponse_SERVING
+		if len(failures) > 0 {
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+		h.Server.SetServingStatus("", status)
+		h.Server.SetServingStatus("{{.FullName}}", status)
+	}
+}
+
+// Watch checks the dependencies every Interval until ctx is done.
+func (h *{{.Name}}Health) Watch(ctx context.Context) {
+	interval := h.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			h.Check(ctx)
+		}
+	}
+}
+
+// ServeHTTP is the readiness endpoint: it answers 200 when the last check
+// passed, and 503 before the first check or with the errors of the failed
+// dependencies otherwise.
+func (h *{{.Name}}Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	checked, failures := h.checked, h.failures
+	h.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !checked {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"ready": false})
+		return
+	}
+	if len(failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready":    len(failures) == 0,
+		"failures": failures,
+	})
+}
+`)
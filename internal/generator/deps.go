@@ -0,0 +1,92 @@
+package generator
+
+import "errors"
+
+// dep is a dependency of the service struct.
+type dep struct {
+	// Name is the name of the field holding the dependency.
+	Name string
+	// Type is its Go type.
+	Type string
+	// Doc documents the field.
+	Doc string
+	// Default is the Go expression of its value when unset, if any.
+	Default string
+}
+
+// Deps returns the dependencies of the service struct. Services generated
+// with a ConstructorStyle log and tell the time through theirs. The lazy
+// dependencies come last; their errors are reported by LazyDeps.
+func (p Service) Deps() []dep {
+	deps := p.fixedDeps()
+	lazy, _ := p.LazyDeps()
+	for _, d := range lazy {
+		deps = append(deps, dep{
+			Name: d.GoName,
+			Type: "*" + p.GetName() + "Lazy",
+			Doc:  d.GoName + " is initialized on first use by Get" + d.GoName + ".",
+		})
+	}
+	return deps
+}
+
+// fixedDeps returns the dependencies of the service struct the generator
+// adds itself.
+func (p Service) fixedDeps() []dep {
+	var deps []dep
+	if p.ConstructorStyle != "" {
+		deps = append(deps,
+			dep{
+				Name:    "Logger",
+				Type:    "*log.Logger",
+				Doc:     "Logger receives the logs of the service.",
+				Default: `log.New(os.Stderr, "` + p.GetName() + `: ", log.LstdFlags)`,
+			},
+			dep{
+				Name:    "Clock",
+				Type:    "func() time.Time",
+				Doc:     "Clock tells the time, which tests may want to freeze.",
+				Default: "time.Now",
+			},
+		)
+	}
+	if p.HasFeatureFlags() {
+		deps = append(deps, dep{
+			Name: "Flags",
+			Type: p.GetName() + "FlagProvider",
+			Doc:  "Flags gates dark-launched methods, which stay off while it is nil.",
+		})
+	}
+	return deps
+}
+
+// checkOptionNames rejects the dependencies whose option of
+// ConstructorStyle "options", With<Service><Name>, is the name of a function
+// generated by another option, e.g. a lazy dependency named tenant along
+// with the context accessors of gen_ctxkeys.
+func (p Service) checkOptionNames() error {
+	if p.ConstructorStyle != "options" {
+		return nil
+	}
+	generated := make(map[string]string)
+	add := func(enabled bool, param string, names ...string) {
+		if !enabled {
+			return
+		}
+		for _, name := range names {
+			generated["With"+p.GetName()+name] = param
+		}
+	}
+	add(p.GenCtxKeys, "gen_ctxkeys", "Principal", "Tenant", "RequestID", "Tx")
+	add(p.GenTenancy, "gen_tenancy", "Tenant")
+	add(p.GenBaggage, "gen_baggage", "Baggage")
+	add(p.GenTraceHeaders, "gen_trace_headers", "Trace")
+	add(p.GenTestServer, "gen_test_server", "TestService", "TestInterceptors", "TestServerOptions", "TestDialOptions")
+	for _, d := range p.Deps() {
+		name := "With" + p.GetName() + d.Name
+		if param, ok := generated[name]; ok {
+			return errors.New("invalid constructor_style option on " + p.GetName() + ": the option of the " + d.Name + " dependency, " + name + ", is also generated by " + param)
+		}
+	}
+	return nil
+}
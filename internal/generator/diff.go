@@ -0,0 +1,306 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// updateDiff describes an Update method whose changes are diffed.
+type updateDiff struct {
+	// Field is the Go name of the input field holding the resource.
+	Field string
+	// GoName is the Go type name of the resource.
+	GoName string
+	// Mask is the Go name of the FieldMask input field, if any.
+	Mask string
+	// Fields are the top-level fields of the resource.
+	Fields []diffField
+}
+
+// diffField is a field of a resource compared by an update diff.
+type diffField struct {
+	// Path is the proto name of the field, as found in field masks.
+	Path string
+	// Getter is the Go getter of the field.
+	Getter string
+	// Comparable reports whether values of the field compare with ==.
+	Comparable bool
+	// Sensitive reports whether the field is marked (service_gen.sensitive),
+	// whose values are left out of diffs.
+	Sensitive bool
+}
+
+// UpdateDiff returns the resource updated by the method, or nil when it is
+// no Update method: its name must start with Update, it must be unary, and
+// its input must hold the resource, a message of the service's
+// own proto package, preferably in the field named after the method, e.g.
+// book for UpdateBook. A google.protobuf.FieldMask field of the input
+// selects the fields updated.
+func (m method) UpdateDiff() *updateDiff {
+	if !strings.HasPrefix(m.GetName(), "Update") || m.GetClientStreaming() || m.GetServerStreaming() {
+		return nil
+	}
+	in, ok := m.messages[m.GetInputType()]
+	if !ok {
+		return nil
+	}
+	want := strings.ToLower(envName(strings.TrimPrefix(m.GetName(), "Update")))
+	var resource *descriptor.FieldDescriptorProto
+	d := &updateDiff{}
+	for _, f := range in.GetField() {
+		if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			continue
+		}
+		if f.GetTypeName() == ".google.protobuf.FieldMask" {
+			if d.Mask == "" {
+				d.Mask = goFieldName(f.GetName())
+			}
+			continue
+		}
+		msg, ok := m.messages[f.GetTypeName()]
+		if !ok || msg.Package != m.service.PackageName || msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		if resource == nil || f.GetName() == want {
+			resource = f
+		}
+	}
+	if resource == nil {
+		return nil
+	}
+	msg := m.messages[resource.GetTypeName()]
+	d.Field = goFieldName(resource.GetName())
+	d.GoName = msg.GoName
+	for _, f := range msg.GetField() {
+		d.Fields = append(d.Fields, diffField{
+			Path:   f.GetName(),
+			Getter: "Get" + goFieldName(f.GetName()) + "()",
+			Comparable: f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED &&
+				f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+				f.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES,
+			Sensitive: sensitive(f),
+		})
+	}
+	return d
+}
+
+// HasUpdateDiffs reports whether any method of the service is diffed.
+func (p Service) HasUpdateDiffs() bool {
+	for _, m := range p.Methods {
+		if m.UpdateDiff() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// updateDiffTmpl declares the publishing of diffs in the handlers of the
+// Update methods of services generated with gen_update_diff.
+var updateDiffTmpl = `
+{{- define "update_diff" }}
+	{{- if .Service.GenUpdateDiff }}
+	{{- with .UpdateDiff }}
+
+	// {{$.TodoNote (printf "Load the stored %s instead of an empty one" .GoName)}}
+	stored := &{{$.Service.GoPrefix}}.{{.GoName}}{}
+	Default{{$.Service.Name}}Diffs.Publish({{$.Ctx}}, {{$.Service.Name}}{{$.Name}}Diff(stored, input))
+	{{- end }}
+	{{- end }}
+{{- end }}
+`
+
+var diffTmpl = newTemplate("diff", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	{{- if .HasUpdateDiffs }}
+	{{.GoImport}}
+	{{- end }}
+)
+
+// {{.Name}}FieldChange is a field changed by an update.
+type {{.Name}}FieldChange struct {
+	// Path is the proto name of the field.
+	Path string
+	// Old and New are the stored and the updated values, both nil for
+	// fields marked (service_gen.sensitive).
+	Old, New interface{}
+	// Sensitive reports whether the values were left out.
+	Sensitive bool
+}
+
+// {{.Name}}Diff is the set of fields an update changes in a resource.
+type {{.Name}}Diff struct {
+	// FullMethod is the full name of the Update method, e.g.
+	// "/{{.FullName}}/UpdateResource".
+	FullMethod string
+	Changes    []{{.Name}}FieldChange
+}
+
+// Paths returns the paths of the changed fields.
+func (d {{.Name}}Diff) Paths() []string {
+	paths := make([]string, len(d.Changes))
+	for i, c := range d.Changes {
+		paths[i] = c.Path
+	}
+	return paths
+}
+
+// add records a change of the field at path.
+func (d *{{.Name}}Diff) add(path string, before, after interface{}, sensitive bool) {
+	if sensitive {
+		before, after = nil, nil
+	}
+	d.Changes = append(d.Changes, {{.Name}}FieldChange{Path: path, Old: before, New: after, Sensitive: sensitive})
+}
+
+// {{.Name}}DiffObserver consumes the diffs of updates, e.g. to write audit
+// logs or emit change events.
+type {{.Name}}DiffObserver interface {
+	ObserveDiff(ctx context.Context, d {{.Name}}Diff)
+}
+
+// {{.Name}}DiffObserverFunc adapts a func to {{.Name}}DiffObserver.
+type {{.Name}}DiffObserverFunc func(ctx context.Context, d {{.Name}}Diff)
+
+// ObserveDiff calls f.
+func (f {{.Name}}DiffObserverFunc) ObserveDiff(ctx context.Context, d {{.Name}}Diff) {
+	f(ctx, d)
+}
+
+// {{.Name}}Diffs publishes the diffs of updates to the registered observers.
+type {{.Name}}Diffs struct {
+	mu        sync.RWMutex
+	observers []{{.Name}}DiffObserver
+}
+
+// Default{{.Name}}Diffs is the publisher the Update handlers use. It writes
+// an audit log of the changes to the standard logger.
+var Default{{.Name}}Diffs = &{{.Name}}Diffs{observers: []{{.Name}}DiffObserver{ {{.Name}}AuditLog{} }}
+
+// Register adds o to the observers of the diffs.
+func (p *{{.Name}}Diffs) Register(o {{.Name}}DiffObserver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observers = append(p.observers, o)
+}
+
+// Publish notifies the observers of d, unless it changes nothing.
+func (p *{{.Name}}Diffs) Publish(ctx context.Context, d {{.Name}}Diff) {
+	if len(d.Changes) == 0 {
+		return
+	}
+	p.mu.RLock()
+	observers := p.observers
+	p.mu.RUnlock()
+	for _, o := range observers {
+		o.ObserveDiff(ctx, d)
+	}
+}
+
+// {{.Name}}AuditLog logs a line per diff, values of sensitive fields left
+// out.
+type {{.Name}}AuditLog struct {
+	// Logger is the logger to write to, the standard logger when nil.
+	Logger *log.Logger
+}
+
+// ObserveDiff logs d.
+func (a {{.Name}}AuditLog) ObserveDiff(ctx context.Context, d {{.Name}}Diff) {
+	changes := make([]string, len(d.Changes))
+	for i, c := range d.Changes {
+		if c.Sensitive {
+			changes[i] = c.Path + ": [redacted]"
+		} else {
+			changes[i] = fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+		}
+	}
+	logf := log.Printf
+	if a.Logger != nil {
+		logf = a.Logger.Printf
+	}
+	logf("audit: %s changed %s", d.FullMethod, strings.Join(changes, ", "))
+}
+
+// {{.LowerName}}Masked returns whether a field is selected by the paths of a
+// field mask: all are without paths, and nested paths select their whole
+// top-level field.
+func {{.LowerName}}Masked(paths []string) func(field string) bool {
+	return func(field string) bool {
+		if len(paths) == 0 {
+			return true
+		}
+		for _, p := range paths {
+			if p == "*" || p == field || strings.HasPrefix(p, field+".") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// {{.LowerName}}Equal reports whether two field values are equal: messages
+// as protobuf messages, and lists and maps element by element, with nil and
+// empty ones alike.
+func {{.LowerName}}Equal(a, b interface{}) bool {
+	if m, ok := a.(proto.Message); ok {
+		n, _ := b.(proto.Message)
+		return proto.Equal(m, n)
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Slice:
+		if va.Len() != vb.Len() {
+			return false
+		}
+		for i := 0; i < va.Len(); i++ {
+			if !{{.LowerName}}Equal(va.Index(i).Interface(), vb.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if va.Len() != vb.Len() {
+			return false
+		}
+		for _, k := range va.MapKeys() {
+			w := vb.MapIndex(k)
+			if !w.IsValid() || !{{.LowerName}}Equal(va.MapIndex(k).Interface(), w.Interface()) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+{{ range .Methods }}
+{{- $method := . }}
+{{- with .UpdateDiff }}
+// {{$.Name}}{{$method.Name}}Diff returns the changes {{$method.Name}} makes to stored,
+// {{ if .Mask }}restricted to the fields selected by the {{.Mask}} of in{{ else }}comparing every field of the {{.Field}} of in{{ end }}.
+func {{$.Name}}{{$method.Name}}Diff(stored *{{$.GoPrefix}}.{{.GoName}}, in *{{$.GoPrefix}}.{{$method.InputGoName}}) {{$.Name}}Diff {
+	d := {{$.Name}}Diff{FullMethod: "/{{$.FullName}}/{{$method.WireName}}"}
+	updated := in.Get{{.Field}}()
+	masked := {{$.LowerName}}Masked({{ if .Mask }}in.Get{{.Mask}}().GetPaths(){{ else }}nil{{ end }})
+	{{- range .Fields }}
+	if masked("{{.Path}}") && {{ if .Comparable }}stored.{{.Getter}} != updated.{{.Getter}}{{ else }}!{{$.LowerName}}Equal(stored.{{.Getter}}, updated.{{.Getter}}){{ end }} {
+		d.add("{{.Path}}", stored.{{.Getter}}, updated.{{.Getter}}, {{.Sensitive}})
+	}
+	{{- end }}
+	return d
+}
+{{ end }}
+{{- end }}
+`)
@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// dryRunName is the name of the report returned instead of the generated
+// files in dry runs.
+const dryRunName = "dry_run.txt"
+
+// wantsDryRun reports whether any service asks for a dry run.
+func wantsDryRun(ps []*Service) bool {
+	for _, p := range ps {
+		if p.DryRun {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunReport summarizes the files that would be generated and the
+// symbols their Go code declares, followed by the existing files that would
+// be skipped.
+func dryRunReport(files []*plugin.CodeGeneratorResponse_File, skipped []string) (*plugin.CodeGeneratorResponse_File, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "protoc-gen-grpc-go-service dry run: %d files would be generated\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(&b, "\n%s (%d bytes)\n", f.GetName(), len(f.GetContent()))
+		if !strings.HasSuffix(f.GetName(), ".go") {
+			continue
+		}
+		symbols, err := declaredSymbols(f.GetContent())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.GetName(), err)
+		}
+		for _, s := range symbols {
+			fmt.Fprintf(&b, "  %s\n", s)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\n%d existing files would be skipped:\n", len(skipped))
+		for _, name := range skipped {
+			fmt.Fprintf(&b, "  %s\n", name)
+		}
+	}
+	return &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(dryRunName),
+		Content: proto.String(b.String()),
+	}, nil
+}
+
+// declaredSymbols lists the exported top-level declarations of a Go file,
+// methods being qualified by their receiver type.
+func declaredSymbols(src string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := d.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if id, ok := recv.(*ast.Ident); ok {
+					if !id.IsExported() {
+						continue
+					}
+					name = id.Name + "." + name
+				}
+			}
+			symbols = append(symbols, "func "+name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					if sp.Name.IsExported() {
+						symbols = append(symbols, "type "+sp.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, n := range sp.Names {
+						if n.IsExported() {
+							symbols = append(symbols, d.Tok.String()+" "+n.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols, nil
+}
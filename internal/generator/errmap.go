@@ -0,0 +1,64 @@
+package generator
+
+var errMapTmpl = newTemplate("errmap", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}ErrorMapping translates errors matching Err, as reported by
+// errors.Is, to Code.
+type {{.Name}}ErrorMapping struct {
+	Err  error
+	Code codes.Code
+}
+
+var (
+	{{.LowerName}}ErrorMapMu sync.RWMutex
+	// {{.LowerName}}ErrorMap is consulted in order; the first match wins.
+	{{.LowerName}}ErrorMap = []{{.Name}}ErrorMapping{
+		{Err: context.DeadlineExceeded, Code: codes.DeadlineExceeded},
+		{Err: context.Canceled, Code: codes.Canceled},
+		// {{.TodoNote "Register the errors of your storage layer, e.g."}}
+		// {Err: sql.ErrNoRows, Code: codes.NotFound},
+	}
+)
+
+// Register{{.Name}}Error translates errors matching err to code. Mappings
+// registered later take precedence over earlier ones.
+func Register{{.Name}}Error(err error, code codes.Code) {
+	{{.LowerName}}ErrorMapMu.Lock()
+	defer {{.LowerName}}ErrorMapMu.Unlock()
+	m := {{.Name}}ErrorMapping{Err: err, Code: code}
+	{{.LowerName}}ErrorMap = append([]{{.Name}}ErrorMapping{m}, {{.LowerName}}ErrorMap...)
+}
+
+// {{.LowerName}}Status translates an error returned by a {{.Name}} handler
+// to a status error. Status errors are returned as is and unmapped errors
+// become codes.Unknown, as gRPC would report them anyway.
+func {{.LowerName}}Status(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	{{.LowerName}}ErrorMapMu.RLock()
+	defer {{.LowerName}}ErrorMapMu.RUnlock()
+	for _, m := range {{.LowerName}}ErrorMap {
+		if errors.Is(err, m.Err) {
+			return status.Error(m.Code, err.Error())
+		}
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+`)
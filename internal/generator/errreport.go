@@ -0,0 +1,384 @@
+package generator
+
+var errReportTmpl = newTemplate("errreport", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	{{- if .LogPayloads }}
+	"math/rand"
+	{{- end }}
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	{{- if .LogPayloads }}
+	"github.com/golang/protobuf/proto"
+	{{- end }}
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}ErrorReport describes an unexpected failure of a call.
+type {{.Name}}ErrorReport struct {
+	// Method is the full name of the method called.
+	Method string
+	// RequestID identifies the call, when the client sent one.
+	RequestID string
+	// Err is the error returned to the client.
+	Err error
+	// Panic is the value recovered when the handler panicked, with Stack
+	// the stack it panicked with.
+	Panic interface{}
+	Stack []byte
+	// Fingerprint identifies where the handler panicked, see
+	// {{.Name}}ErrorFingerprint.
+	Fingerprint string
+}
+
+// {{.Name}}ErrorReporter sends unexpected failures to an error tracker.
+// Report must not block the call for long.
+type {{.Name}}ErrorReporter interface {
+	Report(ctx context.Context, report {{.Name}}ErrorReport)
+}
+
+// {{.LowerName}}RequestIDHeader is the metadata key carrying request IDs.
+const {{.LowerName}}RequestIDHeader = "x-request-id"
+
+// {{.LowerName}}RequestID returns the ID of the request being served
+{{- if .GenCtxKeys }}, from
+// the context or else from the incoming metadata.
+{{- else }}, from
+// the incoming metadata.
+{{- end }}
+func {{.LowerName}}RequestID(ctx context.Context) string {
+	{{- if .GenCtxKeys }}
+	if id := {{.Name}}RequestIDFromContext(ctx); id != "" {
+		return id
+	}
+	{{- end }}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get({{.LowerName}}RequestIDHeader); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// {{.LowerName}}Unexpected reports whether err is worth reporting: Internal
+// and Unknown errors are bugs, other codes are part of the API.
+func {{.LowerName}}Unexpected(err error) bool {
+	switch status.Code(err) {
+	case codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+// {{.LowerName}}PanicReason is the reason of the ErrorInfo detail of the
+// errors recovered panics turn into.
+const {{.LowerName}}PanicReason = "PANIC"
+
+// {{.LowerName}}PanicFingerprint hashes the method, the type of the value
+// panicked with and the functions on the stack, leaving out line numbers
+// and values, so that the panics of a bug share a fingerprint across calls
+// and instances. It must be called from the deferred recovery.
+func {{.LowerName}}PanicFingerprint(method string, p interface{}) string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%T\n", method, p)
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			fmt.Fprintln(h, frame.Function)
+		}
+		if !more {
+			break
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// {{.Name}}ErrorFingerprint returns the fingerprint of the panic err was
+// recovered from, or "" when it was not. Clients can show it as an error ID
+// for support to find the matching logs.
+func {{.Name}}ErrorFingerprint(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetReason() == {{.LowerName}}PanicReason {
+			return info.GetMetadata()["fingerprint"]
+		}
+	}
+	return ""
+}
+
+// {{.Name}}Recovery turns panics of handlers into Internal errors carrying
+// the fingerprint of the panic in their message and an ErrorInfo detail,
+// reporting them to Reporter when it is set.
+type {{.Name}}Recovery struct {
+	Reporter {{.Name}}ErrorReporter
+}
+
+// recover is deferred by the interceptors, with err the error they return.
+func (r *{{.Name}}Recovery) recover(ctx context.Context, method string, err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	fingerprint := {{.LowerName}}PanicFingerprint(method, p)
+	st := status.Newf(codes.Internal, "%s panicked, error ID %s", method, fingerprint)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   {{.LowerName}}PanicReason,
+		Domain:   "{{.FullName}}",
+		Metadata: map[string]string{"fingerprint": fingerprint},
+	}); err == nil {
+		st = detailed
+	}
+	*err = st.Err()
+	if r.Reporter != nil {
+		r.Reporter.Report(ctx, {{.Name}}ErrorReport{
+			Method:      method,
+			RequestID:   {{.LowerName}}RequestID(ctx),
+			Err:         *err,
+			Panic:       p,
+			Stack:       debug.Stack(),
+			Fingerprint: fingerprint,
+		})
+	}
+}
+
+// UnaryInterceptor recovers the panics of unary handlers.
+func (r *{{.Name}}Recovery) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ interface{}, err error) {
+		defer r.recover(ctx, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor recovers the panics of stream handlers.
+func (r *{{.Name}}Recovery) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer r.recover(ss.Context(), info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+// {{.Name}}Logging logs every call and reports the unexpected errors to
+// Reporter when it is set. Chain it before {{.Name}}Recovery so that
+// recovered panics are logged too
+{{- if .GenTraceHeaders }}, and after
+// {{.Name}}TracePropagation so that the lines carry trace IDs
+{{- end }}.
+type {{.Name}}Logging struct {
+	// Logger receives a line per call, the standard logger being used
+	// when nil.
+	Logger   *log.Logger
+	Reporter {{.Name}}ErrorReporter
+	{{- if .LogPayloads }}
+
+	// PayloadSampleRate is the fraction of calls, from 0 to 1, logged with
+	// their payloads, redacted of the fields marked (service_gen.sensitive).
+	// Streams are logged with the last messages received and sent.
+	PayloadSampleRate float64
+	// PayloadOnError logs the payloads of every call failing, sampled or not.
+	PayloadOnError bool
+	{{- end }}
+}
+
+// done logs a call to method that returned err after starting at start
+{{- if .LogPayloads }}, with
+// the input and output payloads when the call is sampled.
+func (l *{{.Name}}Logging) done(ctx context.Context, method string, err error, start time.Time, sampled bool, in, out interface{}) {
+{{- else }}.
+func (l *{{.Name}}Logging) done(ctx context.Context, method string, err error, start time.Time) {
+{{- end }}
+	line := fmt.Sprintf("%s %s %s", method, status.Code(err), time.Since(start))
+	id := {{.LowerName}}RequestID(ctx)
+	if id != "" {
+		line += " request_id=" + id
+	}
+	{{- if .GenTraceHeaders }}
+	if trace := {{.Name}}TraceID(ctx); trace != "" {
+		line += " trace_id=" + trace
+	}
+	{{- end }}
+	if err != nil {
+		line += " error=" + fmt.Sprintf("%q", status.Convert(err).Message())
+	}
+	fingerprint := {{.Name}}ErrorFingerprint(err)
+	if fingerprint != "" {
+		line += " fingerprint=" + fingerprint
+	}
+	{{- if .LogPayloads }}
+	if sampled || (err != nil && l.PayloadOnError) {
+		line += " input=" + {{.LowerName}}Payload(in) + " output=" + {{.LowerName}}Payload(out)
+	}
+	{{- end }}
+	if l.Logger != nil {
+		l.Logger.Println(line)
+	} else {
+		log.Println(line)
+	}
+
+	if l.Reporter != nil && {{.LowerName}}Unexpected(err) {
+		l.Reporter.Report(ctx, {{.Name}}ErrorReport{Method: method, RequestID: id, Err: err, Fingerprint: fingerprint})
+	}
+}
+
+// UnaryInterceptor logs unary calls.
+func (l *{{.Name}}Logging) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		{{- if .LogPayloads }}
+		sampled := l.sample()
+		{{- end }}
+		resp, err := handler(ctx, req)
+		{{- if .LogPayloads }}
+		// Failed calls return typed nil outputs, which are not logged.
+		var out interface{}
+		if err == nil {
+			out = resp
+		}
+		l.done(ctx, info.FullMethod, err, start, sampled, req, out)
+		{{- else }}
+		l.done(ctx, info.FullMethod, err, start)
+		{{- end }}
+		return resp, err
+	}
+}
+
+// StreamInterceptor logs stream calls once they end.
+func (l *{{.Name}}Logging) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		{{- if .LogPayloads }}
+		sampled := l.sample()
+		if !sampled && !l.PayloadOnError {
+			err := handler(srv, ss)
+			l.done(ss.Context(), info.FullMethod, err, start, false, nil, nil)
+			return err
+		}
+		ls := &{{.LowerName}}LoggedStream{ServerStream: ss}
+		err := handler(srv, ls)
+		l.done(ss.Context(), info.FullMethod, err, start, sampled, ls.in, ls.out)
+		{{- else }}
+		err := handler(srv, ss)
+		l.done(ss.Context(), info.FullMethod, err, start)
+		{{- end }}
+		return err
+	}
+}
+{{- if .LogPayloads }}
+
+// sample reports whether the payloads of a call are logged whatever its
+// outcome.
+func (l *{{.Name}}Logging) sample() bool {
+	return l.PayloadSampleRate > 0 && rand.Float64() < l.PayloadSampleRate
+}
+{{- if .GenDebugStrings }}
+
+// {{.LowerName}}Payload returns the redacted protojson of a payload, or "-"
+// when there is none.
+func {{.LowerName}}Payload(v interface{}) string {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return "-"
+	}
+	return {{.LowerName}}DebugString(m)
+}
+{{- else }}
+
+// {{.LowerName}}Payload returns the redacted text of a payload, or "-" when
+// there is none.
+func {{.LowerName}}Payload(v interface{}) string {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%q", proto.CompactTextString({{.LowerName}}Redact(m)))
+}
+{{- end }}
+
+// {{.LowerName}}LoggedStream keeps the last messages received and sent on a
+// stream.
+type {{.LowerName}}LoggedStream struct {
+	grpc.ServerStream
+	in, out interface{}
+}
+
+func (s *{{.LowerName}}LoggedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.in = m
+	}
+	return err
+}
+
+func (s *{{.LowerName}}LoggedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.out = m
+	}
+	return err
+}
+{{- end }}
+`)
+
+var errReportSentryTmpl = newTemplate("errreport-sentry", `// +build sentry
+
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+
+	"github.com/getsentry/sentry-go"
+	"golang.org/x/net/context"
+)
+
+// {{.Name}}SentryReporter reports unexpected failures to Sentry. It is a
+// reference {{.Name}}ErrorReporter, built with -tags sentry.
+type {{.Name}}SentryReporter struct {
+	// Hub sends the events. When nil, the hub of the context is used, or
+	// else sentry.CurrentHub().
+	Hub *sentry.Hub
+}
+
+// Report sends report as a Sentry event tagged with the method and the
+// request ID.
+func (r {{.Name}}SentryReporter) Report(ctx context.Context, report {{.Name}}ErrorReport) {
+	hub := r.Hub
+	if hub == nil {
+		hub = sentry.GetHubFromContext(ctx)
+	}
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("grpc.service", "{{.FullName}}")
+		scope.SetTag("grpc.method", report.Method)
+		if report.RequestID != "" {
+			scope.SetTag("request_id", report.RequestID)
+		}
+		if report.Fingerprint != "" {
+			scope.SetFingerprint([]string{report.Fingerprint})
+		}
+		if report.Panic != nil {
+			scope.SetContext("panic", sentry.Context{"stack": string(report.Stack)})
+			hub.CaptureException(fmt.Errorf("panic: %v", report.Panic))
+			return
+		}
+		hub.CaptureException(report.Err)
+	})
+}
+`)
@@ -0,0 +1,158 @@
+package generator
+
+var eventsTmpl = newTemplate("events", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}RPCEvent describes a completed call to {{.Name}}.
+type {{.Name}}RPCEvent struct {
+	// FullMethod is the full name of the method called, e.g.
+	// "/{{.FullName}}/Method".
+	FullMethod string
+	Start      time.Time
+	Duration   time.Duration
+	Code       codes.Code
+	Err        error
+	// RequestBytes and ResponseBytes are the sizes of the messages received
+	// and sent, summed over the messages of streams.
+	RequestBytes  int
+	ResponseBytes int
+	// MessagesReceived and MessagesSent count the messages of the call, one
+	// each for successful unary calls.
+	MessagesReceived int
+	MessagesSent     int
+}
+
+// {{.Name}}RPCObserver is notified of completed calls. ObserveRPC is called
+// on the goroutine of the call once its handler returns, so it should not
+// block.
+type {{.Name}}RPCObserver interface {
+	ObserveRPC(ctx context.Context, e {{.Name}}RPCEvent)
+}
+
+// {{.Name}}RPCObserverFunc adapts a func to {{.Name}}RPCObserver.
+type {{.Name}}RPCObserverFunc func(ctx context.Context, e {{.Name}}RPCEvent)
+
+// ObserveRPC calls f.
+func (f {{.Name}}RPCObserverFunc) ObserveRPC(ctx context.Context, e {{.Name}}RPCEvent) {
+	f(ctx, e)
+}
+
+// {{.Name}}Events publishes a {{.Name}}RPCEvent per call to the registered
+// observers, so that accounting can be added without another interceptor.
+type {{.Name}}Events struct {
+	mu        sync.RWMutex
+	observers []{{.Name}}RPCObserver
+}
+{{ if .GenServer }}
+// Default{{.Name}}Events is the publisher Run{{.Name}} installs.
+var Default{{.Name}}Events = &{{.Name}}Events{}
+{{ end }}
+// Register adds o to the observers of the calls.
+func (e *{{.Name}}Events) Register(o {{.Name}}RPCObserver) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers = append(e.observers, o)
+}
+
+// publish notifies the observers of ev.
+func (e *{{.Name}}Events) publish(ctx context.Context, ev {{.Name}}RPCEvent) {
+	e.mu.RLock()
+	observers := e.observers
+	e.mu.RUnlock()
+	for _, o := range observers {
+		o.ObserveRPC(ctx, ev)
+	}
+}
+
+// {{.LowerName}}Size returns the encoded size of msg, or 0 when it is not a
+// protobuf message.
+func {{.LowerName}}Size(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
+
+// UnaryInterceptor publishes an event per unary call.
+func (e *{{.Name}}Events) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		ev := {{.Name}}RPCEvent{
+			FullMethod:       info.FullMethod,
+			Start:            start,
+			Duration:         time.Since(start),
+			Code:             status.Code(err),
+			Err:              err,
+			RequestBytes:     {{.LowerName}}Size(req),
+			MessagesReceived: 1,
+		}
+		if err == nil {
+			ev.ResponseBytes, ev.MessagesSent = {{.LowerName}}Size(resp), 1
+		}
+		e.publish(ctx, ev)
+		return resp, err
+	}
+}
+
+// StreamInterceptor publishes an event per stream call.
+func (e *{{.Name}}Events) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		counted := &{{.LowerName}}CountedStream{ServerStream: ss}
+		err := handler(srv, counted)
+		e.publish(ss.Context(), {{.Name}}RPCEvent{
+			FullMethod:       info.FullMethod,
+			Start:            start,
+			Duration:         time.Since(start),
+			Code:             status.Code(err),
+			Err:              err,
+			RequestBytes:     counted.bytesIn,
+			ResponseBytes:    counted.bytesOut,
+			MessagesReceived: counted.in,
+			MessagesSent:     counted.out,
+		})
+		return err
+	}
+}
+
+// {{.LowerName}}CountedStream counts the messages going through a stream and
+// their sizes. gRPC allows a single goroutine to receive and another to send
+// at a time, and each touches counters of its own, so none are locked.
+type {{.LowerName}}CountedStream struct {
+	grpc.ServerStream
+	in, out           int
+	bytesIn, bytesOut int
+}
+
+func (s *{{.LowerName}}CountedStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.in++
+		s.bytesIn += {{.LowerName}}Size(msg)
+	}
+	return err
+}
+
+func (s *{{.LowerName}}CountedStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.out++
+		s.bytesOut += {{.LowerName}}Size(msg)
+	}
+	return err
+}
+`)
@@ -0,0 +1,35 @@
+package generator
+
+import (
+	"os"
+
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// skipExisting drops the files of services generated with SkipExisting that
+// are already present in their OutputDir. owners[i] is the owner of
+// files[i]; the kept files and their owners are returned along
+// with the names of the skipped files.
+func skipExisting(files []*plugin.CodeGeneratorResponse_File, owners []fileOwner) ([]*plugin.CodeGeneratorResponse_File, []fileOwner, []string) {
+	var (
+		keptFiles  []*plugin.CodeGeneratorResponse_File
+		keptOwners []fileOwner
+		skipped    []string
+	)
+	for i, f := range files {
+		p := owners[i]
+		if p.SkipExisting && exists(diskPath(p.OutputDir, f.GetName())) {
+			skipped = append(skipped, f.GetName())
+			continue
+		}
+		keptFiles = append(keptFiles, f)
+		keptOwners = append(keptOwners, p)
+	}
+	return keptFiles, keptOwners, skipped
+}
+
+// exists reports whether a file is present at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
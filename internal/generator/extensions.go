@@ -0,0 +1,207 @@
+package generator
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/nstogner/protoc-gen-grpc-go-service/servicegen"
+)
+
+// extensionKey identifies an option set on a descriptor.
+type extensionKey struct {
+	pb  proto.Message
+	ext *proto.ExtensionDesc
+}
+
+// extensions caches decoded option values. Templates read the same options
+// over and over, from parallel workers, and decoding them is neither cheap
+// nor safe for concurrent use on the same message.
+var extensions = struct {
+	sync.Mutex
+	values map[extensionKey]interface{}
+}{values: make(map[extensionKey]interface{})}
+
+// getExtension returns the value of ext set on pb, or nil when pb is nil or
+// the extension is unset. Descriptors without options carry typed nils.
+// Malformed options panic, which template execution turns into an error.
+func getExtension(pb proto.Message, ext *proto.ExtensionDesc) interface{} {
+	if pb == nil || reflect.ValueOf(pb).IsNil() {
+		return nil
+	}
+
+	extensions.Lock()
+	defer extensions.Unlock()
+
+	key := extensionKey{pb: pb, ext: ext}
+	if v, ok := extensions.values[key]; ok {
+		return v
+	}
+	var v interface{}
+	if proto.HasExtension(pb, ext) {
+		var err error
+		if v, err = proto.GetExtension(pb, ext); err != nil {
+			panic(errors.New("unable to read option " + ext.Name + ": " + err.Error()))
+		}
+	}
+	extensions.values[key] = v
+	return v
+}
+
+// stringExtension returns the string value of ext, or "" when unset.
+func stringExtension(pb proto.Message, ext *proto.ExtensionDesc) string {
+	if v, ok := getExtension(pb, ext).(*string); ok && v != nil {
+		return *v
+	}
+	return ""
+}
+
+// stringsExtension returns the repeated string value of ext.
+func stringsExtension(pb proto.Message, ext *proto.ExtensionDesc) []string {
+	v, _ := getExtension(pb, ext).([]string)
+	return v
+}
+
+// boolExtension returns the bool value of ext, or false when unset.
+func boolExtension(pb proto.Message, ext *proto.ExtensionDesc) bool {
+	if v, ok := getExtension(pb, ext).(*bool); ok && v != nil {
+		return *v
+	}
+	return false
+}
+
+// uint32Extension returns the uint32 value of ext, or 0 when unset.
+func uint32Extension(pb proto.Message, ext *proto.ExtensionDesc) uint32 {
+	if v, ok := getExtension(pb, ext).(*uint32); ok && v != nil {
+		return *v
+	}
+	return 0
+}
+
+// uint64Extension returns the uint64 value of ext, or 0 when unset.
+func uint64Extension(pb proto.Message, ext *proto.ExtensionDesc) uint64 {
+	if v, ok := getExtension(pb, ext).(*uint64); ok && v != nil {
+		return *v
+	}
+	return 0
+}
+
+// Idempotent reports whether the method is marked idempotent or free of
+// side effects through the standard idempotency_level option.
+func (m method) Idempotent() bool {
+	switch m.GetOptions().GetIdempotencyLevel() {
+	case descriptor.MethodOptions_IDEMPOTENT, descriptor.MethodOptions_NO_SIDE_EFFECTS:
+		return true
+	}
+	return false
+}
+
+// Timeout returns the (service_gen.timeout) option.
+func (m method) Timeout() string {
+	return stringExtension(m.GetOptions(), servicegen.E_Timeout)
+}
+
+// RetryableCodes returns the (service_gen.retryable_codes) option.
+func (m method) RetryableCodes() []string {
+	return stringsExtension(m.GetOptions(), servicegen.E_RetryableCodes)
+}
+
+// MaxAttempts returns the (service_gen.max_attempts) option.
+func (m method) MaxAttempts() uint32 {
+	return uint32Extension(m.GetOptions(), servicegen.E_MaxAttempts)
+}
+
+// Owner returns the (service_gen.owner) option, defaulting to the
+// (service_gen.service_owner) of the service.
+func (m method) Owner() string {
+	if owner := stringExtension(m.GetOptions(), servicegen.E_Owner); owner != "" {
+		return owner
+	}
+	return m.service.Owner()
+}
+
+// Owner returns the (service_gen.service_owner) option.
+func (p Service) Owner() string {
+	return stringExtension(p.GetOptions(), servicegen.E_ServiceOwner)
+}
+
+// WorkerSpecs returns the (service_gen.workers) option.
+func (p Service) WorkerSpecs() []string {
+	return stringsExtension(p.GetOptions(), servicegen.E_Workers)
+}
+
+// LazyDepSpecs returns the (service_gen.lazy_deps) option.
+func (p Service) LazyDepSpecs() []string {
+	return stringsExtension(p.GetOptions(), servicegen.E_LazyDeps)
+}
+
+// FeatureFlag returns the (service_gen.feature_flag) option.
+func (m method) FeatureFlag() string {
+	return stringExtension(m.GetOptions(), servicegen.E_FeatureFlag)
+}
+
+// TenantRequired returns the (service_gen.tenant_required) option.
+func (m method) TenantRequired() bool {
+	return boolExtension(m.GetOptions(), servicegen.E_TenantRequired)
+}
+
+// MaxRequestBytes returns the (service_gen.max_request_bytes) option.
+func (m method) MaxRequestBytes() uint64 {
+	return uint64Extension(m.GetOptions(), servicegen.E_MaxRequestBytes)
+}
+
+// HeartbeatInterval returns the (service_gen.heartbeat_interval) option.
+func (m method) HeartbeatInterval() string {
+	return stringExtension(m.GetOptions(), servicegen.E_HeartbeatInterval)
+}
+
+// ResumeTokenField returns the (service_gen.resume_token_field) option.
+func (m method) ResumeTokenField() string {
+	return stringExtension(m.GetOptions(), servicegen.E_ResumeTokenField)
+}
+
+// Downstream returns the (service_gen.downstream) option.
+func (m method) Downstream() []string {
+	return stringsExtension(m.GetOptions(), servicegen.E_Downstream)
+}
+
+// RequiredRoles returns the (service_gen.required_roles) option.
+func (m method) RequiredRoles() []string {
+	return stringsExtension(m.GetOptions(), servicegen.E_RequiredRoles)
+}
+
+// Sunset returns the (service_gen.sunset) option.
+func (m method) Sunset() string {
+	return stringExtension(m.GetOptions(), servicegen.E_Sunset)
+}
+
+// BaggageFields returns the (service_gen.baggage_fields) option.
+func (m method) BaggageFields() []string {
+	return stringsExtension(m.GetOptions(), servicegen.E_BaggageFields)
+}
+
+// CacheControl returns the (service_gen.cache_control) option.
+func (m method) CacheControl() string {
+	return stringExtension(m.GetOptions(), servicegen.E_CacheControl)
+}
+
+// RateLimit returns the (service_gen.rate_limit) option.
+func (m method) RateLimit() string {
+	return stringExtension(m.GetOptions(), servicegen.E_RateLimit)
+}
+
+// DedupeBy returns the (service_gen.dedupe_by) option.
+func (m method) DedupeBy() string {
+	return stringExtension(m.GetOptions(), servicegen.E_DedupeBy)
+}
+
+// DedupeWindow returns the (service_gen.dedupe_window) option, defaulting
+// to 10000.
+func (m method) DedupeWindow() uint32 {
+	if n := uint32Extension(m.GetOptions(), servicegen.E_DedupeWindow); n > 0 {
+		return n
+	}
+	return 10000
+}
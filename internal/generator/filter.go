@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// listParams are the parameters taking lists. Their items are separated by
+// commas like the parameters themselves, e.g. services=A,B,gen_client=true.
+var listParams = map[string]bool{
+	"services":    true,
+	"methods":     true,
+	"env_presets": true,
+}
+
+// rawParams are the parameters whose values are taken as written rather
+// than URL decoded, so that e.g. todo_format=TODO(%s): %s keeps its verbs.
+// Like lists, their values extend over the commas not followed by another
+// parameter.
+var rawParams = map[string]bool{
+	"todo_format": true,
+}
+
+// joinLists turns the comma separated parameter string into a query string,
+// keeping the items following a list parameter in its value and escaping
+// the values of raw parameters.
+func joinLists(parameter string) string {
+	var parts []string
+	// last is the name of the last parameter when its value extends over
+	// the following commas.
+	last := ""
+	for _, part := range strings.Split(parameter, ",") {
+		if last != "" && !strings.Contains(part, "=") {
+			if rawParams[last] {
+				parts[len(parts)-1] += url.QueryEscape("," + part)
+			} else {
+				parts[len(parts)-1] += "%2C" + part
+			}
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		last = ""
+		if listParams[kv[0]] || rawParams[kv[0]] {
+			last = kv[0]
+		}
+		if rawParams[kv[0]] && len(kv) == 2 {
+			part = kv[0] + "=" + url.QueryEscape(kv[1])
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "&")
+}
+
+// filter selects the services and methods to generate, so that large proto
+// files can be scaffolded a few services at a time. It lets everything
+// through when empty.
+type filter struct {
+	// services are names or full names of services.
+	services []string
+	// methods are path.Match patterns of Service.Method names, e.g.
+	// StoreService.Get*. Services without matching methods are skipped.
+	methods []string
+
+	matched map[string]bool
+}
+
+// parseFilter reads the services and methods parameters.
+func parseFilter(param url.Values) (*filter, error) {
+	f := &filter{matched: make(map[string]bool)}
+	f.services = splitList(param["services"])
+	f.methods = splitList(param["methods"])
+	for _, m := range f.methods {
+		if _, err := path.Match(m, ""); err != nil {
+			return nil, errors.New("invalid value for methods: " + m)
+		}
+	}
+	return f, nil
+}
+
+// splitList returns the comma separated items of vs.
+func splitList(vs []string) []string {
+	var items []string
+	for _, v := range vs {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// service reports whether the service name, declared in package pkg, is
+// generated.
+func (f *filter) service(pkg, name string) bool {
+	if len(f.services) == 0 {
+		return true
+	}
+	full := name
+	if pkg != "" {
+		full = pkg + "." + name
+	}
+	for _, s := range f.services {
+		if s == name || s == full {
+			f.matched[s] = true
+			return true
+		}
+	}
+	return false
+}
+
+// method reports whether the method of service is scaffolded.
+func (f *filter) method(service, method string) bool {
+	if len(f.methods) == 0 {
+		return true
+	}
+	for _, m := range f.methods {
+		if ok, _ := path.Match(m, service+"."+method); ok {
+			f.matched[m] = true
+			return true
+		}
+	}
+	return false
+}
+
+// warnUnmatched warns about the services and patterns that selected
+// nothing, which are likely typos.
+func (f *filter) warnUnmatched() {
+	for _, s := range f.services {
+		if !f.matched[s] {
+			warnf("services: no service named %s", s)
+		}
+	}
+	for _, m := range f.methods {
+		if !f.matched[m] {
+			warnf("methods: no method matches %s", m)
+		}
+	}
+}
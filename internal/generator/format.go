@@ -0,0 +1,115 @@
+package generator
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// formatSource removes the unused imports of a generated Go file and
+// go-fmts it. Templates can thus import everything a file may need and
+// leave it to this step to drop what the enabled options did not use.
+// names maps the import paths whose package name cannot be told from the
+// path to that name, see importNames. Imports are removed in place, so src
+// is clobbered.
+func formatSource(src []byte, names map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	// Drop the lines of unused imports, last first to keep the offsets of
+	// the earlier ones valid.
+	out := src
+	for i := len(f.Imports) - 1; i >= 0; i-- {
+		imp := f.Imports[i]
+		if isUsed(imp, used, names) {
+			continue
+		}
+		from := fset.Position(imp.Pos()).Offset
+		to := fset.Position(imp.End()).Offset
+		for from > 0 && out[from-1] != '\n' {
+			from--
+		}
+		for to < len(out) && out[to] != '\n' {
+			to++
+		}
+		if to < len(out) {
+			to++
+		}
+		out = out[:from+copy(out[from:], out[to:])]
+	}
+	return format.Source(out)
+}
+
+// isUsed reports whether the file refers to the import. Blank and dot
+// imports are always considered used.
+func isUsed(imp *ast.ImportSpec, used map[string]bool, names map[string]string) bool {
+	if imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".") {
+		return true
+	}
+	return used[importName(imp, names)]
+}
+
+// importName returns the name a file refers to an import by: its explicit
+// name, its name in names, or the name goimports would assume from the
+// path.
+func importName(imp *ast.ImportSpec, names map[string]string) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	p, err := strconv.Unquote(imp.Path.Value)
+	if err != nil {
+		return ""
+	}
+	if name, ok := names[p]; ok {
+		return name
+	}
+
+	base := path.Base(p)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil && path.Dir(p) != "." {
+			base = path.Base(path.Dir(p))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// importNames returns the package names of the imports of the service that
+// cannot be told from their path: the templates refer to GoImport as
+// GoPrefix, whatever its last element, e.g. storev1 for
+// "example.com/gen/store/v1".
+func (p Service) importNames() map[string]string {
+	spec := strings.TrimSpace(p.GoImport)
+	if i := strings.IndexByte(spec, '"'); i > 0 {
+		// An explicit name is found in the import itself.
+		return nil
+	}
+	importPath, err := strconv.Unquote(spec)
+	if err != nil {
+		return nil
+	}
+	return map[string]string{importPath: p.GoPrefix}
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// fuzzSkipped reports whether a parameter string reads files, which the
+// fuzz targets leave out: a config file named e.g. /dev/zero would never
+// be read to the end.
+func fuzzSkipped(parameter string) bool {
+	for _, key := range []string{"config", "template_dir", "stubs_dir", "output_dir"} {
+		if strings.Contains(parameter, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func FuzzParseParameter(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"GoPrefix=pb,gen_client=true",
+		`GoPrefix=pb,GoImport="example.com/gen/pb",GoPackageName=services`,
+		"services=example.Store,methods=Store.Get*,Store.List",
+		"env_presets=dev,staging,prod,gen_server=true",
+		"strict=true,paths=source_relative,backup=diff",
+		"gen_client=true\r\n",
+		"GoPrefix=50%",
+		"a=b;c=d",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, parameter string) {
+		if fuzzSkipped(parameter) {
+			t.Skip()
+		}
+		param, _, err := parseParameter(parameter)
+		if err != nil {
+			return
+		}
+		if param == nil {
+			t.Fatalf("parseParameter(%q) returned no values and no error", parameter)
+		}
+		if o, err := parseOptions(param); err == nil {
+			_ = o.validate()
+		}
+	})
+}
+
+func FuzzGenerate(f *testing.F) {
+	for _, req := range []*plugin.CodeGeneratorRequest{
+		{},
+		testRequest("", testFile("echo.proto", testService("Echo",
+			testMethod("Say", false, false),
+			testMethod("Upload", true, false),
+			testMethod("Watch", false, true),
+			testMethod("Chat", true, true),
+		))),
+		testRequest("GoPrefix=pb,gen_client=true,gen_server=true", testFile("shop.proto",
+			testService("Store", testMethod("Get", false, false)),
+			testService("Cart", testMethod("Add", false, false)),
+		)),
+		testRequest("layout=handlers,constructor_style=options,paths=source_relative", testFile("a/echo.proto", testService("Echo", testMethod("Say", false, false)))),
+	} {
+		b, err := proto.Marshal(req)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var req plugin.CodeGeneratorRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			return
+		}
+		if fuzzSkipped(req.GetParameter()) {
+			t.Skip()
+		}
+		resp := generate(&req)
+		// generate turns panics into errors, which must not happen however
+		// malformed the request.
+		if strings.HasPrefix(resp.GetError(), "unable to generate: ") {
+			t.Fatal(resp.GetError())
+		}
+		for _, file := range resp.GetFile() {
+			if file.GetName() == "" {
+				t.Fatal("generated a file without a name")
+			}
+		}
+	})
+}
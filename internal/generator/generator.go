@@ -0,0 +1,746 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Service is the data provided to the template.
+type Service struct {
+	descriptor.ServiceDescriptorProto
+	ProtoName   string
+	PackageName string
+	Methods     []method
+	// scaffolded are the methods selected by the methods parameter, when
+	// set. Their implementations are generated into files of their own,
+	// while the other files of the service cover every method.
+	scaffolded []method
+	// wireName is the name of the service in its proto file, while Name is
+	// its Go name.
+	wireName string
+	// fileName is the name of the files of the service, before their suffix.
+	fileName string
+	file     *descriptor.FileDescriptorProto
+	options
+	messages messageIndex
+	enums    enumIndex
+	builders []builder
+	// serviceConfig is the rendered service config, with GenServiceConfig.
+	serviceConfig string
+}
+
+// serviceFile is a file generated once for every service.
+type serviceFile struct {
+	suffix  string
+	tmpl    *template.Template
+	enabled func(options) bool
+}
+
+// serviceFiles lists the files generated per service. Files without an
+// enabled func are always generated.
+var serviceFiles = []serviceFile{
+	{suffix: "_service.go", tmpl: tmpl},
+	{suffix: "_client.go", tmpl: clientTmpl, enabled: func(o options) bool { return o.GenClient }},
+	{suffix: "_connmanager.go", tmpl: connManagerTmpl, enabled: func(o options) bool { return o.GenConnManager }},
+	{suffix: "_service_config.go", tmpl: serviceConfigTmpl, enabled: func(o options) bool { return o.GenServiceConfig }},
+	{suffix: "_service_config.json", tmpl: serviceConfigJSONTmpl, enabled: func(o options) bool { return o.GenServiceConfig }},
+	{suffix: "_smoke/main.go", tmpl: smokeTmpl, enabled: func(o options) bool { return o.GenSmoke }},
+	{suffix: "_chaos.go", tmpl: chaosTmpl, enabled: func(o options) bool { return o.GenChaos }},
+	{suffix: "_chaos_off.go", tmpl: chaosOffTmpl, enabled: func(o options) bool { return o.GenChaos }},
+	{suffix: "_redact.go", tmpl: redactTmpl, enabled: func(o options) bool { return o.GenRecorder || o.LogPayloads || o.GenDebugStrings }},
+	{suffix: "_debug.go", tmpl: debugTmpl, enabled: func(o options) bool { return o.GenDebugStrings }},
+	{suffix: "_recorder.go", tmpl: recorderTmpl, enabled: func(o options) bool { return o.GenRecorder }},
+	{suffix: "_maintenance.go", tmpl: maintenanceTmpl, enabled: func(o options) bool { return o.GenMaintenance }},
+	{suffix: "_shadow.go", tmpl: shadowTmpl, enabled: func(o options) bool { return o.GenShadow }},
+	{suffix: "_canary.go", tmpl: canaryTmpl, enabled: func(o options) bool { return o.GenCanary }},
+	{suffix: "_abrouter.go", tmpl: abRouterTmpl, enabled: func(o options) bool { return o.GenABRouter }},
+	{suffix: "_quota.go", tmpl: quotaTmpl, enabled: func(o options) bool { return o.GenQuota }},
+	{suffix: "_tenant.go", tmpl: tenantTmpl, enabled: func(o options) bool { return o.GenTenancy }},
+	{suffix: "_validate.go", tmpl: validateTmpl, enabled: func(o options) bool { return o.GenPGV }},
+	{suffix: "_catalog.go", tmpl: catalogTmpl, enabled: func(o options) bool { return o.GenCatalog }},
+	{suffix: "_errmap.go", tmpl: errMapTmpl, enabled: func(o options) bool { return o.GenErrMap }},
+	{suffix: "_builders.go", tmpl: buildersTmpl, enabled: func(o options) bool { return o.GenBuilders && o.Support != "shared" }},
+	{suffix: "_subscribe.go", tmpl: subscribeTmpl, enabled: func(o options) bool { return o.GenSubscriptions }},
+	{suffix: "_testserver_test.go", tmpl: testServerTmpl, enabled: func(o options) bool { return o.GenTestServer }},
+	{suffix: "_ctxkeys.go", tmpl: ctxKeysTmpl, enabled: func(o options) bool { return o.GenCtxKeys }},
+	{suffix: "_errreport.go", tmpl: errReportTmpl, enabled: func(o options) bool { return o.GenErrReport }},
+	{suffix: "_errreport_sentry.go", tmpl: errReportSentryTmpl, enabled: func(o options) bool { return o.GenErrReport }},
+	{suffix: "_otel_metrics.go", tmpl: otelMetricsTmpl, enabled: func(o options) bool { return o.GenOTelMetrics }},
+	{suffix: "_stats.go", tmpl: statsTmpl, enabled: func(o options) bool { return o.GenStats }},
+	{suffix: "_stream_metrics.go", tmpl: streamMetricsTmpl, enabled: func(o options) bool { return o.GenStreamMetrics }},
+	{suffix: "_tracectx.go", tmpl: traceCtxTmpl, enabled: func(o options) bool { return o.GenTraceHeaders }},
+	{suffix: "_events.go", tmpl: eventsTmpl, enabled: func(o options) bool { return o.GenEvents }},
+	{suffix: "_buildinfo.go", tmpl: buildInfoTmpl, enabled: func(o options) bool { return o.GenBuildInfo }},
+	{suffix: "_binarylog.go", tmpl: binaryLogTmpl, enabled: func(o options) bool { return o.BinaryLog }},
+	{suffix: "_policy.go", tmpl: policyTmpl, enabled: func(o options) bool { return o.GenPolicy }},
+	{suffix: "_policy.json", tmpl: policyJSONTmpl, enabled: func(o options) bool { return o.PolicyJSON }},
+	{suffix: "_presets.go", tmpl: presetsTmpl, enabled: func(o options) bool { return len(o.EnvPresets) > 0 }},
+	{suffix: "_ops.go", tmpl: opsTmpl, enabled: func(o options) bool { return o.GenOps }},
+	{suffix: "_baggage.go", tmpl: baggageTmpl, enabled: func(o options) bool { return o.GenBaggage }},
+	{suffix: "_legacy.go", tmpl: legacyTmpl, enabled: func(o options) bool { return o.GenLegacyShims }},
+	{suffix: "_diff.go", tmpl: diffTmpl, enabled: func(o options) bool { return o.GenUpdateDiff }},
+	{suffix: "_apiindex.go", tmpl: apiIndexTmpl, enabled: func(o options) bool { return o.GenAPIIndex }},
+	{suffix: "_limits.go", tmpl: limitsTmpl, enabled: func(o options) bool { return o.GenLimits }},
+	{suffix: "_limits.json", tmpl: limitsJSONTmpl, enabled: func(o options) bool { return o.LimitsJSON }},
+	{suffix: "_health.go", tmpl: depHealthFileTmpl, enabled: func(o options) bool { return o.GenDepHealth }},
+	{suffix: "_interceptors_test.go", tmpl: interceptorTestsTmpl, enabled: func(o options) bool {
+		return o.GenInterceptorTests && (o.GenErrReport || o.GenQuota || o.GenTenancy || o.GenMaintenance)
+	}},
+	{suffix: "_banner.go", tmpl: bannerTmpl, enabled: func(o options) bool { return o.GenStartupBanner }},
+	{suffix: "_lifecycle.go", tmpl: lifecycleTmpl, enabled: func(o options) bool { return o.GenLifecycle }},
+	{suffix: "_server.go", tmpl: serverTmpl, enabled: func(o options) bool { return o.GenServer }},
+}
+
+type method struct {
+	descriptor.MethodDescriptorProto
+	// wireName is the name of the method in its proto file, while Name is
+	// its Go name.
+	wireName    string
+	serviceName string
+	messages    messageIndex
+	service     *Service
+}
+
+// The following methods are used by the template.
+func (m method) TrimmedInput() string {
+	return strings.TrimPrefix(m.GetInputType(), ".")
+}
+func (m method) TrimmedOutput() string {
+	return strings.TrimPrefix(m.GetOutputType(), ".")
+}
+func (m method) Service() *Service {
+	return m.service
+}
+func (m method) InputGoName() string {
+	return m.messages.goName(m.GetInputType())
+}
+func (m method) OutputGoName() string {
+	return m.messages.goName(m.GetOutputType())
+}
+func (m method) Ctx() string {
+	if m.GetClientStreaming() || m.GetServerStreaming() {
+		return "stream.Context()"
+	}
+	return "ctx"
+}
+func (m method) Return(err string) string {
+	if m.GetClientStreaming() || m.GetServerStreaming() {
+		return "return " + err
+	}
+	return "return nil, " + err
+}
+func (m method) Params() string {
+	input := "input *" + m.service.GoPrefix + "." + m.InputGoName()
+	stream := "stream " + m.service.GoPrefix + "." + m.StreamName()
+	switch {
+	case m.GetClientStreaming():
+		return stream
+	case m.GetServerStreaming():
+		return input + ", " + stream
+	default:
+		return "ctx context.Context, " + input
+	}
+}
+func (m method) Args() string {
+	switch {
+	case m.GetClientStreaming():
+		return "stream"
+	case m.GetServerStreaming():
+		return "input, stream"
+	default:
+		return "ctx, input"
+	}
+}
+func (m method) Results() string {
+	streaming := m.GetClientStreaming() || m.GetServerStreaming()
+	output := "*" + m.service.GoPrefix + "." + m.OutputGoName()
+	switch {
+	case m.service.GenErrMap && streaming:
+		return "(err error)"
+	case m.service.GenErrMap:
+		return "(_ " + output + ", err error)"
+	case streaming:
+		return "error"
+	default:
+		return "(" + output + ", error)"
+	}
+}
+func (m method) NewOutput() string {
+	if msg, ok := m.messages[m.GetOutputType()]; ok && m.service.GenBuilders && msg.Package == m.service.PackageName {
+		return "New" + msg.GoName + "()"
+	}
+	return "&" + m.service.GoPrefix + "." + m.OutputGoName() + "{}"
+}
+func (m method) StreamName() string {
+	return fmt.Sprintf("%s_%sServer", m.serviceName, m.GetName())
+}
+func (m method) ClientStreamName() string {
+	return fmt.Sprintf("%s_%sClient", m.serviceName, m.GetName())
+}
+func (p Service) LowerName() string {
+	return lowerFirst(p.GetName())
+}
+
+// MethodFiles reports whether the methods parameter is set, in which case
+// the implementation of each method it selects is generated into a file of
+// its own. Every run then writes the same <service>_service.go, however the
+// methods are split across runs.
+func (p Service) MethodFiles() bool {
+	return p.scaffolded != nil
+}
+
+// EmbedsUnimplemented reports whether the service struct embeds the stubs
+// of the methods not generated yet, so that it implements the server
+// interface with MethodFiles. The router of the handlers layout fails the
+// methods without a handler instead.
+func (p Service) EmbedsUnimplemented() bool {
+	return p.MethodFiles() && p.Layout != "handlers"
+}
+
+// fileName returns the name of the file holding the implementation of m,
+// with MethodFiles.
+func (m method) fileName() string {
+	return m.service.fileName + "_" + strings.ToLower(envName(m.GetName())) + "_method.go"
+}
+func (p Service) HasFeatureFlags() bool {
+	for _, m := range p.Methods {
+		if m.FeatureFlag() != "" {
+			return true
+		}
+	}
+	return false
+}
+func (p Service) EnvPrefix() string {
+	return envName(p.GetName())
+}
+func (p Service) ConfigKey() string {
+	return strings.ToLower(envName(p.GetName()))
+}
+func (p Service) FullName() string {
+	if p.PackageName == "" {
+		return p.wireName
+	}
+	return p.PackageName + "." + p.wireName
+}
+func (m method) WireName() string {
+	return m.wireName
+}
+
+// envName converts a CamelCase name to UPPER_SNAKE_CASE for use in
+// environment variable names.
+func envName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) && i > 0 && !unicode.IsUpper(rune(s[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// lowerFirst lower cases the first letter of s, turning an exported Go
+// identifier into an unexported one.
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+var tmpl = newTemplate("server", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+{{block "imports" .}}
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	{{.GoImport}}
+)
+{{end}}
+
+{{ if .HasFeatureFlags }}
+// {{.Name}}FlagProvider reports whether feature flags are on. It gates the
+// methods annotated with (service_gen.feature_flag).
+type {{.Name}}FlagProvider interface {
+	Enabled(ctx context.Context, flag string) bool
+}
+{{ end }}
+{{ if or .Deps .EmbedsUnimplemented }}
+type {{$.Name}}Service struct {
+	{{- if .EmbedsUnimplemented }}
+	{{.LowerName}}Unimplemented
+	{{- end }}
+	{{- range .Deps }}
+	// {{.Doc}}
+	{{.Name}} {{.Type}}
+	{{- end }}
+}
+{{ else }}
+type {{$.Name}}Service struct{}
+{{ end }}
+{{ if .HasFeatureFlags }}
+// flagEnabled reports whether the feature flag is on.
+func (s {{.Name}}Service) flagEnabled(ctx context.Context, flag string) bool {
+	return s.Flags != nil && s.Flags.Enabled(ctx, flag)
+}
+{{ end }}
+{{- if eq .ConstructorStyle "deps" }}
+// {{.Name}}Deps are the dependencies of {{.Name}}Service.
+type {{.Name}}Deps struct {
+	{{- range .Deps }}
+	// {{.Doc}}
+	{{.Name}} {{.Type}}
+	{{- end }}
+}
+
+// New{{.Name}}Service builds the service from deps, defaulting the unset
+// ones.
+func New{{.Name}}Service(deps {{.Name}}Deps) {{.Name}}Service {
+	s := {{.Name}}Service{
+		{{- range .Deps }}
+		{{.Name}}: deps.{{.Name}},
+		{{- end }}
+	}
+	{{- template "dep_defaults" . }}
+	return s
+}
+{{ else if eq .ConstructorStyle "options" }}
+// {{.Name}}Option sets a dependency of the service built by
+// New{{.Name}}Service.
+type {{.Name}}Option func(*{{.Name}}Service)
+{{ range .Deps }}
+// With{{$.Name}}{{.Name}} sets the {{.Name}} dependency.
+func With{{$.Name}}{{.Name}}(v {{.Type}}) {{$.Name}}Option {
+	return func(s *{{$.Name}}Service) { s.{{.Name}} = v }
+}
+{{ end }}
+// New{{.Name}}Service builds the service, applying opts in order and
+// defaulting the dependencies they leave unset.
+func New{{.Name}}Service(opts ...{{.Name}}Option) {{.Name}}Service {
+	var s {{.Name}}Service
+	for _, opt := range opts {
+		opt(&s)
+	}
+	{{- template "dep_defaults" . }}
+	return s
+}
+{{ end }}
+{{ if .Reload }}
+// Reload applies the reloadable settings of cfg. Run{{.Name}} calls it at
+// startup and whenever the configuration is reloaded, possibly while calls
+// are being served.
+func (s {{.Name}}Service) Reload(cfg {{.Name}}Config) error {
+	// {{.TodoNote "Apply the log level, rate limit and feature toggles of cfg"}}
+	_ = cfg
+	return nil
+}
+{{ end }}
+{{ if .HasHeartbeats }}
+{{- template "heartbeat_sender" . }}
+{{ end }}
+{{ if .HasDownstream }}
+{{- template "deadline_helper" . }}
+{{ end }}
+{{ if .HasSagas }}
+{{- template "saga_runner" . }}
+{{ end }}
+{{ if .HasSunsets }}
+{{- template "sunset_interceptors" . }}
+{{ end }}
+{{ if .HasCacheControl }}
+{{- template "cache_interceptor" . }}
+{{ end }}
+{{ if .GenDepHealth }}
+{{- template "dep_checks" . }}
+{{ end }}
+{{ if .HasDedupe }}
+{{- template "dedupe_window" . }}
+{{ end }}
+{{ if .HasWorkers }}
+{{- template "workers" . }}
+{{ end }}
+{{ if .HasLazyDeps }}
+{{- template "lazy_deps" . }}
+{{ end }}
+
+{{ if .EmbedsUnimplemented }}
+{{- template "unimplemented_methods" . }}
+{{ end }}
+{{ if eq .Layout "handlers" }}
+{{- template "handlers" . }}
+{{ else if not .MethodFiles }}
+{{- template "methods" . }}
+{{ end }}
+{{- if .GenNormalize }}
+{{ template "normalizers" . }}
+{{- end }}
+
+{{- define "method_file" }}
+{{- template "header" . }}
+
+package {{.GoPackageName}}
+
+{{ template "imports" . }}
+{{ if eq .Layout "handlers" }}
+{{- template "handler_funcs" . }}
+{{ else }}
+{{- template "methods" . }}
+{{ end }}
+{{- end }}
+
+{{- define "methods" }}
+{{ range .Methods }}
+	{{ if or .GetClientStreaming .GetServerStreaming }}
+		{{ block "stream_body" . }}
+			{{ if .GetClientStreaming }}
+				{{ if .GetServerStreaming }}
+// {{.Name}} streams outputs and listens to a stream of inputs.
+func (s {{.Service.Name}}Service) {{.Name}}(stream {{.Service.GoPrefix}}.{{.StreamName}}) {{.Results}} {
+	{{- template "bidi_impl" . }}
+}
+				{{ else }}
+// {{.Name}} sends a single output for a streamed input.
+func (s {{.Service.Name}}Service) {{.Name}}(stream {{.Service.GoPrefix}}.{{.StreamName}}) {{.Results}} {
+	{{- template "client_stream_impl" . }}
+}
+				{{ end }}
+			{{ else }}
+// {{.Name}} streams output for a single input.
+func (s {{.Service.Name}}Service) {{.Name}}(input *{{.Service.GoPrefix}}.{{.InputGoName}}, stream {{.Service.GoPrefix}}.{{.StreamName}}) {{.Results}} {
+	{{- template "server_stream_impl" . }}
+}
+			{{ end }}
+		{{ end }}
+	{{ else }}
+		{{ block "unary_body" . }}
+// {{.Name}} sends a single output for a single input.
+func (s {{.Service.Name}}Service) {{.Name}}(ctx context.Context, input *{{.Service.GoPrefix}}.{{.InputGoName}}) {{.Results}} {
+	{{- template "unary_impl" . }}
+}
+		{{ end }}
+	{{ end }}
+
+{{ end }}
+{{- end }}
+
+{{- define "unimplemented_methods" }}
+// {{.LowerName}}Unimplemented fails the methods not generated yet with
+// Unimplemented. The methods generated into files of their own shadow
+// its methods.
+type {{.LowerName}}Unimplemented struct{}
+{{ range .Methods }}
+func ({{$.LowerName}}Unimplemented) {{.Name}}({{.Params}}) {{.Results}} {
+	{{.Return (printf "status.Error(codes.Unimplemented, %q)" (printf "%s is not implemented" .GetName))}}
+}
+{{ end }}
+{{- end }}
+
+{{- define "dep_defaults" }}
+	{{- range .Deps }}
+	{{- if .Default }}
+	if s.{{.Name}} == nil {
+		s.{{.Name}} = {{.Default}}
+	}
+	{{- end }}
+	{{- end }}
+{{- end }}
+
+{{- define "handlers" }}
+// {{.Name}}Router implements {{.GoPrefix}}.{{.Name}}Server by calling a
+// handler func per method, so that methods can be implemented in files of
+// their own. Methods without a handler fail with Unimplemented.
+type {{.Name}}Router struct {
+	{{- range .Methods }}
+	{{.Name}}Func {{$.Name}}{{.Name}}Func
+	{{- end }}
+}
+
+{{- if .MethodFiles }}
+// {{.LowerName}}Routes set the handlers generated so far, each registered
+// by the file of its method.
+var {{.LowerName}}Routes []func(r *{{.Name}}Router, s {{.Name}}Service)
+
+// New{{.Name}}Router routes the methods generated so far to their handlers,
+// which close over s.
+func New{{.Name}}Router(s {{.Name}}Service) *{{.Name}}Router {
+	r := &{{.Name}}Router{}
+	for _, route := range {{.LowerName}}Routes {
+		route(r, s)
+	}
+	return r
+}
+{{- else }}
+// New{{.Name}}Router routes every method to the handler generated for it,
+// which closes over s.
+func New{{.Name}}Router(s {{.Name}}Service) *{{.Name}}Router {
+	return &{{.Name}}Router{
+		{{- range .Methods }}
+		{{.Name}}Func: New{{$.Name}}{{.Name}}Handler(s),
+		{{- end }}
+	}
+}
+{{- end }}
+{{ range .Methods }}
+// {{.Name}} calls the {{.Name}}Func handler.
+func (r *{{.Service.Name}}Router) {{.Name}}({{.Params}}) {{.Results}} {
+	if r.{{.Name}}Func == nil {
+		{{.Return (.Status "Unimplemented" "no_handler" (printf "status.Error(codes.Unimplemented, %q)" (printf "%s has no handler" .GetName)))}}
+	}
+	return r.{{.Name}}Func({{.Args}})
+}
+{{ end }}
+{{- range .Methods }}
+// {{.Service.Name}}{{.Name}}Func handles {{.Name}} calls.
+type {{.Service.Name}}{{.Name}}Func func({{.Params}}) {{.Results}}
+{{ end }}
+{{- if not .MethodFiles }}
+{{- template "handler_funcs" . }}
+{{- end }}
+{{- end }}
+
+{{- define "handler_funcs" }}
+{{- range .Methods }}
+{{- if .Service.MethodFiles }}
+func init() {
+	{{.Service.LowerName}}Routes = append({{.Service.LowerName}}Routes, func(r *{{.Service.Name}}Router, s {{.Service.Name}}Service) {
+		r.{{.Name}}Func = New{{.Service.Name}}{{.Name}}Handler(s)
+	})
+}
+{{ end }}
+// New{{.Service.Name}}{{.Name}}Handler returns the handler of {{.Name}} calls.
+func New{{.Service.Name}}{{.Name}}Handler(s {{.Service.Name}}Service) {{.Service.Name}}{{.Name}}Func {
+	return func({{.Params}}) {{.Results}} {
+		{{- if .GetClientStreaming }}
+		{{- if .GetServerStreaming }}
+		{{- template "bidi_impl" . }}
+		{{- else }}
+		{{- template "client_stream_impl" . }}
+		{{- end }}
+		{{- else if .GetServerStreaming }}
+		{{- template "server_stream_impl" . }}
+		{{- else }}
+		{{- template "unary_impl" . }}
+		{{- end }}
+	}
+}
+{{ end }}
+{{- end }}
+
+{{- define "bidi_impl" }}
+	{{- template "guards" . }}
+	{{- if eq .Service.StubBehavior "unimplemented" }}
+	{{- template "unimplemented" . }}
+	{{- else }}
+	{{- template "heartbeat" . }}
+	{{- template "downstream" . }}
+	{{- if .MaxRequestBytes }}
+	received := 0
+	{{- end }}
+	{{- template "dedupe_start" . }}
+	for {
+		input, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		{{- if .MaxRequestBytes }}
+		if received += proto.Size(input); received > {{.MaxRequestBytes}} {
+			{{.Return (.Status "ResourceExhausted" "inputs_too_large" (printf "status.Errorf(codes.ResourceExhausted, %q, received)" (printf "%s inputs of %%d bytes exceed the limit of %d bytes" .GetName .MaxRequestBytes)) "received" (print .MaxRequestBytes))}}
+		}
+		{{- end }}
+		{{- template "normalize" . }}
+		{{- template "validate" . }}
+		{{- template "dedupe" . }}
+
+		// {{.TodoNote "Do something with input"}}
+		_ = input
+		{{- template "input_switches" . }}
+		{{- template "error_example" . }}
+		{{- template "saga" . }}
+
+		// {{.TodoNote "Stream some meaningful output"}}
+		if err := {{.Send}}({{.NewOutput}}); err != nil {
+			return err
+		}
+		{{- template "dedupe_mark" . }}
+	}
+
+	return nil
+	{{- end }}
+{{- end }}
+
+{{- define "client_stream_impl" }}
+	{{- template "guards" . }}
+	{{- if eq .Service.StubBehavior "unimplemented" }}
+	{{- template "unimplemented" . }}
+	{{- else }}
+	{{- template "downstream" . }}
+	{{- if .MaxRequestBytes }}
+	received := 0
+	{{- end }}
+	{{- template "dedupe_start" . }}
+	for {
+		input, err := stream.Recv()
+		if err == io.EOF {
+			{{- template "saga" . }}
+			// {{.TodoNote "Send some meaningful output"}}
+			return stream.SendAndClose({{.NewOutput}})
+		}
+		if err != nil {
+			return err
+		}
+		{{- if .MaxRequestBytes }}
+		if received += proto.Size(input); received > {{.MaxRequestBytes}} {
+			{{.Return (.Status "ResourceExhausted" "inputs_too_large" (printf "status.Errorf(codes.ResourceExhausted, %q, received)" (printf "%s inputs of %%d bytes exceed the limit of %d bytes" .GetName .MaxRequestBytes)) "received" (print .MaxRequestBytes))}}
+		}
+		{{- end }}
+		{{- template "normalize" . }}
+		{{- template "validate" . }}
+		{{- template "dedupe" . }}
+
+		// {{.TodoNote "Do something with the input message"}}
+		_ = input
+		{{- template "input_switches" . }}
+		{{- template "error_example" . }}
+		{{- template "dedupe_mark" . }}
+	}
+
+	return nil
+	{{- end }}
+{{- end }}
+
+{{- define "server_stream_impl" }}
+	{{- template "guards" . }}
+	{{- if eq .Service.StubBehavior "unimplemented" }}
+	{{- template "unimplemented" . }}
+	{{- else }}
+	{{- template "heartbeat" . }}
+	{{- template "downstream" . }}
+	// {{.TodoNote "Do something with the input"}}
+	_ = input
+	{{- template "input_switches" . }}
+	{{- template "error_example" . }}
+	{{- template "saga" . }}
+
+	// {{.TodoNote "Stream some meaningful output"}}
+	for i := 0; i < 10; i++ {
+		if err := {{.Send}}({{.NewOutput}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+	{{- end }}
+{{- end }}
+
+{{- define "unary_impl" }}
+	{{- template "guards" . }}
+	{{- if eq .Service.StubBehavior "unimplemented" }}
+	{{- template "unimplemented" . }}
+	{{- else }}
+	{{- template "downstream" . }}
+	// {{.TodoNote "Do something with the input"}}
+	_ = input
+	{{- template "input_switches" . }}
+	{{- template "update_diff" . }}
+	{{- template "error_example" . }}
+	{{- template "saga" . }}
+
+	// {{.TodoNote "Send some meaningful output"}}
+	return {{.NewOutput}}, nil
+	{{- end }}
+{{- end }}
+
+{{- define "unimplemented" }}
+	// {{.TodoNote (printf "Implement %s" .GetName)}}
+	{{- template "error_example" . }}
+	{{- if .Service.ErrStyle }}
+	{{ end }}
+	{{.Return (.Status "Unimplemented" "not_implemented" (printf "status.Error(codes.Unimplemented, %q)" (printf "%s is not implemented" .GetName)))}}
+{{- end }}
+
+{{- define "input_switches" }}
+	{{- range .InputSwitches }}
+
+	switch {{.Expr}} {
+	{{- range .Cases }}
+	{{ if eq .Expr "default" }}default:{{ else }}case {{.Expr}}:{{ end }}
+		// {{$.TodoNote .Note}}
+	{{- end }}
+	}
+	{{- end }}
+{{- end }}
+
+{{- define "error_example" }}
+	{{- if eq .Service.ErrStyle "status" }}
+
+	// {{.TodoNote "Report failures as status errors, e.g."}}
+	//	{{.Return "status.Errorf(codes.NotFound, \"%v not found\", id)"}}
+	{{- else if eq .Service.ErrStyle "wrapped" }}
+
+	// {{.TodoNote (printf "Wrap internal failures, %sStatus maps" .Service.LowerName)}}
+	// them to status codes, e.g.
+	//	{{.Return "fmt.Errorf(\"unable to load %v: %w\", id, err)"}}
+	{{- end }}
+{{- end }}
+
+{{- define "guards" }}
+	{{- if .Service.GenErrMap }}
+	defer func() { err = {{.Service.LowerName}}Status(err) }()
+	{{ end }}
+	{{- if .FeatureFlag }}
+	if !s.flagEnabled({{.Ctx}}, "{{.FeatureFlag}}") {
+		{{.Return (.Status .Service.FeatureFlagCode "not_enabled" (printf "status.Error(codes.%s, %q)" .Service.FeatureFlagCode (printf "%s is not enabled" .GetName)))}}
+	}
+	{{ end }}
+	{{- if and .MaxRequestBytes (not .GetClientStreaming) }}
+	if size := proto.Size(input); size > {{.MaxRequestBytes}} {
+		{{.Return (.Status "ResourceExhausted" "input_too_large" (printf "status.Errorf(codes.ResourceExhausted, %q, size)" (printf "%s input of %%d bytes exceeds the limit of %d bytes" .GetName .MaxRequestBytes)) "size" (print .MaxRequestBytes))}}
+	}
+	{{ end }}
+	{{- if and .Service.GenNormalize (not .GetClientStreaming) }}
+	{{- template "normalize" . }}
+	{{ end }}
+	{{- if and .Validated (not .GetClientStreaming) }}
+	{{- template "validate" . }}
+	{{ end }}
+{{- end }}
+
+{{- define "validate" }}
+	{{- if .Validated }}
+	if err := {{.Service.LowerName}}Validate(input); err != nil {
+		{{.Return "err"}}
+	}
+	{{- end }}
+{{- end }}
+`+heartbeatTmpl+deadlineTmpl+sagaTmpl+sunsetTmpl+cacheTmpl+depHealthTmpl+normalizeTmpl+updateDiffTmpl+workersTmpl+dedupeTmpl+lazyDepsTmpl)
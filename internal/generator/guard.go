@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// registrationGuard is the registration guard of the services generated
+// into a Go package.
+type registrationGuard struct {
+	GoPackageName string
+	Services      []*Service
+}
+
+// registrationGuards renders the registration guard of every output
+// directory holding services generated with gen_registration_guard, along
+// with the service owning each file.
+func registrationGuards(ps []*Service) ([]*plugin.CodeGeneratorResponse_File, []fileOwner, error) {
+	guards := make(map[string]*registrationGuard)
+	var dirs []string
+	for _, p := range ps {
+		if !p.GenRegistrationGuard {
+			continue
+		}
+		dir := path.Dir(p.fileName)
+		g, ok := guards[dir]
+		if !ok {
+			g = &registrationGuard{GoPackageName: p.GoPackageName}
+			guards[dir] = g
+			dirs = append(dirs, dir)
+		}
+		g.Services = append(g.Services, p)
+	}
+	sort.Strings(dirs)
+
+	var (
+		files  []*plugin.CodeGeneratorResponse_File
+		owners []fileOwner
+	)
+	for _, dir := range dirs {
+		g := guards[dir]
+		sort.Slice(g.Services, func(i, j int) bool { return g.Services[i].FullName() < g.Services[j].FullName() })
+		var w bytes.Buffer
+		if err := registrationGuardTmpl.Execute(&w, g); err != nil {
+			return nil, nil, errors.New("unable to execute template: " + err.Error())
+		}
+		content, err := formatSource(w.Bytes(), nil)
+		if err != nil {
+			return nil, nil, errors.New("unable to go-fmt output: " + err.Error())
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(dir, "registration_guard.go")),
+			Content: proto.String(string(content)),
+		})
+		owner := fileOwner{Service: g.Services[0]}
+		for _, p := range g.Services {
+			owner.covers = append(owner.covers, coverage{service: p})
+		}
+		owners = append(owners, owner)
+	}
+	return files, owners, nil
+}
+
+// registrationGuardTmpl renders a registrationGuard. Its header lists the
+// proto files of the services instead of the owners of a single one.
+var registrationGuardTmpl = newTemplate("registration-guard", `
+// Code initially generated by protoc-gen-grpc-go-service
+// source: {{ range $i, $p := .Services }}{{ if $i }}, {{ end }}{{ $p.ProtoName }}{{ end }}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// GeneratedServices are the full names of the services generated into this
+// package.
+var GeneratedServices = []string{
+	{{- range .Services }}
+	"{{.FullName}}",
+	{{- end }}
+}
+
+// CheckServicesRegistered returns an error naming the GeneratedServices s
+// does not serve. Call it once the server is assembled, from an init-time
+// assertion or a test, so that a service added to the protos but not wired
+// into the server fails fast.
+func CheckServicesRegistered(s interface {
+	GetServiceInfo() map[string]grpc.ServiceInfo
+}) error {
+	info := s.GetServiceInfo()
+	var missing []string
+	for _, name := range GeneratedServices {
+		if _, ok := info[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("services not registered: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+`)
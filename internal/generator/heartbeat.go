@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// HasHeartbeats reports whether any method of the service sends heartbeats.
+func (p Service) HasHeartbeats() bool {
+	for _, m := range p.Methods {
+		if m.LongLived() {
+			return true
+		}
+	}
+	return false
+}
+
+// LongLived reports whether the method streams outputs and has a
+// (service_gen.heartbeat_interval).
+func (m method) LongLived() bool {
+	return m.GetServerStreaming() && m.HeartbeatInterval() != ""
+}
+
+// Heartbeat returns the Go expression of the heartbeat interval.
+func (m method) Heartbeat() (string, error) {
+	d, err := time.ParseDuration(m.HeartbeatInterval())
+	if err != nil {
+		return "", errors.New("invalid heartbeat_interval option on " + m.GetName() + ": " + err.Error())
+	}
+	if d <= 0 {
+		return "", errors.New("invalid heartbeat_interval option on " + m.GetName() + ": must be positive")
+	}
+	return durationExpr(d), nil
+}
+
+// durationExpr returns the Go expression of d, in the largest unit it is a
+// multiple of.
+func durationExpr(d time.Duration) string {
+	for _, u := range []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+	} {
+		if d%u.d == 0 {
+			return strconv.FormatInt(int64(d/u.d), 10) + " * " + u.name
+		}
+	}
+	return "time.Duration(" + strconv.FormatInt(int64(d), 10) + ")"
+}
+
+// Send returns the func the stub sends outputs with, which goes through the
+// heartbeat of long-lived streams so that sends never race.
+func (m method) Send() string {
+	if m.LongLived() {
+		return "hb.Send"
+	}
+	return "stream.Send"
+}
+
+// heartbeatTmpl declares the heartbeat sender in the service file of
+// services with long-lived streams.
+var heartbeatTmpl = `
+{{- define "heartbeat_sender" }}
+// {{.LowerName}}Heartbeat sends heartbeats on a long-lived stream. Send
+// serializes the other outputs with them, as gRPC streams do not support
+// concurrent sends.
+type {{.LowerName}}Heartbeat struct {
+	stream  grpc.ServerStream
+	mu      sync.Mutex
+	once    sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// start{{.Name}}Heartbeat sends msg() on stream every interval, give or
+// take a tenth of it, until Stop is called, the stream ends or a send fails.
+func start{{.Name}}Heartbeat(stream grpc.ServerStream, interval time.Duration, msg func() proto.Message) *{{.LowerName}}Heartbeat {
+	h := &{{.LowerName}}Heartbeat{
+		stream:  stream,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go h.run(interval, msg)
+	return h
+}
+
+func (h *{{.LowerName}}Heartbeat) run(interval time.Duration, msg func() proto.Message) {
+	defer close(h.stopped)
+	for {
+		jitter := time.Duration(rand.Int63n(int64(interval)/5+1)) - interval/10
+		t := time.NewTimer(interval + jitter)
+		select {
+		case <-h.done:
+			t.Stop()
+			return
+		case <-h.stream.Context().Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if err := h.Send(msg()); err != nil {
+			return
+		}
+	}
+}
+
+// Send sends m on the stream.
+func (h *{{.LowerName}}Heartbeat) Send(m proto.Message) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stream.SendMsg(m)
+}
+
+// Stop stops the heartbeats, waiting for one being sent.
+func (h *{{.LowerName}}Heartbeat) Stop() {
+	h.once.Do(func() { close(h.done) })
+	<-h.stopped
+}
+{{- end }}
+
+{{- define "heartbeat" }}
+	{{- if .LongLived }}
+	hb := start{{.Service.Name}}Heartbeat(stream, {{.Heartbeat}}, func() proto.Message {
+		// {{.TodoNote "Send a designated heartbeat message"}}
+		return {{.NewOutput}}
+	})
+	defer hb.Stop()
+	{{ end }}
+{{- end }}
+`
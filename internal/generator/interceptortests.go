@@ -0,0 +1,356 @@
+package generator
+
+// interceptorTestsTmpl renders the unit tests of the interceptors generated
+// for the service, run against fake handlers and streams.
+var interceptorTestsTmpl = newTemplate("interceptortests", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// {{.LowerName}}TestMethod is the full method name the interceptors are
+// tested with, and {{.LowerName}}OtherMethod one of another service.
+const (
+	{{.LowerName}}TestMethod  = "/{{.FullName}}/InterceptorTest"
+	{{.LowerName}}OtherMethod = "/other.v1.Other/InterceptorTest"
+)
+
+// {{.LowerName}}FakeStream is a server stream carrying a context, and
+// nothing else.
+type {{.LowerName}}FakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *{{.LowerName}}FakeStream) Context() context.Context {
+	return s.ctx
+}
+
+// {{.LowerName}}CallUnary calls the unary interceptor i with a handler
+// returning handler(ctx), and reports whether the handler was reached.
+func {{.LowerName}}CallUnary(ctx context.Context, i grpc.UnaryServerInterceptor, method string, handler func(ctx context.Context) (interface{}, error)) (interface{}, bool, error) {
+	reached := false
+	resp, err := i(ctx, "input", &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		reached = true
+		return handler(ctx)
+	})
+	return resp, reached, err
+}
+
+// {{.LowerName}}CallStream calls the stream interceptor i with a handler
+// returning handler(ctx), and reports whether the handler was reached.
+func {{.LowerName}}CallStream(ctx context.Context, i grpc.StreamServerInterceptor, method string, handler func(ctx context.Context) error) (bool, error) {
+	reached := false
+	err := i(nil, &{{.LowerName}}FakeStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, ss grpc.ServerStream) error {
+		reached = true
+		return handler(ss.Context())
+	})
+	return reached, err
+}
+
+func {{.LowerName}}OK(ctx context.Context) (interface{}, error) {
+	return "output", nil
+}
+
+{{- if .GenErrReport }}
+
+// {{.LowerName}}TestReporter records the reports it receives.
+type {{.LowerName}}TestReporter struct {
+	reports []{{.Name}}ErrorReport
+}
+
+func (r *{{.LowerName}}TestReporter) Report(ctx context.Context, report {{.Name}}ErrorReport) {
+	r.reports = append(r.reports, report)
+}
+
+func Test{{.Name}}RecoveryPassesThrough(t *testing.T) {
+	reporter := &{{.LowerName}}TestReporter{}
+	r := &{{.Name}}Recovery{Reporter: reporter}
+	resp, _, err := {{.LowerName}}CallUnary(context.Background(), r.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK)
+	if err != nil || resp != "output" {
+		t.Fatalf("got %v, %v, want the output of the handler", resp, err)
+	}
+	wantErr := status.Error(codes.NotFound, "missing")
+	_, _, err = {{.LowerName}}CallUnary(context.Background(), r.UnaryInterceptor(), {{.LowerName}}TestMethod, func(context.Context) (interface{}, error) {
+		return nil, wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("got error %v, want %v", err, wantErr)
+	}
+	if len(reporter.reports) != 0 {
+		t.Fatalf("got %d reports without panics", len(reporter.reports))
+	}
+}
+
+func Test{{.Name}}RecoveryRecoversPanics(t *testing.T) {
+	reporter := &{{.LowerName}}TestReporter{}
+	r := &{{.Name}}Recovery{Reporter: reporter}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs({{.LowerName}}RequestIDHeader, "req-1"))
+	panicking := func(context.Context) (interface{}, error) {
+		panic("boom")
+	}
+
+	var fingerprints []string
+	for i := 0; i < 2; i++ {
+		_, _, err := {{.LowerName}}CallUnary(ctx, r.UnaryInterceptor(), {{.LowerName}}TestMethod, panicking)
+		if status.Code(err) != codes.Internal {
+			t.Fatalf("got code %v, want Internal", status.Code(err))
+		}
+		fingerprint := {{.Name}}ErrorFingerprint(err)
+		if fingerprint == "" || !strings.Contains(status.Convert(err).Message(), fingerprint) {
+			t.Fatalf("got error %v without its fingerprint %q", err, fingerprint)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	if fingerprints[0] != fingerprints[1] {
+		t.Errorf("got fingerprints %q, want the panics of a site to share theirs", fingerprints)
+	}
+
+	if len(reporter.reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reporter.reports))
+	}
+	report := reporter.reports[0]
+	if report.Panic != "boom" || report.RequestID != "req-1" || report.Method != {{.LowerName}}TestMethod || len(report.Stack) == 0 {
+		t.Errorf("got report %+v, want the panic, request ID, method and stack", report)
+	}
+}
+
+func Test{{.Name}}RecoveryRecoversStreamPanics(t *testing.T) {
+	r := &{{.Name}}Recovery{}
+	_, err := {{.LowerName}}CallStream(context.Background(), r.StreamInterceptor(), {{.LowerName}}TestMethod, func(context.Context) error {
+		panic(errors.New("boom"))
+	})
+	if status.Code(err) != codes.Internal || {{.Name}}ErrorFingerprint(err) == "" {
+		t.Fatalf("got error %v, want an Internal error with a fingerprint", err)
+	}
+}
+
+func Test{{.Name}}LoggingLogsCalls(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := &{{.LowerName}}TestReporter{}
+	l := &{{.Name}}Logging{Logger: log.New(&buf, "", 0), Reporter: reporter}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs({{.LowerName}}RequestIDHeader, "req-1"))
+
+	resp, _, err := {{.LowerName}}CallUnary(ctx, l.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK)
+	if err != nil || resp != "output" {
+		t.Fatalf("got %v, %v, want the output of the handler", resp, err)
+	}
+	line := buf.String()
+	for _, want := range []string{{"{"}}{{.LowerName}}TestMethod, " OK ", "request_id=req-1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("got log %q, want it to contain %q", line, want)
+		}
+	}
+
+	buf.Reset()
+	_, err = {{.LowerName}}CallStream(context.Background(), l.StreamInterceptor(), {{.LowerName}}TestMethod, func(context.Context) error {
+		return status.Error(codes.NotFound, "missing")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("got error %v, want the error of the handler", err)
+	}
+	if line := buf.String(); !strings.Contains(line, "NotFound") || !strings.Contains(line, `+"`"+`error="missing"`+"`"+`) {
+		t.Errorf("got log %q, want the code and message of the error", line)
+	}
+	if len(reporter.reports) != 0 {
+		t.Errorf("got %d reports, want expected errors left unreported", len(reporter.reports))
+	}
+}
+
+func Test{{.Name}}LoggingReportsUnexpectedErrors(t *testing.T) {
+	reporter := &{{.LowerName}}TestReporter{}
+	l := &{{.Name}}Logging{Logger: log.New(&bytes.Buffer{}, "", 0), Reporter: reporter}
+	for _, code := range []codes.Code{codes.Internal, codes.Unknown, codes.InvalidArgument} {
+		{{.LowerName}}CallUnary(context.Background(), l.UnaryInterceptor(), {{.LowerName}}TestMethod, func(context.Context) (interface{}, error) {
+			return nil, status.Error(code, "failed")
+		})
+	}
+	if len(reporter.reports) != 2 {
+		t.Fatalf("got %d reports, want the Internal and Unknown errors", len(reporter.reports))
+	}
+}
+{{- end }}
+
+{{- if .GenQuota }}
+
+// {{.LowerName}}TestQuotaStore adapts a func to {{.Name}}QuotaStore.
+type {{.LowerName}}TestQuotaStore func(tenant, method string) (bool, error)
+
+func (f {{.LowerName}}TestQuotaStore) Allow(ctx context.Context, tenant, method string) (bool, error) {
+	return f(tenant, method)
+}
+
+func Test{{.Name}}QuotaAllows(t *testing.T) {
+	var gotTenant, gotMethod string
+	q := &{{.Name}}Quota{Store: {{.LowerName}}TestQuotaStore(func(tenant, method string) (bool, error) {
+		gotTenant, gotMethod = tenant, method
+		return true, nil
+	})}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs({{.Name}}TenantHeader, "acme"))
+	resp, reached, err := {{.LowerName}}CallUnary(ctx, q.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK)
+	if err != nil || !reached || resp != "output" {
+		t.Fatalf("got %v, %v, want the output of the handler", resp, err)
+	}
+	if gotTenant != "acme" || gotMethod != "InterceptorTest" {
+		t.Errorf("got tenant %q and method %q, want acme and InterceptorTest", gotTenant, gotMethod)
+	}
+}
+
+func Test{{.Name}}QuotaRejects(t *testing.T) {
+	q := &{{.Name}}Quota{Store: {{.LowerName}}TestQuotaStore(func(tenant, method string) (bool, error) {
+		return false, nil
+	})}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs({{.Name}}TenantHeader, "acme"))
+	_, reached, err := {{.LowerName}}CallUnary(ctx, q.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK)
+	if reached || status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got error %v, want ResourceExhausted before the handler", err)
+	}
+	var violation *errdetails.QuotaFailure_Violation
+	for _, d := range status.Convert(err).Details() {
+		if f, ok := d.(*errdetails.QuotaFailure); ok && len(f.GetViolations()) > 0 {
+			violation = f.GetViolations()[0]
+		}
+	}
+	if violation.GetSubject() != "tenant:acme" {
+		t.Errorf("got violation %v, want the subject tenant:acme", violation)
+	}
+
+	reached, err = {{.LowerName}}CallStream(ctx, q.StreamInterceptor(), {{.LowerName}}TestMethod, func(context.Context) error { return nil })
+	if reached || status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got stream error %v, want ResourceExhausted before the handler", err)
+	}
+}
+
+func Test{{.Name}}QuotaStoreFailures(t *testing.T) {
+	q := &{{.Name}}Quota{Store: {{.LowerName}}TestQuotaStore(func(tenant, method string) (bool, error) {
+		return false, errors.New("store down")
+	})}
+	_, reached, err := {{.LowerName}}CallUnary(context.Background(), q.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK)
+	if reached || status.Code(err) != codes.Unavailable {
+		t.Fatalf("got error %v, want Unavailable while the store fails", err)
+	}
+	q.FailOpen = true
+	if _, reached, err = {{.LowerName}}CallUnary(context.Background(), q.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK); !reached || err != nil {
+		t.Fatalf("got error %v, want calls let through with FailOpen", err)
+	}
+}
+
+func Test{{.Name}}QuotaIgnoresOtherServices(t *testing.T) {
+	q := &{{.Name}}Quota{Store: {{.LowerName}}TestQuotaStore(func(tenant, method string) (bool, error) {
+		t.Errorf("got a quota check of %s", method)
+		return false, nil
+	})}
+	if _, reached, err := {{.LowerName}}CallUnary(context.Background(), q.UnaryInterceptor(), {{.LowerName}}OtherMethod, {{.LowerName}}OK); !reached || err != nil {
+		t.Fatalf("got error %v, want calls to other services let through", err)
+	}
+}
+{{- end }}
+
+{{- if .GenTenancy }}
+
+func Test{{.Name}}TenancyScopesCalls(t *testing.T) {
+	var observed []string
+	tn := &{{.Name}}Tenancy{Observe: func(tenant, method string, code codes.Code, latency time.Duration) {
+		observed = append(observed, tenant+" "+method+" "+code.String())
+	}}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs({{.Name}}TenantHeader, "acme"))
+	_, reached, err := {{.LowerName}}CallUnary(ctx, tn.UnaryInterceptor(), {{.LowerName}}TestMethod, func(ctx context.Context) (interface{}, error) {
+		if tenant := {{.Name}}TenantFromContext(ctx); tenant != "acme" {
+			t.Errorf("got tenant %q in the handler, want acme", tenant)
+		}
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	if !reached || status.Code(err) != codes.NotFound {
+		t.Fatalf("got error %v, want the error of the handler", err)
+	}
+	if len(observed) != 1 || observed[0] != "acme InterceptorTest NotFound" {
+		t.Errorf("got observations %q, want the tenant, method and code of the call", observed)
+	}
+
+	reached, err = {{.LowerName}}CallStream(ctx, tn.StreamInterceptor(), {{.LowerName}}TestMethod, func(ctx context.Context) error {
+		if tenant := {{.Name}}TenantFromContext(ctx); tenant != "acme" {
+			t.Errorf("got tenant %q in the stream handler, want acme", tenant)
+		}
+		return nil
+	})
+	if !reached || err != nil {
+		t.Fatalf("got stream error %v, want the handler to succeed", err)
+	}
+}
+
+func Test{{.Name}}TenancyPrefersClaims(t *testing.T) {
+	tn := &{{.Name}}Tenancy{Claims: func(context.Context) (string, bool) { return "from-claims", true }}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs({{.Name}}TenantHeader, "from-header"))
+	{{.LowerName}}CallUnary(ctx, tn.UnaryInterceptor(), {{.LowerName}}TestMethod, func(ctx context.Context) (interface{}, error) {
+		if tenant := {{.Name}}TenantFromContext(ctx); tenant != "from-claims" {
+			t.Errorf("got tenant %q, want the claims to take precedence over the header", tenant)
+		}
+		return nil, nil
+	})
+}
+
+func Test{{.Name}}TenancyRequiresTenants(t *testing.T) {
+	if len({{.LowerName}}TenantRequired) == 0 {
+		t.Skip("no method of {{.Name}} requires a tenant")
+	}
+	tn := &{{.Name}}Tenancy{}
+	for method := range {{.LowerName}}TenantRequired {
+		_, reached, err := {{.LowerName}}CallUnary(context.Background(), tn.UnaryInterceptor(), "/{{.FullName}}/"+method, {{.LowerName}}OK)
+		if reached || status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: got error %v, want Unauthenticated before the handler", method, err)
+		}
+	}
+}
+{{- end }}
+
+{{- if .GenMaintenance }}
+
+func Test{{.Name}}MaintenanceSwitch(t *testing.T) {
+	m := &{{.Name}}Maintenance{RetryDelay: time.Minute, Message: "down"}
+	if _, reached, err := {{.LowerName}}CallUnary(context.Background(), m.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK); !reached || err != nil {
+		t.Fatalf("got error %v, want calls served while maintenance mode is off", err)
+	}
+
+	m.Enable()
+	_, reached, err := {{.LowerName}}CallUnary(context.Background(), m.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK)
+	if reached || status.Code(err) != codes.Unavailable || status.Convert(err).Message() != "down" {
+		t.Fatalf("got error %v, want Unavailable before the handler", err)
+	}
+	var retry *errdetails.RetryInfo
+	for _, d := range status.Convert(err).Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry.GetRetryDelay().GetSeconds() != 60 {
+		t.Errorf("got retry info %v, want a delay of a minute", retry)
+	}
+	if reached, err := {{.LowerName}}CallStream(context.Background(), m.StreamInterceptor(), {{.LowerName}}TestMethod, func(context.Context) error { return nil }); reached || status.Code(err) != codes.Unavailable {
+		t.Errorf("got stream error %v, want Unavailable before the handler", err)
+	}
+	if _, reached, err := {{.LowerName}}CallUnary(context.Background(), m.UnaryInterceptor(), {{.LowerName}}OtherMethod, {{.LowerName}}OK); !reached || err != nil {
+		t.Errorf("got error %v, want calls to other services served", err)
+	}
+
+	m.Disable()
+	if _, reached, err := {{.LowerName}}CallUnary(context.Background(), m.UnaryInterceptor(), {{.LowerName}}TestMethod, {{.LowerName}}OK); !reached || err != nil {
+		t.Fatalf("got error %v, want calls served again", err)
+	}
+}
+{{- end }}
+`)
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// lazyDep is a dependency of (service_gen.lazy_deps), initialized on first
+// use.
+type lazyDep struct {
+	// Name is the name of the dependency in the proto file, e.g.
+	// search_index.
+	Name string
+	// GoName is the name of the field holding it, e.g. SearchIndex.
+	GoName string
+	// Type is the Go type of the dependency.
+	Type string
+}
+
+var lazyDepType = regexp.MustCompile(`^\*?[A-Za-z_][A-Za-z0-9_]*$`)
+
+// HasLazyDeps reports whether the service declares lazy dependencies.
+func (p Service) HasLazyDeps() bool {
+	return len(p.LazyDepSpecs()) > 0
+}
+
+// LazyDeps returns the lazy dependencies of the service, declared as name or
+// name=Type, e.g. search_index=SearchIndex.
+func (p Service) LazyDeps() ([]lazyDep, error) {
+	var ds []lazyDep
+	seen := make(map[string]bool)
+	for _, d := range p.fixedDeps() {
+		seen[d.Name] = true
+	}
+	methods := make(map[string]bool)
+	for _, m := range p.Methods {
+		methods[m.GetName()] = true
+	}
+	for _, spec := range p.LazyDepSpecs() {
+		parts := strings.SplitN(spec, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if !workerName.MatchString(name) {
+			return nil, errors.New("invalid lazy_deps option on " + p.GetName() + ": " + spec + ", expected name or name=Type, e.g. search_index=SearchIndex")
+		}
+		d := lazyDep{Name: name, GoName: camelCase(name), Type: "interface{}"}
+		if len(parts) == 2 {
+			d.Type = strings.TrimSpace(parts[1])
+			if !lazyDepType.MatchString(d.Type) {
+				return nil, errors.New("invalid lazy_deps option on " + p.GetName() + ": " + spec + ", the type must be a type of the package of the service, e.g. SearchIndex or *SearchIndex")
+			}
+		}
+		if seen[d.GoName] {
+			return nil, errors.New("invalid lazy_deps option on " + p.GetName() + ": duplicate dependency " + d.GoName)
+		}
+		if methods["Get"+d.GoName] {
+			return nil, errors.New("invalid lazy_deps option on " + p.GetName() + ": the getter of " + name + " conflicts with method Get" + d.GoName)
+		}
+		seen[d.GoName] = true
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// lazyDepsTmpl declares the lazy dependencies in the service file of
+// services annotated with (service_gen.lazy_deps).
+var lazyDepsTmpl = `
+{{- define "lazy_deps" }}
+// {{.Name}}Lazy is a dependency of {{.Name}}Service initialized on first
+// use, e.g. the client of a heavy backend only some methods need. It is
+// safe for concurrent use.
+type {{.Name}}Lazy struct {
+	// Init initializes the dependency. It runs once, with a context of its
+	// own so that callers giving up do not fail it for the others.
+	Init func(ctx context.Context) (interface{}, error)
+	// Timeout bounds Init, which is unbounded when zero.
+	Timeout time.Duration
+
+	once sync.Once
+	done chan struct{}
+	v    interface{}
+	err  error
+}
+
+// Get returns the dependency, starting its initialization on the first
+// call. Calls wait for it until ctx is done. A failed initialization is
+// not retried: its error is returned by every call.
+func (l *{{.Name}}Lazy) Get(ctx context.Context) (interface{}, error) {
+	l.once.Do(l.start)
+	select {
+	case <-l.done:
+		return l.v, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *{{.Name}}Lazy) start() {
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		defer func() {
+			if r := recover(); r != nil {
+				l.v, l.err = nil, fmt.Errorf("panic: %v", r)
+			}
+		}()
+		if l.Init == nil {
+			l.err = errors.New("no initializer")
+			return
+		}
+		ctx := context.Background()
+		if l.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+			defer cancel()
+		}
+		l.v, l.err = l.Init(ctx)
+	}()
+}
+{{ range .LazyDeps }}
+// Get{{.GoName}} returns the {{.Name}} dependency, initialized on first use.
+func (s {{$.Name}}Service) Get{{.GoName}}(ctx context.Context) ({{.Type}}, error) {
+	var zero {{.Type}}
+	if s.{{.GoName}} == nil {
+		return zero, errors.New("{{$.Name}}: the {{.Name}} dependency is not set")
+	}
+	v, err := s.{{.GoName}}.Get(ctx)
+	if err != nil {
+		return zero, err
+	}
+	{{- if eq .Type "interface{}" }}
+	return v, nil
+	{{- else }}
+	d, ok := v.({{.Type}})
+	if !ok {
+		return zero, fmt.Errorf("{{$.Name}}: the {{.Name}} dependency is a %T, not a {{.Type}}", v)
+	}
+	return d, nil
+	{{- end }}
+}
+{{ end }}
+{{- end }}
+`
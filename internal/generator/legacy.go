@@ -0,0 +1,82 @@
+package generator
+
+// legacyTmpl generates the shims serving a service partly from a
+// hand-written implementation while it migrates to the generated one. The
+// hand-written handlers need no conversion: x/net/context.Context is an
+// alias of context.Context, and the streams keep their <Service>_<Method>
+// names in the stubs.
+var legacyTmpl = newTemplate("legacy", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"golang.org/x/net/context"
+	{{.GoImport}}
+)
+
+// {{.Name}}Migration implements {{.GoPrefix}}.{{.Name}}Server by serving
+// each method from a hand-written implementation, until the method is
+// migrated to the generated one. Legacy may implement any subset of the
+// methods, with x/net/context or context signatures alike: the methods it
+// lacks are served by Generated.
+type {{.Name}}Migration struct {
+	// Legacy is the hand-written implementation.
+	Legacy interface{}
+	// Generated is the generated implementation, e.g. {{.Name}}Service.
+	Generated {{.GoPrefix}}.{{.Name}}Server
+	// Migrated holds the names of the methods served by Generated.
+	Migrated map[string]bool
+}
+
+// New{{.Name}}Migration serves the methods named by migrated with
+// generated, and the others with legacy when it implements them.
+func New{{.Name}}Migration(legacy interface{}, generated {{.GoPrefix}}.{{.Name}}Server, migrated ...string) *{{.Name}}Migration {
+	m := &{{.Name}}Migration{Legacy: legacy, Generated: generated, Migrated: make(map[string]bool)}
+	for _, name := range migrated {
+		m.Migrated[name] = true
+	}
+	return m
+}
+
+// Pending returns the names of the methods still served by Legacy.
+func (m *{{.Name}}Migration) Pending() []string {
+	var pending []string
+	{{- range .Methods }}
+	if _, ok := m.Legacy.(interface {
+		{{.Name}}({{.Params}}) {{.Results}}
+	}); ok && !m.Migrated["{{.Name}}"] {
+		pending = append(pending, "{{.Name}}")
+	}
+	{{- end }}
+	return pending
+}
+{{ range .Methods }}
+// {{.Name}} calls Legacy, or Generated once {{.Name}} is migrated.
+func (m *{{.Service.Name}}Migration) {{.Name}}({{.Params}}) {{.Results}} {
+	if l, ok := m.Legacy.(interface {
+		{{.Name}}({{.Params}}) {{.Results}}
+	}); ok && !m.Migrated["{{.Name}}"] {
+		return l.{{.Name}}({{.Args}})
+	}
+	return m.Generated.{{.Name}}({{.Args}})
+}
+{{ end }}
+{{- if eq .Layout "handlers" }}
+// New{{.Name}}LegacyRouter routes the methods legacy implements to it and
+// the others to the handlers generated for them, which close over s.
+// Migrating a method then amounts to setting its func to the generated
+// handler.
+func New{{.Name}}LegacyRouter(legacy interface{}, s {{.Name}}Service) *{{.Name}}Router {
+	r := New{{.Name}}Router(s)
+	{{- range .Methods }}
+	if l, ok := legacy.(interface {
+		{{.Name}}({{.Params}}) {{.Results}}
+	}); ok {
+		r.{{.Name}}Func = l.{{.Name}}
+	}
+	{{- end }}
+	return r
+}
+{{ end }}
+`)
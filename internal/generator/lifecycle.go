@@ -0,0 +1,128 @@
+package generator
+
+var lifecycleTmpl = newTemplate("lifecycle", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Default{{.Name}}StopTimeout is the time a component of a {{.Name}}Lifecycle
+// is given to stop when StopTimeout is zero.
+const Default{{.Name}}StopTimeout = 10 * time.Second
+
+// {{.Name}}Lifecycle starts the components of a server, e.g. dependency
+// connections, background workers and listeners, in the order they are
+// registered, and stops them in the reverse order: what was started first,
+// and which the others may use, is stopped last.
+type {{.Name}}Lifecycle struct {
+	// StopTimeout bounds the stop of each component,
+	// Default{{.Name}}StopTimeout when zero.
+	StopTimeout time.Duration
+
+	mu         sync.Mutex
+	components []{{.LowerName}}Component
+	started    int
+}
+
+// {{.LowerName}}Component is a component of a {{.Name}}Lifecycle.
+type {{.LowerName}}Component struct {
+	name        string
+	start, stop func(ctx context.Context) error
+}
+
+// {{.Name}}LifecycleRegistrar is implemented by services registering their
+// own components, e.g. the connections to their dependencies, to the
+// lifecycle of Run{{.Name}}. They are started before the listeners and
+// stopped once the servers have drained.
+type {{.Name}}LifecycleRegistrar interface {
+	RegisterLifecycle(lc *{{.Name}}Lifecycle)
+}
+
+// Register adds a component named name, started by start and stopped by
+// stop, either of which may be nil. start must not block: it returns once
+// the component is ready, leaving any long-running work to goroutines.
+func (l *{{.Name}}Lifecycle) Register(name string, start, stop func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, {{.LowerName}}Component{name: name, start: start, stop: stop})
+}
+
+// Start starts the components not started yet, in order. When one fails,
+// the components already started are stopped again and its error is
+// returned.
+func (l *{{.Name}}Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.started < len(l.components) {
+		c := l.components[l.started]
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				l.stopLocked(context.Background())
+				return fmt.Errorf("unable to start %s: %v", c.name, err)
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse order, each within
+// StopTimeout, and returns their errors. A component failing to stop does
+// not keep the others running.
+func (l *{{.Name}}Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopLocked(ctx)
+}
+
+func (l *{{.Name}}Lifecycle) stopLocked(ctx context.Context) error {
+	timeout := l.StopTimeout
+	if timeout <= 0 {
+		timeout = Default{{.Name}}StopTimeout
+	}
+	var errs []string
+	for ; l.started > 0; l.started-- {
+		c := l.components[l.started-1]
+		if c.stop == nil {
+			continue
+		}
+		sctx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.stop(sctx)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", c.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to stop %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// {{.LowerName}}StopGRPC stops s gracefully, or abruptly once ctx is done,
+// returning the error of ctx then.
+func {{.LowerName}}StopGRPC(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+`)
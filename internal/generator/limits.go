@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimit is a (service_gen.rate_limit): Requests calls per Period.
+type rateLimit struct {
+	Requests uint32
+	Period   time.Duration
+}
+
+// Rate returns the rate limit of the method, nil when it has none. The
+// period of "100/s" is a unit, and of "50/10s" a duration.
+func (m method) Rate() (*rateLimit, error) {
+	if m.RateLimit() == "" {
+		return nil, nil
+	}
+	invalid := errors.New("invalid rate_limit option on " + m.GetName() + ": " + m.RateLimit() + ", expected requests per period, e.g. 100/s")
+	parts := strings.SplitN(m.RateLimit(), "/", 2)
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 32)
+	if err != nil || n == 0 {
+		return nil, invalid
+	}
+	period := strings.TrimSpace(parts[1])
+	switch period {
+	case "s", "m", "h":
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return nil, invalid
+	}
+	return &rateLimit{Requests: uint32(n), Period: d}, nil
+}
+
+// PeriodExpr returns the Go expression of the period of r.
+func (r rateLimit) PeriodExpr() string {
+	return durationExpr(r.Period)
+}
+
+// methodLimits are the limits of a method, as exported in
+// <service>_limits.json.
+type methodLimits struct {
+	FullMethod      string         `json:"full_method"`
+	Timeout         string         `json:"timeout,omitempty"`
+	MaxRequestBytes uint64         `json:"max_request_bytes,omitempty"`
+	RateLimit       *jsonRateLimit `json:"rate_limit,omitempty"`
+}
+
+// jsonRateLimit is a rate limit as exported in <service>_limits.json.
+type jsonRateLimit struct {
+	Requests uint32 `json:"requests"`
+	Period   string `json:"period"`
+}
+
+// LimitsJSON returns the limits of every method of the service, keyed by
+// full method name. Durations are in seconds, as in service configs.
+func (p Service) LimitsJSON() (string, error) {
+	limits := make(map[string]methodLimits)
+	for _, m := range p.Methods {
+		l := methodLimits{
+			FullMethod:      m.FullMethod(),
+			MaxRequestBytes: m.MaxRequestBytes(),
+		}
+		if t := m.Timeout(); t != "" {
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				return "", errors.New("invalid timeout option on " + m.GetName() + ": " + err.Error())
+			}
+			l.Timeout = strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+		}
+		rate, err := m.Rate()
+		if err != nil {
+			return "", err
+		}
+		if rate != nil {
+			l.RateLimit = &jsonRateLimit{
+				Requests: rate.Requests,
+				Period:   strconv.FormatFloat(rate.Period.Seconds(), 'f', -1, 64) + "s",
+			}
+		}
+		limits[m.FullMethod()] = l
+	}
+	b, err := json.MarshalIndent(limits, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+var limitsTmpl = newTemplate("limits", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"time"
+)
+
+// {{.Name}}RateLimit is the rate of calls a method accepts per client.
+type {{.Name}}RateLimit struct {
+	Requests uint32
+	Period   time.Duration
+}
+
+// {{.Name}}MethodLimits are the limits of a method, from its options. Zero
+// values mean no limit.
+type {{.Name}}MethodLimits struct {
+	FullMethod string
+	// Timeout is the default deadline of calls, from (service_gen.timeout).
+	Timeout time.Duration
+	// MaxRequestBytes caps the size of the input, from
+	// (service_gen.max_request_bytes).
+	MaxRequestBytes uint64
+	// RateLimit is the rate of calls accepted, from (service_gen.rate_limit).
+	RateLimit *{{.Name}}RateLimit
+}
+
+// {{.Name}}Limits lists the limits of the methods of {{.Name}} by full
+// method name, for edge gateways to configure their own limits from.
+var {{.Name}}Limits = map[string]{{.Name}}MethodLimits{
+	{{- range .Methods }}
+	"{{.FullMethod}}": {
+		FullMethod:      "{{.FullMethod}}",
+		Timeout:         {{.TimeoutExpr}},
+		MaxRequestBytes: {{.MaxRequestBytes}},
+		{{- with .Rate }}
+		RateLimit:       &{{$.Name}}RateLimit{Requests: {{.Requests}}, Period: {{.PeriodExpr}}},
+		{{- end }}
+	},
+	{{- end }}
+}
+`)
+
+var limitsJSONTmpl = newTemplate("limitsjson", "{{.LimitsJSON}}\n")
@@ -0,0 +1,109 @@
+package generator
+
+var maintenanceTmpl = newTemplate("maintenance", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}MaintenanceEnv names the environment variable switching
+// {{.Name}} into maintenance mode at startup, e.g. {{.EnvPrefix}}_MAINTENANCE=true.
+const {{.Name}}MaintenanceEnv = "{{.EnvPrefix}}_MAINTENANCE"
+
+// {{.Name}}Maintenance rejects {{.Name}} calls with codes.Unavailable while
+// maintenance mode is on, telling clients when to retry. Other services
+// registered on the server, health checking included, are not affected. It
+// is safe for concurrent use.
+type {{.Name}}Maintenance struct {
+	// RetryDelay is suggested to clients through a RetryInfo detail.
+	RetryDelay time.Duration
+	// Message is the status message of rejected calls.
+	Message string
+
+	enabled int32
+}
+
+// New{{.Name}}Maintenance returns a maintenance switch, turned on when
+// {{.Name}}MaintenanceEnv is set to true.
+func New{{.Name}}Maintenance() (*{{.Name}}Maintenance, error) {
+	m := &{{.Name}}Maintenance{
+		RetryDelay: 30 * time.Second,
+		Message:    "{{.Name}} is under maintenance",
+	}
+	if v := os.Getenv({{.Name}}MaintenanceEnv); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", {{.Name}}MaintenanceEnv, err)
+		}
+		if on {
+			m.Enable()
+		}
+	}
+	return m, nil
+}
+
+// Enable turns maintenance mode on.
+func (m *{{.Name}}Maintenance) Enable() {
+	atomic.StoreInt32(&m.enabled, 1)
+}
+
+// Disable turns maintenance mode off.
+func (m *{{.Name}}Maintenance) Disable() {
+	atomic.StoreInt32(&m.enabled, 0)
+}
+
+// Enabled reports whether maintenance mode is on.
+func (m *{{.Name}}Maintenance) Enabled() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+// check returns the error rejecting a call to the full method name, if any.
+func (m *{{.Name}}Maintenance) check(fullMethod string) error {
+	if !m.Enabled() || !strings.HasPrefix(fullMethod, "/{{.FullName}}/") {
+		return nil
+	}
+	st := status.New(codes.Unavailable, m.Message)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(m.RetryDelay),
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// UnaryInterceptor rejects unary calls while maintenance mode is on.
+func (m *{{.Name}}Maintenance) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := m.check(info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor rejects new streams while maintenance mode is on.
+// Streams already running are left to finish.
+func (m *{{.Name}}Maintenance) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := m.check(info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+`)
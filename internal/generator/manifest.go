@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// manifestName is the name of the generation manifest in the output
+// directory.
+const manifestName = "manifest.json"
+
+// manifest describes the output of an invocation for verification and
+// ownership tooling.
+type manifest struct {
+	Generator string            `json:"generator"`
+	Files     []manifestFile    `json:"files"`
+	Services  []manifestService `json:"services"`
+}
+
+// manifestFile is a generated file. Service and Methods are the service it
+// is generated for and the methods rendered into it, while files holding
+// the code of several services, e.g. merged by amalgamate, list them in
+// Services instead.
+type manifestFile struct {
+	Name     string             `json:"name"`
+	SHA256   string             `json:"sha256"`
+	Service  string             `json:"service,omitempty"`
+	Methods  []string           `json:"methods,omitempty"`
+	Services []manifestCoverage `json:"services,omitempty"`
+}
+
+// manifestCoverage is a service whose code a file holds, with the methods
+// rendered into it.
+type manifestCoverage struct {
+	Service string   `json:"service"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+// manifestService is a service generated for, with the options in effect.
+type manifestService struct {
+	Name    string   `json:"name"`
+	Proto   string   `json:"proto"`
+	Owners  []owner  `json:"owners,omitempty"`
+	Options *options `json:"options"`
+}
+
+// wantsManifest reports whether any service asks for a manifest.
+func wantsManifest(ps []*Service) bool {
+	for _, p := range ps {
+		if p.GenManifest {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestFor builds the manifest of files, where owners[i] is the owner of
+// files[i].
+func manifestFor(ps []*Service, files []*plugin.CodeGeneratorResponse_File, owners []fileOwner) (*plugin.CodeGeneratorResponse_File, error) {
+	m := manifest{
+		Generator: "protoc-gen-grpc-go-service",
+		Files:     []manifestFile{},
+		Services:  []manifestService{},
+	}
+	for i, f := range files {
+		sum := sha256.Sum256([]byte(f.GetContent()))
+		mf := manifestFile{
+			Name:   f.GetName(),
+			SHA256: hex.EncodeToString(sum[:]),
+		}
+		for _, c := range owners[i].covers {
+			mc := manifestCoverage{Service: c.service.FullName()}
+			for _, mtd := range c.methods {
+				mc.Methods = append(mc.Methods, mtd.GetName())
+			}
+			mf.Services = append(mf.Services, mc)
+		}
+		if len(mf.Services) == 1 {
+			mf.Service, mf.Methods, mf.Services = mf.Services[0].Service, mf.Services[0].Methods, nil
+		}
+		m.Files = append(m.Files, mf)
+	}
+	for _, p := range ps {
+		opts := p.options
+		m.Services = append(m.Services, manifestService{
+			Name:    p.FullName(),
+			Proto:   p.ProtoName,
+			Owners:  p.Owners(),
+			Options: &opts,
+		})
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(manifestName),
+		Content: proto.String(string(b) + "\n"),
+	}, nil
+}
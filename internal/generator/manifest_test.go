@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// TestManifestFiles checks that the manifest attributes every file to the
+// services and methods rendered into it.
+func TestManifestFiles(t *testing.T) {
+	shop := testFile("shop.proto",
+		testService("Store", testMethod("Get", false, false), testMethod("List", false, true)),
+		testService("Cart", testMethod("Add", false, false)),
+	)
+	tests := []struct {
+		name string
+		req  *plugin.CodeGeneratorRequest
+		want map[string]manifestFile
+	}{
+		{
+			name: "service files",
+			req:  testRequest("gen_manifest=true,gen_client=true", shop),
+			want: map[string]manifestFile{
+				"store_client.go": {Service: "test.Store", Methods: []string{"Get", "List"}},
+				"cart_service.go": {Service: "test.Cart", Methods: []string{"Add"}},
+			},
+		},
+		{
+			name: "method files",
+			req:  testRequest("gen_manifest=true,methods=Store.Get", shop),
+			want: map[string]manifestFile{
+				"store_service.go":    {Service: "test.Store", Methods: []string{"Get", "List"}},
+				"store_get_method.go": {Service: "test.Store", Methods: []string{"Get"}},
+			},
+		},
+		{
+			name: "registration guard",
+			req:  testRequest("gen_manifest=true,gen_registration_guard=true", shop),
+			want: map[string]manifestFile{
+				"registration_guard.go": {Services: []manifestCoverage{{Service: "test.Cart"}, {Service: "test.Store"}}},
+			},
+		},
+		{
+			name: "amalgamated files",
+			req:  testRequest("gen_manifest=true,amalgamate=true,gen_client=true,methods=Store.Get,Cart.*", shop),
+			want: map[string]manifestFile{
+				"services.gen.go": {Services: []manifestCoverage{
+					{Service: "test.Store", Methods: []string{"Get", "List"}},
+					{Service: "test.Cart", Methods: []string{"Add"}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := generateFiles(t, tt.req)
+			var m manifest
+			if err := json.Unmarshal([]byte(files[manifestName]), &m); err != nil {
+				t.Fatalf("unable to read %s: %v", manifestName, err)
+			}
+			got := make(map[string]manifestFile)
+			for _, f := range m.Files {
+				f.SHA256 = ""
+				got[f.Name] = f
+			}
+			for name, want := range tt.want {
+				want.Name = name
+				if !reflect.DeepEqual(got[name], want) {
+					t.Errorf("%s is listed as %+v, want %+v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// message is a message type declared in one of the request's proto files.
+type message struct {
+	*descriptor.DescriptorProto
+	// GoName is the name of the generated Go type, e.g. Outer_Inner.
+	GoName string
+	// Package is the proto package the message is declared in.
+	Package string
+	// Syntax is the syntax of the file declaring the message.
+	Syntax string
+	// PGV reports whether the file declaring the message imports the
+	// protoc-gen-validate rules, which gives the message a Validate method.
+	PGV bool
+}
+
+// messageIndex maps fully qualified message names, with their leading dot,
+// to the messages declared in the request.
+type messageIndex map[string]*message
+
+// indexMessages indexes the messages, including nested ones, of files.
+func indexMessages(files []*descriptor.FileDescriptorProto) messageIndex {
+	idx := make(messageIndex)
+	for _, f := range files {
+		prefix := "."
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage() + "."
+		}
+		for _, d := range f.GetMessageType() {
+			idx.add(f, prefix, "", d)
+		}
+	}
+	return idx
+}
+
+func (idx messageIndex) add(f *descriptor.FileDescriptorProto, prefix, goPrefix string, d *descriptor.DescriptorProto) {
+	goName := goPrefix + camelCase(d.GetName())
+	idx[prefix+d.GetName()] = &message{DescriptorProto: d, GoName: goName, Package: f.GetPackage(), Syntax: f.GetSyntax(), PGV: importsPGV(f)}
+	for _, nested := range d.GetNestedType() {
+		idx.add(f, prefix+d.GetName()+".", goName+"_", nested)
+	}
+}
+
+// goName returns the Go type name of the fully qualified message name,
+// falling back to the trimmed proto name for unknown messages.
+func (idx messageIndex) goName(name string) string {
+	if m, ok := idx[name]; ok {
+		return m.GoName
+	}
+	return strings.TrimPrefix(name, ".")
+}
+
+// camelCase converts a proto name to the Go name protoc-gen-go generates
+// for it: underscores followed by a lower case letter are dropped and that
+// letter is upper cased, as is the first letter.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
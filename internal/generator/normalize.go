@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// normalization is a statement of the normalizer of a method.
+type normalization struct {
+	// Stmt is the Go statement normalizing a field of input.
+	Stmt string
+	// Todo, when set, leaves Stmt commented out behind a TODO with that
+	// note, for the cases that depend on the meaning of the field.
+	Todo string
+	// PageSize reports whether Stmt defaults a page size.
+	PageSize bool
+}
+
+// defaultPageSize is the page size normalizers default page_size fields to.
+const defaultPageSize = 50
+
+// Normalizations returns the statements normalizing the top-level fields of
+// the input of the method: strings are trimmed, but for sensitive ones,
+// page_size fields defaulted, and identifiers suggested to be lower cased.
+// Proto2 and oneof fields, set through pointers and wrappers, are left
+// out.
+func (m method) Normalizations() []normalization {
+	msg, ok := m.messages[m.GetInputType()]
+	if !ok || msg.Syntax != "proto3" {
+		return nil
+	}
+	var ns []normalization
+	for _, f := range msg.GetField() {
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED || f.OneofIndex != nil {
+			continue
+		}
+		field := "input." + goFieldName(f.GetName())
+		switch f.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			if sensitive(f) {
+				continue
+			}
+			ns = append(ns, normalization{Stmt: field + " = strings.TrimSpace(" + field + ")"})
+			if name := f.GetName(); name == "id" || strings.HasSuffix(name, "_id") || strings.Contains(name, "email") {
+				ns = append(ns, normalization{
+					Stmt: field + " = strings.ToLower(" + field + ")",
+					Todo: "Lower case " + f.GetName() + " if it is case-insensitive",
+				})
+			}
+		case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64,
+			descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64,
+			descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64:
+			if f.GetName() == "page_size" {
+				ns = append(ns, normalization{
+					Stmt:     "if " + field + " <= 0 {\n\t\t" + field + " = " + m.service.LowerName() + "DefaultPageSize\n\t}",
+					PageSize: true,
+				})
+			}
+		}
+	}
+	return ns
+}
+
+// HasPageSizes reports whether any normalizer defaults a page size.
+func (p Service) HasPageSizes() bool {
+	for _, m := range p.Methods {
+		for _, n := range m.Normalizations() {
+			if n.PageSize {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DefaultPageSize returns the page size normalizers default to.
+func (p Service) DefaultPageSize() int {
+	return defaultPageSize
+}
+
+// normalizeTmpl declares the normalizers in the service file of services
+// generated with gen_normalize.
+var normalizeTmpl = `
+{{- define "normalizers" }}
+{{- if .HasPageSizes }}
+// {{.LowerName}}DefaultPageSize is the page size of the calls asking for none.
+const {{.LowerName}}DefaultPageSize = {{.DefaultPageSize}}
+{{ end }}
+{{- range .Methods }}
+{{- $method := . }}
+// normalize{{.Service.Name}}{{.Name}}Request normalizes {{ if .GetClientStreaming }}each input{{ else }}the input{{ end }} of {{.Name}}
+// before it is validated, so that equivalent inputs are handled alike.
+func normalize{{.Service.Name}}{{.Name}}Request(input *{{.Service.GoPrefix}}.{{.InputGoName}}) {
+	{{- range .Normalizations }}
+	{{- if .Todo }}
+	// {{$method.TodoNote .Todo}}:
+	// {{.Stmt}}
+	{{- else }}
+	{{.Stmt}}
+	{{- end }}
+	{{- end }}
+	// {{.TodoNote "Normalize the other fields"}}
+}
+{{ end }}
+{{- end }}
+
+{{- define "normalize" }}
+	{{- if .Service.GenNormalize }}
+	normalize{{.Service.Name}}{{.Name}}Request(input)
+	{{- end }}
+{{- end }}
+`
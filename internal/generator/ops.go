@@ -0,0 +1,213 @@
+package generator
+
+var opsTmpl = newTemplate("ops", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}OpsServiceName is the name of the ops service of {{.Name}}.
+const {{.Name}}OpsServiceName = "{{.FullName}}Ops"
+
+// {{.Name}}OpsServer is the ops service of {{.Name}}, controlling it at
+// runtime. Its messages are well-known types, so that it needs no proto
+// file.
+type {{.Name}}OpsServer interface {
+	// SetLogLevel sets the minimum level of the logs and returns it.
+	SetLogLevel(ctx context.Context, level *wrappers.StringValue) (*wrappers.StringValue, error)
+	// SetMaintenance turns maintenance mode on or off and returns whether it
+	// is on.
+	SetMaintenance(ctx context.Context, on *wrappers.BoolValue) (*wrappers.BoolValue, error)
+	// DumpConfig returns the configuration in effect as JSON.
+	DumpConfig(ctx context.Context, in *empty.Empty) (*wrappers.StringValue, error)
+}
+
+// {{.Name}}Ops implements {{.Name}}OpsServer with the switches of the
+// service. Operations whose switch is nil fail with Unimplemented.
+type {{.Name}}Ops struct {
+	// LogLevel applies a log level.
+	LogLevel func(level string) error
+	{{- if .GenMaintenance }}
+	// Maintenance is the maintenance switch of the service.
+	Maintenance *{{.Name}}Maintenance
+	{{- else }}
+	// Maintenance turns maintenance mode on or off.
+	Maintenance func(on bool)
+	{{- end }}
+	// Config returns the configuration in effect, secrets removed.
+	Config func() interface{}
+}
+
+// SetLogLevel calls LogLevel.
+func (o *{{.Name}}Ops) SetLogLevel(ctx context.Context, level *wrappers.StringValue) (*wrappers.StringValue, error) {
+	if o.LogLevel == nil {
+		return nil, status.Error(codes.Unimplemented, "log levels cannot be set")
+	}
+	if err := o.LogLevel(level.GetValue()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to set the log level: %v", err)
+	}
+	return &wrappers.StringValue{Value: level.GetValue()}, nil
+}
+
+// SetMaintenance switches Maintenance.
+func (o *{{.Name}}Ops) SetMaintenance(ctx context.Context, on *wrappers.BoolValue) (*wrappers.BoolValue, error) {
+	if o.Maintenance == nil {
+		return nil, status.Error(codes.Unimplemented, "maintenance mode cannot be switched")
+	}
+	{{- if .GenMaintenance }}
+	if on.GetValue() {
+		o.Maintenance.Enable()
+	} else {
+		o.Maintenance.Disable()
+	}
+	return &wrappers.BoolValue{Value: o.Maintenance.Enabled()}, nil
+	{{- else }}
+	o.Maintenance(on.GetValue())
+	return &wrappers.BoolValue{Value: on.GetValue()}, nil
+	{{- end }}
+}
+
+// DumpConfig marshals the result of Config.
+func (o *{{.Name}}Ops) DumpConfig(ctx context.Context, in *empty.Empty) (*wrappers.StringValue, error) {
+	if o.Config == nil {
+		return nil, status.Error(codes.Unimplemented, "the configuration cannot be dumped")
+	}
+	b, err := json.MarshalIndent(o.Config(), "", "  ")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to marshal the configuration: %v", err)
+	}
+	return &wrappers.StringValue{Value: string(b)}, nil
+}
+
+// New{{.Name}}OpsServer returns a server of srv only accepting calls
+// authenticated with token as a bearer token. Serve it on a listener of its
+// own, away from public interfaces.
+func New{{.Name}}OpsServer(srv {{.Name}}OpsServer, token string, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	if token == "" {
+		return nil, fmt.Errorf("{{.Name}}: the ops service needs a token")
+	}
+	auth := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get("authorization") {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) == 1 {
+				return nil
+			}
+		}
+		return status.Error(codes.Unauthenticated, "invalid ops token")
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := auth(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}))
+	s := grpc.NewServer(opts...)
+	s.RegisterService(&{{.LowerName}}OpsServiceDesc, srv)
+	return s, nil
+}
+
+var {{.LowerName}}OpsServiceDesc = grpc.ServiceDesc{
+	ServiceName: {{.Name}}OpsServiceName,
+	HandlerType: (*{{.Name}}OpsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetLogLevel",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrappers.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.({{.Name}}OpsServer).SetLogLevel(ctx, req.(*wrappers.StringValue))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + {{.Name}}OpsServiceName + "/SetLogLevel"}, handler)
+			},
+		},
+		{
+			MethodName: "SetMaintenance",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrappers.BoolValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.({{.Name}}OpsServer).SetMaintenance(ctx, req.(*wrappers.BoolValue))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + {{.Name}}OpsServiceName + "/SetMaintenance"}, handler)
+			},
+		},
+		{
+			MethodName: "DumpConfig",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(empty.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.({{.Name}}OpsServer).DumpConfig(ctx, req.(*empty.Empty))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + {{.Name}}OpsServiceName + "/DumpConfig"}, handler)
+			},
+		},
+	},
+}
+
+// {{.Name}}OpsClient calls the ops service of {{.Name}} with a token.
+type {{.Name}}OpsClient struct {
+	cc    *grpc.ClientConn
+	token string
+}
+
+// New{{.Name}}OpsClient returns a client of the ops service served on cc,
+// authenticating with token.
+func New{{.Name}}OpsClient(cc *grpc.ClientConn, token string) *{{.Name}}OpsClient {
+	return &{{.Name}}OpsClient{cc: cc, token: token}
+}
+
+func (c *{{.Name}}OpsClient) invoke(ctx context.Context, method string, in, out interface{}, opts ...grpc.CallOption) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	return c.cc.Invoke(ctx, "/"+{{.Name}}OpsServiceName+"/"+method, in, out, opts...)
+}
+
+// SetLogLevel sets the minimum level of the logs.
+func (c *{{.Name}}OpsClient) SetLogLevel(ctx context.Context, level string, opts ...grpc.CallOption) error {
+	return c.invoke(ctx, "SetLogLevel", &wrappers.StringValue{Value: level}, new(wrappers.StringValue), opts...)
+}
+
+// SetMaintenance turns maintenance mode on or off.
+func (c *{{.Name}}OpsClient) SetMaintenance(ctx context.Context, on bool, opts ...grpc.CallOption) (bool, error) {
+	out := new(wrappers.BoolValue)
+	err := c.invoke(ctx, "SetMaintenance", &wrappers.BoolValue{Value: on}, out, opts...)
+	return out.GetValue(), err
+}
+
+// DumpConfig returns the configuration in effect as JSON.
+func (c *{{.Name}}OpsClient) DumpConfig(ctx context.Context, opts ...grpc.CallOption) (string, error) {
+	out := new(wrappers.StringValue)
+	err := c.invoke(ctx, "DumpConfig", new(empty.Empty), out, opts...)
+	return out.GetValue(), err
+}
+`)
@@ -0,0 +1,523 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options holds the generation options passed through the protoc parameter
+// string, e.g. --grpc-go-service_out=GoPrefix=pb,gen_client=true:./services/
+type options struct {
+	GoPrefix      string
+	GoPackageName string
+	GoImport      string
+
+	// TemplateDir holds *.tmpl files overriding blocks of the built-in
+	// templates: header, imports, unary_body and stream_body.
+	TemplateDir string
+	// StubsDir holds the Go stubs generated by protoc-gen-go and its gRPC
+	// plugin. When set, the symbols the generated code refers to are checked
+	// against them.
+	StubsDir string
+	// FeatureFlagCode is the status code returned by methods whose feature
+	// flag is off: Unimplemented or FailedPrecondition.
+	FeatureFlagCode string
+
+	// OutputDir is the directory protoc writes the files to, relative to
+	// the directory protoc runs in. protoc does not pass it on to plugins.
+	OutputDir string
+	// Paths is where the files of a service go: "" names them after the
+	// service alone, "source_relative" puts them in the directory of the
+	// proto file and "import" under the go_package import path of the proto
+	// file, in a GoPackageName directory.
+	Paths string
+	// SkipExisting leaves out the files already present in OutputDir, to
+	// protect hand-edited implementations.
+	SkipExisting bool
+	// Backup is "bak" or "diff" to emit, next to every file overwriting a
+	// different one in OutputDir, a copy of the previous contents or a diff
+	// from them.
+	Backup string
+	// DryRun replaces the generated files with a report listing them and
+	// the symbols they declare.
+	DryRun bool
+
+	// GenClient emits a <service>_client.go file with a dial helper.
+	GenClient bool
+	// GenConnManager emits a <service>_connmanager.go file with a pool of
+	// connections keyed by target. It implies GenClient.
+	GenConnManager bool
+	// GenServiceConfig emits the service config derived from method options
+	// as <service>_service_config.json and a Go constant, which the dial
+	// helper then uses by default.
+	GenServiceConfig bool
+	// GenSmoke emits a <service>_smoke/main.go command checking a running
+	// instance through server reflection and a health probe.
+	GenSmoke bool
+	// GenChaos emits fault injection interceptors compiled in with the
+	// chaos build tag, and pass-through ones otherwise.
+	GenChaos bool
+	// GenRecorder emits an interceptor recording redacted unary calls to
+	// disk and a harness replaying them against a server.
+	GenRecorder bool
+	// GenMaintenance emits a maintenance switch rejecting calls with
+	// Unavailable while it is on.
+	GenMaintenance bool
+	// GenShadow emits a client decorator mirroring unary calls to a shadow
+	// backend.
+	GenShadow bool
+	// GenCanary emits a client splitting calls between a stable and a canary
+	// backend.
+	GenCanary bool
+	// GenABRouter emits a server dispatching every call to one of two
+	// implementations of the service, by percentage or request metadata, for
+	// incremental rewrites.
+	GenABRouter bool
+	// GenQuota emits interceptors enforcing per-tenant quotas through a
+	// pluggable store.
+	GenQuota bool
+	// GenTenancy emits tenant extraction, context accessors and interceptors
+	// guarding methods that require a tenant.
+	GenTenancy bool
+	// GenCatalog emits a catalog of the messages of the errors generated code
+	// fails calls with, localized by the accept-language of calls, and
+	// routes those errors through it.
+	GenCatalog bool
+	// GenErrMap emits a registry translating internal errors to status codes
+	// and routes the errors of every generated handler through it.
+	GenErrMap bool
+	// GenPGV checks the inputs of every handler with the Validate methods
+	// protoc-gen-validate generates for the messages of files importing
+	// validate/validate.proto, mapping violations to InvalidArgument.
+	GenPGV bool
+	// GenBuilders emits constructors with functional options for the output
+	// messages and uses them in stubs.
+	GenBuilders bool
+	// GenSwitches emits, in stubs, switches over the oneofs and enum fields
+	// of the input.
+	GenSwitches bool
+	// GenSubscriptions emits client subscriptions to the server streams that
+	// reconnect after transient errors. It implies GenClient.
+	GenSubscriptions bool
+	// GenTestServer emits a test helper serving the service over bufconn.
+	GenTestServer bool
+	// GenCtxKeys emits typed context keys and accessors for the values
+	// middleware stores in the context, including the tenant of GenTenancy.
+	GenCtxKeys bool
+	// GenErrReport emits recovery and logging interceptors reporting
+	// unexpected errors through a pluggable reporter, with a Sentry reporter
+	// behind the sentry build tag.
+	GenErrReport bool
+	// LogPayloads lets the logging interceptor of GenErrReport log the
+	// redacted payloads of a sample of the calls, or of the failed ones. It
+	// implies GenErrReport.
+	LogPayloads bool
+	// GenDebugStrings emits helpers rendering the messages of the service as
+	// redacted protojson, which the payloads of LogPayloads are then logged
+	// in.
+	GenDebugStrings bool
+	// GenOTelMetrics emits interceptors recording OpenTelemetry metrics of
+	// call durations and stream messages.
+	GenOTelMetrics bool
+	// GenStats emits a stats.Handler tracking connections, stream lifetimes
+	// and bytes, exported through OpenTelemetry with GenOTelMetrics and
+	// Prometheus otherwise. The server bootstrap installs it.
+	GenStats bool
+	// GenStreamMetrics emits a stream interceptor counting the messages of
+	// streams and recording their sizes per method, through the backend of
+	// GenStats. The server bootstrap installs it.
+	GenStreamMetrics bool
+	// GenTraceHeaders emits interceptors propagating W3C and B3 trace
+	// headers from incoming calls to outgoing ones, for services without a
+	// tracer. The logging interceptor of GenErrReport logs their trace IDs.
+	GenTraceHeaders bool
+	// GenEvents emits interceptors publishing an event per completed call to
+	// registered observers, which the server bootstrap installs.
+	GenEvents bool
+	// GenBuildInfo emits the build version, commit and descriptor hash of
+	// the binary, exposed in the header metadata of health checks and, by
+	// the server bootstrap, on its debug endpoint.
+	GenBuildInfo bool
+	// GenPolicy emits the full method names of the service and a map of
+	// their streaming shape, idempotency and required roles, for
+	// authorization services to build policy from.
+	GenPolicy bool
+	// PolicyJSON also emits the policy of GenPolicy as JSON. It implies
+	// GenPolicy.
+	PolicyJSON bool
+	// GenDepHealth emits a CheckAll method pinging the dependencies of the
+	// service struct and a checker feeding its results to the health server
+	// and a readiness endpoint, which the server bootstrap runs.
+	GenDepHealth bool
+	// GenLegacyShims emits adapters serving the methods of a hand-written
+	// implementation of the service until they are migrated to the
+	// generated one.
+	GenLegacyShims bool
+	// GenBaggage emits interceptors propagating OpenTelemetry baggage, to
+	// which the input fields of (service_gen.baggage_fields) are added. The
+	// dial helper and the server bootstrap install them.
+	GenBaggage bool
+	// GenOps emits an ops service setting the log level, switching the
+	// maintenance mode of GenMaintenance and dumping the configuration,
+	// which the server bootstrap serves on a listener of its own behind a
+	// token. It implies GenServer.
+	GenOps bool
+	// GenNormalize emits a normalizer per method, trimming the strings of
+	// its input and defaulting its page size, which the handlers call
+	// before validating the input.
+	GenNormalize bool
+	// GenUpdateDiff emits a helper per Update method computing the fields
+	// an update changes in the stored resource, and has the handlers
+	// publish them for audit logs and change events.
+	GenUpdateDiff bool
+	// GenAPIIndex emits a typed description of the service, its methods,
+	// their messages and options, for tooling to import.
+	GenAPIIndex bool
+	// GenLimits emits the timeout, size and rate limits of every method, for
+	// edge gateways to configure their own from.
+	GenLimits bool
+	// LimitsJSON also emits the limits of GenLimits as JSON. It implies
+	// GenLimits.
+	LimitsJSON bool
+	// Strict fails the generation on unknown parameters and options, proto
+	// files without go_package, inputs and outputs declared in no file of
+	// the request, and names sanitized into Go identifiers, instead of
+	// generating questionable code.
+	Strict bool
+	// Amalgamate merges the Go files generated into each output directory,
+	// tests and files with build constraints aside, into a single
+	// <package>.gen.go, for tools embedding a server in a binary of their
+	// own. It applies to every service of the protoc run.
+	Amalgamate bool
+	// StreamSpanEvents makes the stream metrics of GenStreamMetrics also
+	// record the lifecycle of streams as span events. It implies
+	// GenStreamMetrics.
+	StreamSpanEvents bool
+	// GenRegistrationGuard emits a registration_guard.go per Go package,
+	// listing the services generated into it and checking that a server
+	// registers all of them.
+	GenRegistrationGuard bool
+	// GenStartupBanner makes the server bootstrap log a banner once
+	// serving, with the build info, the middleware installed and the
+	// addresses listened on. It implies GenServer and GenBuildInfo.
+	GenStartupBanner bool
+	// GenInterceptorTests emits unit tests of the recovery, logging, quota,
+	// tenancy and maintenance interceptors generated for the service, run
+	// against fake handlers.
+	GenInterceptorTests bool
+	// GenLifecycle emits a lifecycle manager starting components in order
+	// and stopping them in reverse, which the server bootstrap runs its
+	// listeners, servers and the components of the service with. It implies
+	// GenServer.
+	GenLifecycle bool
+	// GenServer emits a bootstrap serving the service, configured from the
+	// environment.
+	GenServer bool
+	// ConstructorStyle adds a logger and a clock to the dependencies of the
+	// service struct, and a New<Service>Service constructor taking them as a
+	// "deps" struct or as functional "options". The struct is left without
+	// constructor when empty.
+	ConstructorStyle string
+	// Layout is the shape of the service file: "struct" implements every
+	// method on the service struct, "handlers" generates a handler func per
+	// method and a router composing them.
+	Layout string
+	// Support is where helpers shared by the services of a Go package, the
+	// builders, are generated: "inline" in the files of the first service
+	// needing them, "shared" in a file per helper, written identically by
+	// every protoc run generating it, so that runs can target the same
+	// package.
+	Support string
+	// TodoFormat, when set, renders TODO comments with fmt, filling its two
+	// %s verbs with the service or method name and the note.
+	TodoFormat string
+	// StubBehavior is what stub bodies do until implemented: "empty"
+	// succeeds with empty outputs, "unimplemented" fails with
+	// codes.Unimplemented.
+	StubBehavior string
+	// ErrStyle is how stub bodies demonstrate failing: "status" returns
+	// status errors, "wrapped" wraps internal errors for the error mapper.
+	// Stubs show neither when empty.
+	ErrStyle string
+	// DeadlineReserve is the default time kept from the deadline of calls to
+	// methods with (service_gen.downstream) for the work left after their
+	// downstream calls return.
+	DeadlineReserve time.Duration
+	// ConfigBackend is how the server bootstrap loads its configuration:
+	// "env" reads environment variables, "viper" a YAML file with
+	// environment overrides and hot reloading.
+	ConfigBackend string
+	// Reload makes the server bootstrap pass reloadable settings to a Reload
+	// hook of the service on SIGHUP. It implies GenServer.
+	Reload bool
+	// EnvPresets are the environments, e.g. dev, staging and prod, the server
+	// bootstrap has configuration presets for, selected by APP_ENV. They
+	// imply GenServer.
+	EnvPresets []string
+	// BinaryLog makes the server bootstrap write gRPC binary logs to a
+	// rotated file, for the methods configured. It implies GenServer.
+	BinaryLog bool
+	// Systemd lets the server bootstrap use a listener inherited through
+	// systemd socket activation.
+	Systemd bool
+	// GenManifest emits a manifest.json describing every generated file and
+	// the options in effect.
+	GenManifest bool
+}
+
+// parseParameter parses the comma separated key=value parameter string.
+// List parameters take the items following them, see joinLists.
+// When it names a config file, the options of the file are used for any
+// key the parameter string does not set, and the file's per-service
+// overrides are returned keyed by service name.
+func parseParameter(parameter string) (url.Values, map[string]url.Values, error) {
+	// Wrappers on Windows may pass the parameter with the CRLF ending the
+	// line they read it from.
+	param, err := url.ParseQuery(joinLists(strings.TrimRight(parameter, "\r\n")))
+	if err != nil {
+		return nil, nil, errors.New("invalid parameter: " + err.Error())
+	}
+	if param.Get("config") == "" {
+		return param, nil, nil
+	}
+
+	cfg, err := loadConfig(param.Get("config"))
+	if err != nil {
+		return nil, nil, errors.New("unable to load config: " + err.Error())
+	}
+	return mergeValues(cfg.options, param), cfg.overrides, nil
+}
+
+// mergeValues returns base with the keys set in top replaced.
+func mergeValues(base, top url.Values) url.Values {
+	merged := url.Values{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range top {
+		merged[k] = v
+	}
+	return merged
+}
+
+// parseOptions reads the options from the parsed parameters.
+func parseOptions(param url.Values) (options, error) {
+	o := options{
+		GoPrefix:      "protos",
+		GoPackageName: "services",
+		GoImport:      "",
+	}
+
+	if GoPrefix := param.Get("GoPrefix"); len(GoPrefix) > 0 {
+		o.GoPrefix = GoPrefix
+	}
+	if GoPackageName := param.Get("GoPackageName"); len(GoPackageName) > 0 {
+		o.GoPackageName = GoPackageName
+	}
+	if GoImport := param.Get("GoImport"); len(GoImport) > 0 {
+		o.GoImport = GoImport
+	}
+	o.TemplateDir = param.Get("template_dir")
+	o.StubsDir = param.Get("stubs_dir")
+	o.OutputDir = slashPath(param.Get("output_dir"))
+	if o.OutputDir == "" {
+		o.OutputDir = "."
+	}
+	switch o.Paths = param.Get("paths"); o.Paths {
+	case "", "source_relative", "import":
+	default:
+		return o, errors.New("invalid value for paths: " + o.Paths)
+	}
+	switch o.ConstructorStyle = param.Get("constructor_style"); o.ConstructorStyle {
+	case "", "deps", "options":
+	default:
+		return o, errors.New("invalid value for constructor_style: " + o.ConstructorStyle)
+	}
+	switch o.Layout = param.Get("layout"); o.Layout {
+	case "":
+		o.Layout = "struct"
+	case "struct", "handlers":
+	default:
+		return o, errors.New("invalid value for layout: " + o.Layout)
+	}
+	switch o.Support = param.Get("support"); o.Support {
+	case "":
+		o.Support = "inline"
+	case "inline", "shared":
+	default:
+		return o, errors.New("invalid value for support: " + o.Support)
+	}
+	if o.TodoFormat = param.Get("todo_format"); o.TodoFormat != "" {
+		if strings.Contains(fmt.Sprintf(o.TodoFormat, "", ""), "%!") {
+			return o, errors.New("invalid value for todo_format: " + o.TodoFormat)
+		}
+	}
+	switch o.StubBehavior = param.Get("stub_behavior"); o.StubBehavior {
+	case "":
+		o.StubBehavior = "empty"
+	case "empty", "unimplemented":
+	default:
+		return o, errors.New("invalid value for stub_behavior: " + o.StubBehavior)
+	}
+	switch o.ErrStyle = param.Get("errstyle"); o.ErrStyle {
+	case "", "status", "wrapped":
+	default:
+		return o, errors.New("invalid value for errstyle: " + o.ErrStyle)
+	}
+	o.DeadlineReserve = 50 * time.Millisecond
+	if v := param.Get("deadline_reserve"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return o, errors.New("invalid value for deadline_reserve: " + err.Error())
+		}
+		if d < 0 {
+			return o, errors.New("invalid value for deadline_reserve: " + v)
+		}
+		o.DeadlineReserve = d
+	}
+	switch o.ConfigBackend = param.Get("config_backend"); o.ConfigBackend {
+	case "":
+		o.ConfigBackend = "env"
+	case "env", "viper":
+	default:
+		return o, errors.New("invalid value for config_backend: " + o.ConfigBackend)
+	}
+	switch o.Backup = param.Get("backup"); o.Backup {
+	case "", "bak", "diff":
+	default:
+		return o, errors.New("invalid value for backup: " + o.Backup)
+	}
+	switch param.Get("feature_flag_code") {
+	case "", "unimplemented":
+		o.FeatureFlagCode = "Unimplemented"
+	case "failed_precondition":
+		o.FeatureFlagCode = "FailedPrecondition"
+	default:
+		return o, errors.New("invalid value for feature_flag_code: " + param.Get("feature_flag_code"))
+	}
+	bools := map[string]*bool{
+		"dry_run":                &o.DryRun,
+		"skip_existing":          &o.SkipExisting,
+		"gen_client":             &o.GenClient,
+		"gen_conn_manager":       &o.GenConnManager,
+		"gen_service_config":     &o.GenServiceConfig,
+		"gen_smoke":              &o.GenSmoke,
+		"gen_chaos":              &o.GenChaos,
+		"gen_recorder":           &o.GenRecorder,
+		"gen_maintenance":        &o.GenMaintenance,
+		"gen_shadow":             &o.GenShadow,
+		"gen_canary":             &o.GenCanary,
+		"gen_ab_router":          &o.GenABRouter,
+		"gen_quota":              &o.GenQuota,
+		"gen_tenancy":            &o.GenTenancy,
+		"gen_errmap":             &o.GenErrMap,
+		"gen_catalog":            &o.GenCatalog,
+		"gen_manifest":           &o.GenManifest,
+		"gen_pgv":                &o.GenPGV,
+		"gen_builders":           &o.GenBuilders,
+		"gen_switches":           &o.GenSwitches,
+		"gen_subscriptions":      &o.GenSubscriptions,
+		"gen_test_server":        &o.GenTestServer,
+		"gen_ctxkeys":            &o.GenCtxKeys,
+		"gen_errreport":          &o.GenErrReport,
+		"log_payloads":           &o.LogPayloads,
+		"gen_debug_strings":      &o.GenDebugStrings,
+		"gen_otel_metrics":       &o.GenOTelMetrics,
+		"gen_stats":              &o.GenStats,
+		"gen_stream_metrics":     &o.GenStreamMetrics,
+		"gen_trace_headers":      &o.GenTraceHeaders,
+		"gen_events":             &o.GenEvents,
+		"gen_build_info":         &o.GenBuildInfo,
+		"gen_policy":             &o.GenPolicy,
+		"policy_json":            &o.PolicyJSON,
+		"gen_dep_health":         &o.GenDepHealth,
+		"gen_legacy_shims":       &o.GenLegacyShims,
+		"gen_baggage":            &o.GenBaggage,
+		"gen_ops":                &o.GenOps,
+		"gen_normalize":          &o.GenNormalize,
+		"gen_update_diff":        &o.GenUpdateDiff,
+		"gen_api_index":          &o.GenAPIIndex,
+		"gen_limits":             &o.GenLimits,
+		"limits_json":            &o.LimitsJSON,
+		"gen_lifecycle":          &o.GenLifecycle,
+		"gen_interceptor_tests":  &o.GenInterceptorTests,
+		"gen_startup_banner":     &o.GenStartupBanner,
+		"gen_registration_guard": &o.GenRegistrationGuard,
+		"stream_span_events":     &o.StreamSpanEvents,
+		"amalgamate":             &o.Amalgamate,
+		"gen_server":             &o.GenServer,
+		"systemd":                &o.Systemd,
+		"reload":                 &o.Reload,
+		"binary_log":             &o.BinaryLog,
+		"strict":                 &o.Strict,
+	}
+	for key, dst := range bools {
+		b, err := parseBool(param, key)
+		if err != nil {
+			return o, err
+		}
+		*dst = b
+	}
+	if unknown := unknownParams(param, bools); o.Strict && len(unknown) > 0 {
+		return o, errors.New("strict mode: unknown parameters: " + strings.Join(unknown, ", "))
+	}
+	if o.GenConnManager || o.GenSubscriptions {
+		o.GenClient = true
+	}
+	o.EnvPresets = splitList(param["env_presets"])
+	if err := validatePresets(o.EnvPresets); err != nil {
+		return o, err
+	}
+	if o.StreamSpanEvents {
+		o.GenStreamMetrics = true
+	}
+	if o.GenStartupBanner {
+		o.GenBuildInfo = true
+	}
+	if o.Reload || o.BinaryLog || o.GenOps || o.GenLifecycle || o.GenStartupBanner || len(o.EnvPresets) > 0 {
+		o.GenServer = true
+	}
+	if o.LogPayloads {
+		o.GenErrReport = true
+	}
+	if o.PolicyJSON {
+		o.GenPolicy = true
+	}
+	if o.LimitsJSON {
+		o.GenLimits = true
+	}
+	if o.ErrStyle == "wrapped" {
+		o.GenErrMap = true
+	}
+
+	return o, nil
+}
+
+// parseBool reads a boolean parameter, defaulting to false when unset.
+func parseBool(param url.Values, key string) (bool, error) {
+	v := param.Get(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.New("invalid value for " + key + ": " + err.Error())
+	}
+	return b, nil
+}
+
+// validate rejects options the templates cannot turn into valid Go.
+func (o options) validate() error {
+	if !token.IsIdentifier(o.GoPrefix) {
+		return errors.New("invalid value for GoPrefix: " + o.GoPrefix)
+	}
+	if !token.IsIdentifier(o.GoPackageName) {
+		return errors.New("invalid value for GoPackageName: " + o.GoPackageName)
+	}
+	return nil
+}
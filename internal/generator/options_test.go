@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseParameter(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		want      url.Values
+	}{
+		{
+			name:      "flags",
+			parameter: "GoPrefix=pb,gen_client=true",
+			want:      url.Values{"GoPrefix": {"pb"}, "gen_client": {"true"}},
+		},
+		{
+			name:      "lists",
+			parameter: "methods=Store.Get*,Store.List,gen_client=true",
+			want:      url.Values{"methods": {"Store.Get*,Store.List"}, "gen_client": {"true"}},
+		},
+		{
+			name:      "todo format",
+			parameter: "todo_format=TODO(%s): %s [JIRA-____],gen_client=true",
+			want:      url.Values{"todo_format": {"TODO(%s): %s [JIRA-____]"}, "gen_client": {"true"}},
+		},
+		{
+			name:      "todo format with commas",
+			parameter: "gen_client=true,todo_format=%[2]s, by %[1]s & co+",
+			want:      url.Values{"todo_format": {"%[2]s, by %[1]s & co+"}, "gen_client": {"true"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := parseParameter(tt.parameter)
+			if err != nil {
+				t.Fatalf("parseParameter(%q): %v", tt.parameter, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseParameter(%q) = %v, want %v", tt.parameter, got, tt.want)
+			}
+			if _, err := parseOptions(got); err != nil {
+				t.Errorf("parseOptions: %v", err)
+			}
+		})
+	}
+}
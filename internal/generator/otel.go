@@ -0,0 +1,127 @@
+package generator
+
+var otelMetricsTmpl = newTemplate("otel-metrics", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}Metrics records OpenTelemetry metrics of the calls served, named
+// after the RPC semantic conventions so that they line up with the spans
+// of tracing instrumentation such as otelgrpc.
+type {{.Name}}Metrics struct {
+	duration metric.Float64Histogram
+	received metric.Int64Counter
+	sent     metric.Int64Counter
+}
+
+// New{{.Name}}Metrics creates the instruments of {{.Name}} with the meter
+// provider, otel.GetMeterProvider() being used when it is nil.
+func New{{.Name}}Metrics(provider metric.MeterProvider) (*{{.Name}}Metrics, error) {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter("{{.FullName}}")
+
+	var (
+		m   {{.Name}}Metrics
+		err error
+	)
+	m.duration, err = meter.Float64Histogram("rpc.server.duration",
+		metric.WithDescription("Duration of the calls served."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		return nil, err
+	}
+	m.received, err = meter.Int64Counter("rpc.server.messages_received",
+		metric.WithDescription("Messages received on streams."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return nil, err
+	}
+	m.sent, err = meter.Int64Counter("rpc.server.messages_sent",
+		metric.WithDescription("Messages sent on streams."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// {{.LowerName}}MethodAttrs returns the attributes of calls to fullMethod,
+// e.g. "/{{.FullName}}/Method".
+func {{.LowerName}}MethodAttrs(fullMethod string) []attribute.KeyValue {
+	service, method := "{{.FullName}}", strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		service, method = method[:i], method[i+1:]
+	}
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	}
+}
+
+// done records the duration of a call that returned err.
+func (m *{{.Name}}Metrics) done(ctx context.Context, attrs []attribute.KeyValue, err error, start time.Time) {
+	attrs = append(attrs, attribute.Int64("rpc.grpc.status_code", int64(status.Code(err))))
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+	m.duration.Record(ctx, elapsed, metric.WithAttributes(attrs...))
+}
+
+// UnaryInterceptor records the duration of unary calls.
+func (m *{{.Name}}Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.done(ctx, {{.LowerName}}MethodAttrs(info.FullMethod), err, start)
+		return resp, err
+	}
+}
+
+// StreamInterceptor records the duration of stream calls and counts the
+// messages they receive and send.
+func (m *{{.Name}}Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		attrs := {{.LowerName}}MethodAttrs(info.FullMethod)
+		err := handler(srv, &{{.LowerName}}MeteredStream{ServerStream: ss, metrics: m, attrs: metric.WithAttributes(attrs...)})
+		m.done(ss.Context(), attrs, err, start)
+		return err
+	}
+}
+
+// {{.LowerName}}MeteredStream counts the messages going through a stream.
+type {{.LowerName}}MeteredStream struct {
+	grpc.ServerStream
+	metrics *{{.Name}}Metrics
+	attrs   metric.MeasurementOption
+}
+
+func (s *{{.LowerName}}MeteredStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.metrics.received.Add(s.Context(), 1, s.attrs)
+	}
+	return err
+}
+
+func (s *{{.LowerName}}MeteredStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.metrics.sent.Add(s.Context(), 1, s.attrs)
+	}
+	return err
+}
+`)
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// owner is an entry of the AUTHORS block of generated files.
+type owner struct {
+	Name string `json:"name"`
+	// Methods lists the methods owned, or is empty when the owner is
+	// responsible for the whole service.
+	Methods string `json:"methods,omitempty"`
+}
+
+// Owners lists the owners of the service and of its methods in order of
+// appearance.
+func (p Service) Owners() []owner {
+	var names []string
+	methods := make(map[string][]string)
+	for _, m := range p.Methods {
+		o := m.Owner()
+		if o == "" {
+			continue
+		}
+		if _, ok := methods[o]; !ok {
+			names = append(names, o)
+		}
+		methods[o] = append(methods[o], m.GetName())
+	}
+
+	var owners []owner
+	if so := p.Owner(); so != "" {
+		owners = append(owners, owner{Name: so})
+	}
+	for _, name := range names {
+		if name == p.Owner() {
+			continue
+		}
+		owners = append(owners, owner{Name: name, Methods: strings.Join(methods[name], ", ")})
+	}
+	return owners
+}
+
+// Todo returns the marker of TODO comments in the method's stub, which
+// names the owner when there is one, e.g. TODO(storage-team).
+func (m method) Todo() string {
+	if o := m.Owner(); o != "" {
+		return "TODO(" + o + ")"
+	}
+	return "TODO"
+}
+
+// Todo returns the marker of TODO comments concerning the whole service.
+func (p Service) Todo() string {
+	if o := p.Owner(); o != "" {
+		return "TODO(" + o + ")"
+	}
+	return "TODO"
+}
+
+// TodoNote renders a TODO comment about the method: the marker followed by
+// note, or the TodoFormat filled with the method's full name and note.
+func (m method) TodoNote(note string) string {
+	if f := m.service.TodoFormat; f != "" {
+		return fmt.Sprintf(f, m.service.GetName()+"."+m.GetName(), note)
+	}
+	return m.Todo() + ": " + note
+}
+
+// TodoNote renders a TODO comment about the whole service.
+func (p Service) TodoNote(note string) string {
+	if p.TodoFormat != "" {
+		return fmt.Sprintf(p.TodoFormat, p.GetName(), note)
+	}
+	return p.Todo() + ": " + note
+}
@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// outputBase returns the name the files of svc are generated under, before
+// their suffix. Without a paths mode the names are flat, as they always
+// were. With "source_relative" they go in the directory of the proto file,
+// and with "import" in a GoPackageName directory under the go_package
+// import path of the proto file, so that services of different packages
+// sharing a name no longer collide.
+func outputBase(f *descriptor.FileDescriptorProto, svc *descriptor.ServiceDescriptorProto, o options) string {
+	name := strings.ToLower(svc.GetName())
+	switch o.Paths {
+	case "source_relative":
+		return path.Join(path.Dir(slashPath(f.GetName())), name)
+	case "import":
+		dir := path.Dir(slashPath(f.GetName()))
+		if pkg := f.GetOptions().GetGoPackage(); pkg != "" {
+			// go_package may name the package after the import path, e.g.
+			// "example.com/gen/store;store".
+			if i := strings.Index(pkg, ";"); i >= 0 {
+				pkg = pkg[:i]
+			}
+			dir = pkg
+		}
+		return path.Join(dir, o.GoPackageName, name)
+	}
+	return name
+}
+
+// outputDir returns the directory, relative to OutputDir, the files of the
+// service are generated in.
+func (p Service) outputDir() string {
+	return path.Join(p.OutputDir, path.Dir(p.fileName))
+}
+
+// slashPath returns name with its backslashes turned into slashes. The
+// names of protoc requests and responses are slash separated on every
+// platform, but wrappers on Windows may pass native paths, which would
+// otherwise generate different names there.
+func slashPath(name string) string {
+	return strings.Replace(name, `\`, "/", -1)
+}
+
+// diskPath returns the path on disk of the generated file name, relative to
+// dir, e.g. the OutputDir of its service.
+func diskPath(dir, name string) string {
+	return filepath.Join(filepath.FromSlash(dir), filepath.FromSlash(name))
+}
+
+// unixNewlines returns s with its CRLF line endings turned into LF ones, as
+// in files checked out or edited on Windows.
+func unixNewlines(s string) string {
+	return strings.Replace(s, "\r\n", "\n", -1)
+}
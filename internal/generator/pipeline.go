@@ -0,0 +1,368 @@
+// Package generator implements protoc-gen-grpc-go-service: it turns a
+// CodeGeneratorRequest into Go scaffolding for the services it describes.
+//
+// The pipeline is split into Decode, Parse, Generate and Encode so that each
+// step can be exercised on its own; Run chains them.
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// Run reads a request from r and writes the response to w. Requests that
+// cannot be generated are answered with a response carrying the error, as
+// protoc expects; only failures to write the response are returned.
+func Run(r io.Reader, w io.Writer) error {
+	req, err := Decode(r)
+	if err != nil {
+		return Encode(errorResponse(err), w)
+	}
+	return Encode(generate(req), w)
+}
+
+// generate parses and generates req, turning errors and panics into an
+// error response.
+func generate(req *plugin.CodeGeneratorRequest) (resp *plugin.CodeGeneratorResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp = errorResponse(fmt.Errorf("unable to generate: %v", r))
+		}
+	}()
+
+	ps, err := Parse(req)
+	if err != nil {
+		return errorResponse(err)
+	}
+	resp, err = Generate(ps)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return resp
+}
+
+// errorResponse returns a response reporting err to protoc.
+func errorResponse(err error) *plugin.CodeGeneratorResponse {
+	return &plugin.CodeGeneratorResponse{Error: proto.String(err.Error())}
+}
+
+// Decode unmarshals the protobuf request.
+func Decode(r io.Reader) (*plugin.CodeGeneratorRequest, error) {
+	var req plugin.CodeGeneratorRequest
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.New("unable to read request: " + err.Error())
+	}
+	if err := proto.Unmarshal(input, &req); err != nil {
+		return nil, errors.New("unable to unmarshal request: " + err.Error())
+	}
+	return &req, nil
+}
+
+// Parse wrangles the request to fit needs of the templates, returning one
+// Service per service of the files in the request, or of those selected by
+// the services and methods parameters.
+func Parse(req *plugin.CodeGeneratorRequest) ([]*Service, error) {
+	var ps []*Service
+	param, overrides, err := parseParameter(req.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+	selected, err := parseFilter(param)
+	if err != nil {
+		return nil, err
+	}
+	messages := indexMessages(req.GetProtoFile())
+	enums := indexEnums(req.GetProtoFile())
+	for _, pf := range req.GetProtoFile() {
+		for _, svc := range pf.GetService() {
+			if !selected.service(pf.GetPackage(), svc.GetName()) {
+				continue
+			}
+			if err := validateService(pf.GetName(), svc); err != nil {
+				return nil, err
+			}
+			opts, err := parseOptions(mergeValues(param, overrides[svc.GetName()]))
+			if err != nil {
+				return nil, err
+			}
+			if err := opts.validate(); err != nil {
+				return nil, err
+			}
+			p := &Service{
+				ServiceDescriptorProto: *svc,
+				wireName:               svc.GetName(),
+				PackageName:            pf.GetPackage(),
+				ProtoName:              slashPath(pf.GetName()),
+				fileName:               outputBase(pf, svc, opts),
+				file:                   pf,
+				options:                opts,
+				messages:               messages,
+				enums:                  enums,
+			}
+			if name := goIdent(svc.GetName()); name != svc.GetName() {
+				if !opts.Strict {
+					warnf("%s: service %s is generated as %s", pf.GetName(), svc.GetName(), name)
+				}
+				p.Name = proto.String(name)
+			}
+			names := make(map[string]string)
+			for _, mtd := range p.ServiceDescriptorProto.GetMethod() {
+				m := method{
+					MethodDescriptorProto: *mtd,
+					wireName:              mtd.GetName(),
+					serviceName:           p.GetName(),
+					messages:              messages,
+					service:               p,
+				}
+				name := goIdent(mtd.GetName())
+				if prev, ok := names[name]; ok {
+					return nil, fmt.Errorf("%s: %s: methods %s and %s are both generated as %s", pf.GetName(), svc.GetName(), prev, mtd.GetName(), name)
+				}
+				names[name] = mtd.GetName()
+				if name != mtd.GetName() {
+					if !opts.Strict {
+						warnf("%s: method %s.%s is generated as %s", pf.GetName(), svc.GetName(), mtd.GetName(), name)
+					}
+					m.Name = proto.String(name)
+				}
+				p.Methods = append(p.Methods, m)
+				if len(selected.methods) > 0 && selected.method(svc.GetName(), mtd.GetName()) {
+					p.scaffolded = append(p.scaffolded, m)
+				}
+			}
+			if len(p.scaffolded) == 0 && len(selected.methods) > 0 {
+				continue
+			}
+			if err := p.checkOptionNames(); err != nil {
+				return nil, err
+			}
+			if opts.GenServiceConfig {
+				if p.serviceConfig, err = p.renderServiceConfig(); err != nil {
+					return nil, err
+				}
+			}
+
+			ps = append(ps, p)
+		}
+
+	}
+	selected.warnUnmatched()
+	assignBuilders(ps)
+	if err := checkStrict(ps); err != nil {
+		return nil, err
+	}
+	if err := checkSymbols(ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// validateService rejects services the templates cannot turn into valid Go.
+// Names that are not valid Go identifiers are sanitized rather than
+// rejected.
+func validateService(file string, svc *descriptor.ServiceDescriptorProto) error {
+	if svc.GetName() == "" {
+		return fmt.Errorf("%s: missing service name", file)
+	}
+	for _, m := range svc.GetMethod() {
+		if m.GetName() == "" {
+			return fmt.Errorf("%s: %s: missing method name", file, svc.GetName())
+		}
+		if m.GetInputType() == "" || m.GetOutputType() == "" {
+			return fmt.Errorf("%s: %s.%s: missing input or output type", file, svc.GetName(), m.GetName())
+		}
+	}
+	return nil
+}
+
+// fileOwner is what a generated file is generated for. Service sets the
+// options the file is written with, e.g. its OutputDir, while covers lists
+// the services whose code it holds, with the methods rendered for each:
+// several of them for files shared by services, such as those merged by
+// amalgamate.
+type fileOwner struct {
+	*Service
+	covers []coverage
+}
+
+// coverage is a service and its methods rendered into a file.
+type coverage struct {
+	service *Service
+	methods []method
+}
+
+// ownedBy returns the owner of a file holding methods of p.
+func ownedBy(p *Service, methods []method) fileOwner {
+	return fileOwner{Service: p, covers: []coverage{{service: p, methods: methods}}}
+}
+
+// Generate executes the templates of every service. Files are rendered by
+// parallel workers, each reusing a buffer of its own, and copied out of it
+// exactly once, after formatting. The response lists them in a stable
+// order regardless.
+func Generate(ps []*Service) (*plugin.CodeGeneratorResponse, error) {
+	type job struct {
+		p *Service
+		f serviceFile
+		// support, when set, is rendered instead of f.
+		support *supportFile
+		// scaffold, when set, is the method whose implementation is rendered
+		// instead of f.
+		scaffold *method
+	}
+	var jobs []job
+	for _, p := range ps {
+		for _, f := range serviceFiles {
+			if f.enabled == nil || f.enabled(p.options) {
+				jobs = append(jobs, job{p: p, f: f})
+			}
+		}
+		for _, sf := range p.supportFiles() {
+			sf := sf
+			jobs = append(jobs, job{p: p, f: serviceFile{tmpl: supportBuildersTmpl}, support: &sf})
+		}
+		for i := range p.scaffolded {
+			jobs = append(jobs, job{p: p, f: serviceFile{tmpl: tmpl}, scaffold: &p.scaffolded[i]})
+		}
+	}
+
+	files := make([]*plugin.CodeGeneratorResponse_File, len(jobs))
+	errs := make([]error, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < runtime.GOMAXPROCS(0) && n < len(jobs); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := &bytes.Buffer{}
+			for i := range next {
+				files[i], errs[i] = generateFile(w, jobs[i].p, jobs[i].f, jobs[i].support, jobs[i].scaffold)
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	owners := make([]fileOwner, len(jobs))
+	for i, j := range jobs {
+		switch {
+		case j.support != nil:
+			owners[i] = ownedBy(j.p, nil)
+		case j.scaffold != nil:
+			owners[i] = ownedBy(j.p, []method{*j.scaffold})
+		default:
+			owners[i] = ownedBy(j.p, j.p.Methods)
+		}
+	}
+	guards, guardOwners, err := registrationGuards(ps)
+	if err != nil {
+		return nil, err
+	}
+	files, owners = append(files, guards...), append(owners, guardOwners...)
+	seen := make(map[string]fileOwner)
+	for i, f := range files {
+		if prev, ok := seen[f.GetName()]; ok {
+			return nil, fmt.Errorf("%s and %s both generate %s; set paths=source_relative or paths=import to tell them apart", prev.FullName(), owners[i].FullName(), f.GetName())
+		}
+		seen[f.GetName()] = owners[i]
+	}
+	if wantsAmalgamation(ps) {
+		var err error
+		if files, owners, err = amalgamate(files, owners); err != nil {
+			return nil, err
+		}
+	}
+	files, owners, skipped := skipExisting(files, owners)
+	backups, backupOwners := backupFiles(files, owners)
+	files, owners = append(files, backups...), append(owners, backupOwners...)
+	if wantsManifest(ps) {
+		m, err := manifestFor(ps, files, owners)
+		if err != nil {
+			return nil, errors.New("unable to build manifest: " + err.Error())
+		}
+		files = append(files, m)
+	}
+	if wantsDryRun(ps) {
+		report, err := dryRunReport(files, skipped)
+		if err != nil {
+			return nil, errors.New("unable to build dry run report: " + err.Error())
+		}
+		files = []*plugin.CodeGeneratorResponse_File{report}
+	}
+	return &plugin.CodeGeneratorResponse{File: files}, nil
+}
+
+// generateFile renders f for p, or sf or the implementation of m when set,
+// using w as scratch space.
+func generateFile(w *bytes.Buffer, p *Service, f serviceFile, sf *supportFile, m *method) (*plugin.CodeGeneratorResponse_File, error) {
+	t := f.tmpl
+	if p.TemplateDir != "" {
+		var err error
+		if t, err = cachedOverrides(t, p.TemplateDir); err != nil {
+			return nil, errors.New("unable to parse template overrides: " + err.Error())
+		}
+	}
+
+	data, fileName := p, p.fileName+f.suffix
+	if sf != nil {
+		shared := *p
+		shared.builders = []builder{sf.builder}
+		data, fileName = &shared, sf.name(p)
+	}
+	name := t.Name()
+	if m != nil {
+		scaffold := *p
+		scaffold.Methods = []method{*m}
+		data, fileName, name = &scaffold, m.fileName(), "method_file"
+	}
+
+	w.Reset()
+	if err := t.ExecuteTemplate(w, name, data); err != nil {
+		return nil, errors.New("unable to execute template: " + err.Error())
+	}
+
+	content := w.Bytes()
+	if strings.HasSuffix(fileName, ".go") {
+		var err error
+		if content, err = formatSource(content, p.importNames()); err != nil {
+			return nil, errors.New("unable to go-fmt output: " + err.Error())
+		}
+	}
+	return &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(fileName),
+		Content: proto.String(string(content)),
+	}, nil
+}
+
+// Encode marshals the protobuf response.
+func Encode(resp *plugin.CodeGeneratorResponse, w io.Writer) error {
+	outBytes, err := proto.Marshal(resp)
+	if err != nil {
+		return errors.New("unable to marshal response to protobuf: " + err.Error())
+	}
+
+	if _, err := w.Write(outBytes); err != nil {
+		return errors.New("unable to write response: " + err.Error())
+	}
+	return nil
+}
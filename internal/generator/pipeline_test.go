@@ -0,0 +1,288 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// testMethod returns a method from Request to Response.
+func testMethod(name string, clientStreaming, serverStreaming bool) *descriptor.MethodDescriptorProto {
+	return &descriptor.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(".test.Request"),
+		OutputType:      proto.String(".test.Response"),
+		ClientStreaming: proto.Bool(clientStreaming),
+		ServerStreaming: proto.Bool(serverStreaming),
+	}
+}
+
+// testService returns a service of methods.
+func testService(name string, methods ...*descriptor.MethodDescriptorProto) *descriptor.ServiceDescriptorProto {
+	return &descriptor.ServiceDescriptorProto{Name: proto.String(name), Method: methods}
+}
+
+// testFile returns a file of package test declaring Request, Response and
+// services.
+func testFile(name string, services ...*descriptor.ServiceDescriptorProto) *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String(name),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("example.com/gen/test")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Request"),
+				Field: []*descriptor.FieldDescriptorProto{{
+					Name:   proto.String("id"),
+					Number: proto.Int32(1),
+					Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+				}},
+			},
+			{Name: proto.String("Response")},
+		},
+		Service: services,
+	}
+}
+
+// testRequest returns a request generating files with param.
+func testRequest(param string, files ...*descriptor.FileDescriptorProto) *plugin.CodeGeneratorRequest {
+	req := &plugin.CodeGeneratorRequest{ProtoFile: files}
+	if param != "" {
+		req.Parameter = proto.String(param)
+	}
+	for _, f := range files {
+		req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+	}
+	return req
+}
+
+// generateFiles generates req, failing t on error, and returns the
+// contents of the generated files by name.
+func generateFiles(t testing.TB, req *plugin.CodeGeneratorRequest) map[string]string {
+	t.Helper()
+	resp := generate(req)
+	if resp.Error != nil {
+		t.Fatalf("generate: %s", resp.GetError())
+	}
+	files := make(map[string]string)
+	for _, f := range resp.GetFile() {
+		files[f.GetName()] = f.GetContent()
+	}
+	return files
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *plugin.CodeGeneratorRequest
+		// want maps the names of the files expected to the snippets they
+		// must contain.
+		want map[string][]string
+	}{
+		{
+			name: "unary",
+			req:  testRequest("", testFile("echo.proto", testService("Echo", testMethod("Say", false, false)))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"package services",
+					"// source: echo.proto",
+					"type EchoService struct{}",
+					"func (s EchoService) Say(ctx context.Context, input *protos.Request) (*protos.Response, error) {",
+				},
+			},
+		},
+		{
+			name: "client stream",
+			req:  testRequest("", testFile("echo.proto", testService("Echo", testMethod("Upload", true, false)))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"// Upload sends a single output for a streamed input.",
+					"func (s EchoService) Upload(stream protos.Echo_UploadServer) error {",
+					"stream.SendAndClose(",
+				},
+			},
+		},
+		{
+			name: "server stream",
+			req:  testRequest("", testFile("echo.proto", testService("Echo", testMethod("Watch", false, true)))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"// Watch streams output for a single input.",
+					"func (s EchoService) Watch(input *protos.Request, stream protos.Echo_WatchServer) error {",
+				},
+			},
+		},
+		{
+			name: "bidi stream",
+			req:  testRequest("", testFile("echo.proto", testService("Echo", testMethod("Chat", true, true)))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"// Chat streams outputs and listens to a stream of inputs.",
+					"func (s EchoService) Chat(stream protos.Echo_ChatServer) error {",
+				},
+			},
+		},
+		{
+			name: "empty request",
+			req:  &plugin.CodeGeneratorRequest{},
+			want: map[string][]string{},
+		},
+		{
+			name: "file without services",
+			req:  testRequest("", testFile("echo.proto")),
+			want: map[string][]string{},
+		},
+		{
+			name: "two services",
+			req: testRequest("GoPrefix=pb", testFile("shop.proto",
+				testService("Store", testMethod("Get", false, false)),
+				testService("Cart", testMethod("Add", false, false), testMethod("Items", false, true)),
+			)),
+			want: map[string][]string{
+				"store_service.go": {
+					"type StoreService struct{}",
+					"func (s StoreService) Get(ctx context.Context, input *pb.Request) (*pb.Response, error) {",
+				},
+				"cart_service.go": {
+					"type CartService struct{}",
+					"func (s CartService) Add(ctx context.Context, input *pb.Request) (*pb.Response, error) {",
+					"func (s CartService) Items(input *pb.Request, stream pb.Cart_ItemsServer) error {",
+				},
+			},
+		},
+		{
+			name: "todo format",
+			req:  testRequest("todo_format=TODO(%s): %s, see [JIRA-____],GoPrefix=pb", testFile("echo.proto", testService("Echo", testMethod("Say", false, false)))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"// TODO(Echo.Say): Do something with the input, see [JIRA-____]",
+					"func (s EchoService) Say(ctx context.Context, input *pb.Request) (*pb.Response, error) {",
+				},
+			},
+		},
+		{
+			name: "method filter",
+			req: testRequest("methods=Echo.Say", testFile("echo.proto", testService("Echo",
+				testMethod("Say", false, false),
+				testMethod("Chat", true, true),
+			))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"\techoUnimplemented\n",
+					"func (echoUnimplemented) Say(ctx context.Context, input *protos.Request) (*protos.Response, error) {",
+					"func (echoUnimplemented) Chat(stream protos.Echo_ChatServer) error {",
+				},
+				"echo_say_method.go": {
+					"func (s EchoService) Say(ctx context.Context, input *protos.Request) (*protos.Response, error) {",
+				},
+			},
+		},
+		{
+			name: "method filter with handlers",
+			req: testRequest("layout=handlers,methods=Echo.Say", testFile("echo.proto", testService("Echo",
+				testMethod("Say", false, false),
+				testMethod("Chat", true, true),
+			))),
+			want: map[string][]string{
+				"echo_service.go": {
+					"type EchoService struct{}",
+					"ChatFunc EchoChatFunc",
+					"for _, route := range echoRoutes {",
+				},
+				"echo_say_method.go": {
+					"echoRoutes = append(echoRoutes, func(r *EchoRouter, s EchoService) {",
+					"func NewEchoSayHandler(s EchoService) EchoSayFunc {",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := generateFiles(t, tt.req)
+			if len(files) != len(tt.want) {
+				var names []string
+				for name := range files {
+					names = append(names, name)
+				}
+				t.Fatalf("generated %v, want %d files", names, len(tt.want))
+			}
+			for name, snippets := range tt.want {
+				content, ok := files[name]
+				if !ok {
+					t.Fatalf("%s is not generated", name)
+				}
+				for _, s := range snippets {
+					if !strings.Contains(content, s) {
+						t.Errorf("%s does not contain %q:\n%s", name, s, content)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateMethodFiles(t *testing.T) {
+	file := testFile("echo.proto", testService("Echo",
+		testMethod("Say", false, false),
+		testMethod("Upload", true, false),
+		testMethod("Chat", true, true),
+	))
+	for _, layout := range []string{"struct", "handlers"} {
+		t.Run(layout, func(t *testing.T) {
+			first := generateFiles(t, testRequest("layout="+layout+",methods=Echo.Say", file))
+			second := generateFiles(t, testRequest("layout="+layout+",methods=Echo.Upload,Echo.Chat", file))
+			// Runs selecting other methods add the files of those methods
+			// and rewrite the service file as is, leaving the methods
+			// generated before in place.
+			if first["echo_service.go"] != second["echo_service.go"] {
+				t.Errorf("echo_service.go differs between runs:\n%s\n%s", first["echo_service.go"], second["echo_service.go"])
+			}
+			for _, name := range []string{"echo_upload_method.go", "echo_chat_method.go"} {
+				if _, ok := first[name]; ok {
+					t.Errorf("%s is generated by the first run", name)
+				}
+				if _, ok := second[name]; !ok {
+					t.Errorf("%s is not generated by the second run", name)
+				}
+			}
+			if _, ok := second["echo_say_method.go"]; ok {
+				t.Error("echo_say_method.go is generated by the second run")
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *plugin.CodeGeneratorRequest
+		want string
+	}{
+		{
+			name: "malformed parameter",
+			req:  testRequest("gen_client=true,GoPrefix=50%", testFile("echo.proto", testService("Echo", testMethod("Say", false, false)))),
+			want: "invalid parameter",
+		},
+		{
+			name: "missing method name",
+			req:  testRequest("", testFile("echo.proto", testService("Echo", &descriptor.MethodDescriptorProto{}))),
+			want: "missing method name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := generate(tt.req)
+			if !strings.Contains(resp.GetError(), tt.want) {
+				t.Errorf("error %q, want it to contain %q", resp.GetError(), tt.want)
+			}
+			if len(resp.GetFile()) > 0 {
+				t.Errorf("generated %d files along with the error", len(resp.GetFile()))
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"encoding/json"
+)
+
+// methodPolicy is the authorization policy of a method, as exported in
+// <service>_policy.json.
+type methodPolicy struct {
+	FullMethod      string   `json:"full_method"`
+	ClientStreaming bool     `json:"client_streaming"`
+	ServerStreaming bool     `json:"server_streaming"`
+	Idempotent      bool     `json:"idempotent"`
+	RequiredRoles   []string `json:"required_roles"`
+}
+
+// PolicyJSON returns the authorization policy of every method of the
+// service, keyed by full method name.
+func (p Service) PolicyJSON() (string, error) {
+	policy := make(map[string]methodPolicy)
+	for _, m := range p.Methods {
+		roles := m.RequiredRoles()
+		if roles == nil {
+			roles = []string{}
+		}
+		policy[m.FullMethod()] = methodPolicy{
+			FullMethod:      m.FullMethod(),
+			ClientStreaming: m.GetClientStreaming(),
+			ServerStreaming: m.GetServerStreaming(),
+			Idempotent:      m.Idempotent(),
+			RequiredRoles:   roles,
+		}
+	}
+	b, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// FullMethod returns the full name of the method on the wire, e.g.
+// "/pkg.Service/Method".
+func (m method) FullMethod() string {
+	return "/" + m.service.FullName() + "/" + m.wireName
+}
+
+var policyTmpl = newTemplate("policy", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+// The full names of the methods of {{.Name}}, as seen by interceptors.
+const (
+	{{- range .Methods }}
+	{{$.Name}}{{.Name}}FullMethod = "{{.FullMethod}}"
+	{{- end }}
+)
+
+// {{.Name}}MethodPolicy is the authorization policy of a method.
+type {{.Name}}MethodPolicy struct {
+	FullMethod      string
+	ClientStreaming bool
+	ServerStreaming bool
+	// Idempotent reports whether the method is marked idempotent or free of
+	// side effects.
+	Idempotent bool
+	// RequiredRoles are the roles a caller needs, from
+	// (service_gen.required_roles).
+	RequiredRoles []string
+}
+
+// {{.Name}}Policy lists the methods of {{.Name}} by full method name.
+var {{.Name}}Policy = map[string]{{.Name}}MethodPolicy{
+	{{- range .Methods }}
+	{{$.Name}}{{.Name}}FullMethod: {
+		FullMethod:      {{$.Name}}{{.Name}}FullMethod,
+		ClientStreaming: {{.GetClientStreaming}},
+		ServerStreaming: {{.GetServerStreaming}},
+		Idempotent:      {{.Idempotent}},
+		{{- with .RequiredRoles }}
+		RequiredRoles:   []string{ {{- range $i, $r := . }}{{ if $i }}, {{ end }}{{ printf "%q" $r }}{{ end -}} },
+		{{- end }}
+	},
+	{{- end }}
+}
+`)
+
+var policyJSONTmpl = newTemplate("policyjson", "{{.PolicyJSON}}\n")
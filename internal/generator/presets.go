@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// envPreset is the configuration generated for an environment of
+// env_presets.
+type envPreset struct {
+	Name       string
+	LogLevel   string
+	Reflection bool
+	RequireTLS bool
+}
+
+// presetName matches the environment names env_presets accepts.
+var presetName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// validatePresets rejects environment names that cannot be used as map
+// keys and environment variable values as is.
+func validatePresets(names []string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !presetName.MatchString(name) {
+			return errors.New("invalid value for env_presets: " + name)
+		}
+		if seen[name] {
+			return errors.New("invalid value for env_presets: duplicate " + name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Presets returns the configuration of every environment of env_presets.
+// Development environments log verbosely and expose reflection without
+// requiring TLS, production ones do the opposite, and the others sit in
+// between with reflection and TLS.
+func (p Service) Presets() []envPreset {
+	var presets []envPreset
+	for _, name := range p.EnvPresets {
+		switch name {
+		case "dev", "development", "local", "test":
+			presets = append(presets, envPreset{Name: name, LogLevel: "debug", Reflection: true})
+		case "prod", "production":
+			presets = append(presets, envPreset{Name: name, LogLevel: "warn", RequireTLS: true})
+		default:
+			presets = append(presets, envPreset{Name: name, LogLevel: "info", Reflection: true, RequireTLS: true})
+		}
+	}
+	return presets
+}
+
+// PresetList returns the environments of env_presets, for messages.
+func (p Service) PresetList() string {
+	return strings.Join(p.EnvPresets, ", ")
+}
+
+var presetsTmpl = newTemplate("presets", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"os"
+)
+
+// {{.Name}}EnvVar names the environment the configuration is preset for.
+const {{.Name}}EnvVar = "APP_ENV"
+
+// {{.Name}}Presets hold the configuration of each environment, which
+// Load{{.Name}}Config starts from before applying the environment
+// variables{{ if eq .ConfigBackend "viper" }} and the config file{{ end }}. They encode the policies of the
+// environments: adjust them to yours.
+var {{.Name}}Presets = map[string]{{.Name}}Config{
+	{{- range .Presets }}
+	"{{.Name}}": {
+		Env:        "{{.Name}}",
+		Addr:       ":8080",
+		SocketMode: 0660,
+		LogLevel:   "{{.LogLevel}}",
+		Reflection: {{.Reflection}},
+		RequireTLS: {{.RequireTLS}},
+	},
+	{{- end }}
+}
+
+// {{.LowerName}}EnvName returns the environment named by {{.Name}}EnvVar,
+// "{{index .EnvPresets 0}}" when it is unset.
+func {{.LowerName}}EnvName() string {
+	if env := os.Getenv({{.Name}}EnvVar); env != "" {
+		return env
+	}
+	return "{{index .EnvPresets 0}}"
+}
+
+// {{.Name}}Preset returns the preset of the environment named by
+// {{.Name}}EnvVar.
+func {{.Name}}Preset() ({{.Name}}Config, error) {
+	env := {{.LowerName}}EnvName()
+	cfg, ok := {{.Name}}Presets[env]
+	if !ok {
+		return cfg, fmt.Errorf("unknown %s %q, expected one of {{.PresetList}}", {{.Name}}EnvVar, env)
+	}
+	return cfg, nil
+}
+`)
@@ -0,0 +1,123 @@
+package generator
+
+var quotaTmpl = newTemplate("quota", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+{{ if not .GenTenancy }}
+// {{.Name}}TenantHeader is the metadata key carrying the tenant of a call.
+const {{.Name}}TenantHeader = "x-tenant-id"
+{{ end }}
+// {{.Name}}QuotaStore tracks the quotas of tenants. Implementations are
+// typically backed by a shared rate limiter so that every instance of the
+// service draws from the same budget.
+type {{.Name}}QuotaStore interface {
+	// Allow consumes one unit of the tenant's quota for method and reports
+	// whether the call may proceed.
+	Allow(ctx context.Context, tenant, method string) (bool, error)
+}
+
+// {{.Name}}Quota rejects {{.Name}} calls of tenants exceeding their quota
+// with codes.ResourceExhausted and a QuotaFailure detail.
+type {{.Name}}Quota struct {
+	// Store decides whether calls are within quota.
+	Store {{.Name}}QuotaStore
+	// Tenant extracts the tenant of a call. It defaults to {{if .GenTenancy}}the
+	// tenant stored by {{.Name}}Tenancy, then to {{end}}reading
+	// {{.Name}}TenantHeader from the incoming metadata.
+	Tenant func(ctx context.Context) string
+	// FailOpen lets calls through when Store fails, which is preferable when
+	// the store is less available than the service.
+	FailOpen bool
+}
+
+{{ if not .GenTenancy }}
+// {{.LowerName}}TenantFromMetadata reads the tenant from the incoming
+// metadata.
+func {{.LowerName}}TenantFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get({{.Name}}TenantHeader); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+{{ end }}
+
+// check returns the error rejecting a call to the full method name, if any.
+func (q *{{.Name}}Quota) check(ctx context.Context, fullMethod string) error {
+	if !strings.HasPrefix(fullMethod, "/{{.FullName}}/") {
+		return nil
+	}
+	tenant := q.tenant(ctx)
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+
+	ok, err := q.Store.Allow(ctx, tenant, method)
+	if err != nil {
+		if q.FailOpen {
+			return nil
+		}
+		return status.Errorf(codes.Unavailable, "{{.Name}}: quota check failed: %v", err)
+	}
+	if ok {
+		return nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "{{.Name}}: quota exceeded")
+	if detailed, err := st.WithDetails(&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{
+			{
+				Subject:     "tenant:" + tenant,
+				Description: "quota exceeded for " + method,
+			},
+		},
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// tenant returns the tenant of the call.
+func (q *{{.Name}}Quota) tenant(ctx context.Context) string {
+	if q.Tenant != nil {
+		return q.Tenant(ctx)
+	}
+	{{- if .GenTenancy }}
+	if tenant := {{.Name}}TenantFromContext(ctx); tenant != "" {
+		return tenant
+	}
+	{{- end }}
+	return {{.LowerName}}TenantFromMetadata(ctx)
+}
+
+// UnaryInterceptor enforces quotas on unary calls.
+func (q *{{.Name}}Quota) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := q.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor enforces quotas on the creation of streams.
+func (q *{{.Name}}Quota) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := q.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+`)
@@ -0,0 +1,148 @@
+package generator
+
+var recorderTmpl = newTemplate("recorder", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	{{.GoImport}}
+)
+
+// {{.Name}}Recording is a recorded {{.Name}} call. Request and Response hold
+// the redacted messages in protojson.
+type {{.Name}}Recording struct {
+	Method   string          `+"`"+`json:"method"`+"`"+`
+	Time     time.Time       `+"`"+`json:"time"`+"`"+`
+	Request  json.RawMessage `+"`"+`json:"request"`+"`"+`
+	Response json.RawMessage `+"`"+`json:"response,omitempty"`+"`"+`
+	Code     string          `+"`"+`json:"code"`+"`"+`
+	Error    string          `+"`"+`json:"error,omitempty"`+"`"+`
+}
+
+// {{.Name}}Recorder records unary {{.Name}} calls to Dir, one JSON file per
+// call, for debugging production issues locally with Replay{{.Name}}.
+// Fields marked (service_gen.sensitive) are redacted. Streaming calls are
+// not recorded.
+type {{.Name}}Recorder struct {
+	Dir string
+}
+
+// UnaryInterceptor records every unary {{.Name}} call after it completes.
+func (r *{{.Name}}Recorder) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if strings.HasPrefix(info.FullMethod, "/{{.FullName}}/") {
+			if rerr := r.record(info.FullMethod, req, resp, err); rerr != nil {
+				log.Printf("{{.Name}}: unable to record %s: %v", info.FullMethod, rerr)
+			}
+		}
+		return resp, err
+	}
+}
+
+func (r *{{.Name}}Recorder) record(method string, req, resp interface{}, callErr error) error {
+	now := time.Now()
+	rec := {{.Name}}Recording{
+		Method: method,
+		Time:   now,
+		Code:   status.Code(callErr).String(),
+	}
+	if callErr != nil {
+		rec.Error = status.Convert(callErr).Message()
+	}
+
+	var err error
+	if rec.Request, err = {{.LowerName}}MarshalRecorded(req); err != nil {
+		return err
+	}
+	if callErr == nil {
+		if rec.Response, err = {{.LowerName}}MarshalRecorded(resp); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.json", now.UnixNano(), method[strings.LastIndex(method, "/")+1:])
+	return ioutil.WriteFile(filepath.Join(r.Dir, name), b, 0600)
+}
+
+// {{.LowerName}}MarshalRecorded renders a redacted message as protojson.
+func {{.LowerName}}MarshalRecorded(v interface{}) (json.RawMessage, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unable to record %T: not a proto message", v)
+	}
+	s, err := (&jsonpb.Marshaler{}).MarshalToString({{.LowerName}}Redact(m))
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(s), nil
+}
+
+// Replay{{.Name}} re-invokes the unary handlers of srv with the requests
+// recorded in dir, oldest first, and reports the outcome of each call.
+// Redacted fields are replayed empty.
+func Replay{{.Name}}(ctx context.Context, dir string, srv {{.GoPrefix}}.{{.Name}}Server, report func(rec {{.Name}}Recording, resp proto.Message, err error)) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var rec {{.Name}}Recording
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return fmt.Errorf("unable to read recording %s: %v", f, err)
+		}
+		if !strings.HasPrefix(rec.Method, "/{{.FullName}}/") {
+			continue
+		}
+		resp, err := {{.LowerName}}Replay(ctx, srv, rec)
+		report(rec, resp, err)
+	}
+	return nil
+}
+
+// {{.LowerName}}Replay invokes the handler the recording was made for.
+func {{.LowerName}}Replay(ctx context.Context, srv {{.GoPrefix}}.{{.Name}}Server, rec {{.Name}}Recording) (proto.Message, error) {
+	switch rec.Method {
+	{{- range .Methods }}
+	{{- if not (or .GetClientStreaming .GetServerStreaming) }}
+	case "/{{$.FullName}}/{{.WireName}}":
+		in := &{{$.GoPrefix}}.{{.InputGoName}}{}
+		if err := jsonpb.UnmarshalString(string(rec.Request), in); err != nil {
+			return nil, err
+		}
+		out, err := srv.{{.Name}}(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	{{- end }}
+	{{- end }}
+	}
+	return nil, fmt.Errorf("unable to replay %s: not a unary method", rec.Method)
+}
+`)
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/nstogner/protoc-gen-grpc-go-service/servicegen"
+)
+
+// redactor clears the sensitive fields of one message type.
+type redactor struct {
+	// GoName is the Go type name of the message.
+	GoName string
+	// Statements clear the sensitive fields of a message named m.
+	Statements []string
+}
+
+// Redactors returns a redactor for every message used by the service that
+// holds sensitive fields, directly or in nested messages. Only messages of
+// the service's own proto package are considered.
+func (p Service) Redactors() []redactor {
+	var order []string
+	seen := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		msg, ok := p.messages[name]
+		if !ok || seen[name] || msg.Package != p.PackageName {
+			return
+		}
+		seen[name] = true
+		order = append(order, name)
+		for _, f := range msg.GetField() {
+			if t := p.messages.valueType(f); t != "" {
+				visit(t)
+			}
+		}
+	}
+	for _, m := range p.Methods {
+		visit(m.GetInputType())
+		visit(m.GetOutputType())
+	}
+
+	needs := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, name := range order {
+			if needs[name] {
+				continue
+			}
+			for _, f := range p.messages[name].GetField() {
+				if sensitive(f) || needs[p.messages.valueType(f)] {
+					needs[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var rs []redactor
+	for _, name := range order {
+		if !needs[name] {
+			continue
+		}
+		msg := p.messages[name]
+		r := redactor{GoName: msg.GoName}
+		for _, f := range msg.GetField() {
+			if stmt := p.redactStatement(msg, f, needs); stmt != "" {
+				r.Statements = append(r.Statements, stmt)
+			}
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// redactStatement returns the Go statement clearing f in a message named m,
+// or "" when f holds nothing sensitive.
+func (p Service) redactStatement(msg *message, f *descriptor.FieldDescriptorProto, needs map[string]bool) string {
+	name := camelCase(f.GetName())
+	nested := needs[p.messages.valueType(f)]
+	if !sensitive(f) && !nested {
+		return ""
+	}
+	clear := func(v string) string {
+		return fmt.Sprintf("%sClear%s(%s)", p.LowerName(), p.messages.goName(p.messages.valueType(f)), v)
+	}
+
+	if f.OneofIndex != nil {
+		oneof := camelCase(msg.GetOneofDecl()[f.GetOneofIndex()].GetName())
+		wrapper := fmt.Sprintf("*%s.%s_%s", p.GoPrefix, msg.GoName, name)
+		if sensitive(f) {
+			return fmt.Sprintf("if _, ok := m.%s.(%s); ok {\nm.%s = nil\n}", oneof, wrapper, oneof)
+		}
+		return fmt.Sprintf("if v, ok := m.%s.(%s); ok {\n%s\n}", oneof, wrapper, clear("v."+name))
+	}
+
+	if !sensitive(f) {
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return fmt.Sprintf("for _, v := range m.%s {\n%s\n}", name, clear("v"))
+		}
+		return clear("m." + name)
+	}
+
+	zero := "0"
+	switch {
+	case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+		f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		f.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		zero = "nil"
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+		zero = `""`
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+		zero = "false"
+	}
+	return fmt.Sprintf("m.%s = %s", name, zero)
+}
+
+// valueType returns the message type held by f: the field type itself, or
+// the value type of a map. It returns "" for fields holding no message.
+func (idx messageIndex) valueType(f *descriptor.FieldDescriptorProto) string {
+	if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return ""
+	}
+	entry, ok := idx[f.GetTypeName()]
+	if !ok || !entry.GetOptions().GetMapEntry() {
+		return f.GetTypeName()
+	}
+	for _, ef := range entry.GetField() {
+		if ef.GetName() == "value" {
+			return idx.valueType(ef)
+		}
+	}
+	return ""
+}
+
+// sensitive reports whether the field is marked (service_gen.sensitive).
+func sensitive(f *descriptor.FieldDescriptorProto) bool {
+	return boolExtension(f.GetOptions(), servicegen.E_Sensitive)
+}
+
+var redactTmpl = newTemplate("redact", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"github.com/golang/protobuf/proto"
+	{{- if .Redactors }}
+	{{.GoImport}}
+	{{- end }}
+)
+
+// {{.LowerName}}Redact returns m with the fields marked
+// (service_gen.sensitive) cleared. Messages holding such fields are copied
+// first; others are returned as they are.
+func {{.LowerName}}Redact(m proto.Message) proto.Message {
+	{{- if .Redactors }}
+	switch m := m.(type) {
+	{{- range .Redactors }}
+	case *{{$.GoPrefix}}.{{.GoName}}:
+		c := proto.Clone(m).(*{{$.GoPrefix}}.{{.GoName}})
+		{{$.LowerName}}Clear{{.GoName}}(c)
+		return c
+	{{- end }}
+	}
+	{{- end }}
+	return m
+}
+{{ range .Redactors }}
+func {{$.LowerName}}Clear{{.GoName}}(m *{{$.GoPrefix}}.{{.GoName}}) {
+	if m == nil {
+		return
+	}
+	{{- range .Statements }}
+	{{.}}
+	{{- end }}
+}
+{{ end }}
+`)
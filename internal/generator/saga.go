@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"errors"
+
+	"github.com/nstogner/protoc-gen-grpc-go-service/servicegen"
+)
+
+// HasSagas reports whether any method of the service runs a saga.
+func (p Service) HasSagas() bool {
+	for _, m := range p.Methods {
+		if len(m.SagaStepNames()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SagaStepNames returns the (service_gen.saga_steps) option.
+func (m method) SagaStepNames() []string {
+	return stringsExtension(m.GetOptions(), servicegen.E_SagaSteps)
+}
+
+// SagaSteps returns the steps of the saga of the method, rejecting empty
+// and duplicate names.
+func (m method) SagaSteps() ([]string, error) {
+	steps := m.SagaStepNames()
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		if step == "" {
+			return nil, errors.New("invalid saga_steps option on " + m.GetName() + ": empty step name")
+		}
+		if seen[step] {
+			return nil, errors.New("invalid saga_steps option on " + m.GetName() + ": duplicate step " + step)
+		}
+		seen[step] = true
+	}
+	return steps, nil
+}
+
+// sagaTmpl declares the saga runner in the service file of services with
+// sagas, and runs the steps in stubs.
+var sagaTmpl = `
+{{- define "saga_runner" }}
+// {{.Name}}SagaStep is a step of a saga. Do performs the step; Compensate,
+// which may be nil, undoes it when a later step fails.
+type {{.Name}}SagaStep struct {
+	Name       string
+	Do         func(ctx context.Context) error
+	Compensate func(ctx context.Context) error
+}
+
+// run{{.Name}}Saga runs steps in order. When a step fails, or ctx is done
+// before the next one starts, the completed steps are compensated in reverse
+// order and the error is returned. Compensation runs even though ctx is done,
+// with a context of its own; its failures are logged.
+func run{{.Name}}Saga(ctx context.Context, steps []{{.Name}}SagaStep) error {
+	for i, step := range steps {
+		err := ctx.Err()
+		if err == nil {
+			err = step.Do(ctx)
+		}
+		if err == nil {
+			continue
+		}
+		log.Printf("{{.Name}}: saga step %s failed: %v", step.Name, err)
+		for j := i - 1; j >= 0; j-- {
+			if steps[j].Compensate == nil {
+				continue
+			}
+			if cerr := steps[j].Compensate(context.Background()); cerr != nil {
+				log.Printf("{{.Name}}: unable to compensate saga step %s: %v", steps[j].Name, cerr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+{{- end }}
+
+{{- define "saga" }}
+	{{- with .SagaSteps }}
+
+	if err := run{{$.Service.Name}}Saga({{$.Ctx}}, []{{$.Service.Name}}SagaStep{
+		{{- range . }}
+		{
+			Name: {{printf "%q" .}},
+			Do: func(ctx context.Context) error {
+				// {{$.TodoNote (printf "Run %s" .)}}
+				return nil
+			},
+			Compensate: func(ctx context.Context) error {
+				// {{$.TodoNote (printf "Undo %s" .)}}
+				return nil
+			},
+		},
+		{{- end }}
+	}); err != nil {
+		{{$.Return "err"}}
+	}
+	{{- end }}
+{{- end }}
+`
@@ -0,0 +1,41 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// warnings receives the warnings of the generation. The response of this
+// protobuf version has no field for them, and protoc forwards the stderr of
+// plugins.
+var warnings io.Writer = os.Stderr
+
+// warnf writes a warning.
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(warnings, "protoc-gen-grpc-go-service: warning: "+format+"\n", args...)
+}
+
+// goIdent returns the Go identifier protoc-gen-go gives a service or a
+// method named name. Characters that are invalid in Go identifiers are
+// replaced with underscores first, and identifiers that would not start
+// with a letter are prefixed with an X, so that any name maps to the same
+// exported identifier every time.
+func goIdent(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		if r < 0x80 && (r == '_' || isASCIILetter(byte(r)) || isASCIIDigit(byte(r))) {
+			return r
+		}
+		return '_'
+	}, name)
+	ident := camelCase(clean)
+	if ident == "" || !isASCIILetter(ident[0]) {
+		ident = "X" + ident
+	}
+	return ident
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
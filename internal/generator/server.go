@@ -0,0 +1,959 @@
+package generator
+
+var serverTmpl = newTemplate("server-bootstrap", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/binarylog"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	{{.GoImport}}
+)
+
+// {{.Name}}Config configures Run{{.Name}}. Load{{.Name}}Config fills it
+// from {{if eq .ConfigBackend "viper"}}a config file and {{end}}the environment.
+type {{.Name}}Config struct {
+	// Addr is the address to listen on, e.g. ":8080", or a unix socket such
+	// as "unix:///run/{{.LowerName}}.sock" for sidecar-local serving.
+	{{- if .Systemd }} It is
+	// ignored when systemd passes a listening socket.
+	{{- end }}
+	Addr string
+	// SocketMode is the permission of unix sockets, 0660 by default.
+	SocketMode os.FileMode
+	// H2C serves gRPC alongside HTTP handlers, such as a gateway or a
+	// grpc-web wrapper, over cleartext HTTP/2 on Addr. Use it where the mesh
+	// terminates TLS; otherwise gRPC is served on its own.
+	H2C bool
+	// MetricsAddr is the address serving Prometheus metrics on /metrics,
+	// e.g. ":9090". Metrics are not served when it is empty.
+	MetricsAddr string
+	// DebugAddr is the address serving pprof profiles under /debug/pprof/,
+	// e.g. "localhost:6060". Profiles are not served when it is empty; keep
+	// it off public interfaces.
+	DebugAddr string
+	{{- if .EnvPresets }}
+	// Env is the environment the configuration was preset for, named by
+	// {{.Name}}EnvVar.
+	Env string
+	// Reflection registers the server reflection service, for tools such as
+	// grpcurl.
+	Reflection bool
+	// TLSCertFile and TLSKeyFile are the certificate and key served over
+	// TLS. Calls are served in cleartext when they are empty.
+	TLSCertFile string
+	TLSKeyFile  string
+	// RequireTLS refuses to serve without a certificate.
+	RequireTLS bool
+	{{- if not .Reload }}
+	// LogLevel is the minimum level of the logs.
+	LogLevel string
+	{{- end }}
+	{{- end }}
+	{{- if .GenOps }}
+	// OpsAddr is the address serving the {{.Name}}OpsServiceName service,
+	// e.g. "localhost:7070". It is not served when empty; keep it off public
+	// interfaces.
+	OpsAddr string
+	// OpsToken is the bearer token calls to the ops service must carry. It
+	// is required with OpsAddr.
+	OpsToken string `+"`"+`json:"-"`+"`"+`
+	{{- end }}
+	{{- if .BinaryLog }}
+	// BinaryLogPath is the file gRPC binary logs are written to. They are
+	// not written when it is empty, nor unless GRPC_BINARY_LOG_FILTER is set.
+	BinaryLogPath string
+	// BinaryLogMaxBytes is the size the binary log is rotated at, and
+	// BinaryLogMaxFiles the number of rotated files kept.
+	BinaryLogMaxBytes int64
+	BinaryLogMaxFiles int
+	// BinaryLogMethods are the methods logged, e.g. "/{{.FullName}}/*".
+	// Every method is logged when empty.
+	BinaryLogMethods []string
+	{{- end }}
+	{{- if .Reload }}
+
+	// The settings below are reloadable: Run{{.Name}} passes their new
+	// values to the Reload hook of the service on SIGHUP
+	{{- if eq .ConfigBackend "viper" }} and when the
+	// config file changes{{ end }}.
+
+	// LogLevel is the minimum level of the logs, "info" by default.
+	LogLevel string
+	// RateLimit caps the calls served per second. Calls are not limited
+	// when it is 0.
+	RateLimit float64
+	// Toggles are the feature toggles turned on.
+	Toggles []string
+	{{- if eq .ConfigBackend "viper" }}
+
+	// path is the config file the configuration was loaded from.
+	path string
+	{{- end }}
+	{{- end }}
+}
+
+{{ if eq .ConfigBackend "viper" }}
+// {{.Name}}ConfigSection is the section of the config file holding the
+// configuration of {{.Name}}.
+const {{.Name}}ConfigSection = "{{.ConfigKey}}"
+
+// Load{{.Name}}Config reads the configuration from the {{.Name}}ConfigSection
+// section of the YAML file at path, e.g.
+//
+//	{{.ConfigKey}}:
+//	  addr: ":8080"
+//	  metrics_addr: ":9090"
+//
+// Environment variables take precedence over the file:
+//
+//	{{.EnvPrefix}}_ADDR          address to listen on, ":8080" by default
+//	{{.EnvPrefix}}_SOCKET_MODE   octal permission of unix sockets, "0660" by default
+//	{{.EnvPrefix}}_H2C           "true" to serve over cleartext HTTP/2
+//	{{.EnvPrefix}}_METRICS_ADDR  address serving Prometheus metrics, off by default
+//	{{.EnvPrefix}}_DEBUG_ADDR    address serving pprof profiles, off by default
+{{- template "reload_env" . }}
+func Load{{.Name}}Config(path string) ({{.Name}}Config, error) {
+	v, err := new{{.Name}}Viper(path)
+	if err != nil {
+		return {{.Name}}Config{}, err
+	}
+	{{- if .Reload }}
+	cfg, err := {{.LowerName}}ConfigFrom(v)
+	cfg.path = path
+	return cfg, err
+	{{- else }}
+	return {{.LowerName}}ConfigFrom(v)
+	{{- end }}
+}
+
+// Watch{{.Name}}Config loads the configuration like Load{{.Name}}Config,
+// then calls onChange with the configuration reloaded whenever the file
+// changes, or with the error making it invalid. Settings only read at
+// startup, such as the addresses, need a restart to take effect.
+func Watch{{.Name}}Config(path string, onChange func({{.Name}}Config, error)) ({{.Name}}Config, error) {
+	v, err := new{{.Name}}Viper(path)
+	if err != nil {
+		return {{.Name}}Config{}, err
+	}
+	cfg, err := {{.LowerName}}ConfigFrom(v)
+	if err != nil {
+		return cfg, err
+	}
+	{{- if .Reload }}
+	cfg.path = path
+	{{- end }}
+	v.OnConfigChange(func(fsnotify.Event) {
+		{{- if .Reload }}
+		cfg, err := {{.LowerName}}ConfigFrom(v)
+		cfg.path = path
+		onChange(cfg, err)
+		{{- else }}
+		onChange({{.LowerName}}ConfigFrom(v))
+		{{- end }}
+	})
+	v.WatchConfig()
+	return cfg, nil
+}
+
+// new{{.Name}}Viper reads the config file at path, binding the keys of
+// {{.Name}}ConfigSection to their environment variables.
+func new{{.Name}}Viper(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+
+	v.SetDefault({{.Name}}ConfigSection+".addr", ":8080")
+	v.SetDefault({{.Name}}ConfigSection+".socket_mode", "0660")
+	{{- if .BinaryLog }}
+	v.SetDefault({{.Name}}ConfigSection+".binary_log_max_bytes", 64<<20)
+	v.SetDefault({{.Name}}ConfigSection+".binary_log_max_files", 5)
+	{{- end }}
+	{{- if .EnvPresets }}
+	preset, err := {{.Name}}Preset()
+	if err != nil {
+		return nil, err
+	}
+	v.SetDefault({{.Name}}ConfigSection+".log_level", preset.LogLevel)
+	v.SetDefault({{.Name}}ConfigSection+".reflection", preset.Reflection)
+	v.SetDefault({{.Name}}ConfigSection+".require_tls", preset.RequireTLS)
+	{{- else if .Reload }}
+	v.SetDefault({{.Name}}ConfigSection+".log_level", "info")
+	{{- end }}
+	for _, key := range []string{"addr", "socket_mode", "h2c", "metrics_addr", "debug_addr"
+		{{- if .GenOps }}, "ops_addr", "ops_token"{{ end }}
+		{{- if .BinaryLog }}, "binary_log_path", "binary_log_max_bytes", "binary_log_max_files", "binary_log_methods"{{ end }}
+		{{- if .EnvPresets }}, "reflection", "tls_cert_file", "tls_key_file", "require_tls"{{ if not .Reload }}, "log_level"{{ end }}{{ end }}
+		{{- if .Reload }}, "log_level", "rate_limit", "toggles"{{ end }}} {
+		if err := v.BindEnv({{.Name}}ConfigSection+"."+key, "{{.EnvPrefix}}_"+strings.ToUpper(key)); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// {{.LowerName}}ConfigFrom reads the configuration from v.
+func {{.LowerName}}ConfigFrom(v *viper.Viper) ({{.Name}}Config, error) {
+	key := func(k string) string { return {{.Name}}ConfigSection + "." + k }
+	cfg := {{.Name}}Config{
+		Addr:        v.GetString(key("addr")),
+		H2C:         v.GetBool(key("h2c")),
+		MetricsAddr: v.GetString(key("metrics_addr")),
+		DebugAddr:   v.GetString(key("debug_addr")),
+		{{- if .GenOps }}
+		OpsAddr:     v.GetString(key("ops_addr")),
+		OpsToken:    v.GetString(key("ops_token")),
+		{{- end }}
+		{{- if .BinaryLog }}
+		BinaryLogPath:     v.GetString(key("binary_log_path")),
+		BinaryLogMaxBytes: v.GetInt64(key("binary_log_max_bytes")),
+		BinaryLogMaxFiles: v.GetInt(key("binary_log_max_files")),
+		BinaryLogMethods:  {{.LowerName}}SplitList(v.GetStringSlice(key("binary_log_methods"))),
+		{{- end }}
+		{{- if .EnvPresets }}
+		Env:         {{.LowerName}}EnvName(),
+		Reflection:  v.GetBool(key("reflection")),
+		TLSCertFile: v.GetString(key("tls_cert_file")),
+		TLSKeyFile:  v.GetString(key("tls_key_file")),
+		RequireTLS:  v.GetBool(key("require_tls")),
+		{{- if not .Reload }}
+		LogLevel:    v.GetString(key("log_level")),
+		{{- end }}
+		{{- end }}
+		{{- if .Reload }}
+		LogLevel:    v.GetString(key("log_level")),
+		RateLimit:   v.GetFloat64(key("rate_limit")),
+		Toggles:     {{.LowerName}}SplitList(v.GetStringSlice(key("toggles"))),
+		{{- end }}
+	}
+	m, err := strconv.ParseUint(v.GetString(key("socket_mode")), 8, 32)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid {{.ConfigKey}}.socket_mode: %v", err)
+	}
+	cfg.SocketMode = os.FileMode(m)
+	return cfg, nil
+}
+
+{{ else }}
+// Load{{.Name}}Config reads the configuration from the environment:
+//
+//	{{.EnvPrefix}}_ADDR          address to listen on, ":8080" by default
+//	{{.EnvPrefix}}_SOCKET_MODE   octal permission of unix sockets, "0660" by default
+//	{{.EnvPrefix}}_H2C           "true" to serve over cleartext HTTP/2
+//	{{.EnvPrefix}}_METRICS_ADDR  address serving Prometheus metrics, off by default
+//	{{.EnvPrefix}}_DEBUG_ADDR    address serving pprof profiles, off by default
+{{- template "reload_env" . }}
+func Load{{.Name}}Config() ({{.Name}}Config, error) {
+	{{- if .EnvPresets }}
+	cfg, err := {{.Name}}Preset()
+	if err != nil {
+		return cfg, err
+	}
+	{{- else }}
+	cfg := {{.Name}}Config{Addr: ":8080", SocketMode: 0660}
+	{{- end }}
+	if v := os.Getenv("{{.EnvPrefix}}_ADDR"); v != "" {
+		cfg.Addr = v
+	}
+	if v := os.Getenv("{{.EnvPrefix}}_SOCKET_MODE"); v != "" {
+		m, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_SOCKET_MODE: %v", err)
+		}
+		cfg.SocketMode = os.FileMode(m)
+	}
+	if v := os.Getenv("{{.EnvPrefix}}_H2C"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_H2C: %v", err)
+		}
+		cfg.H2C = b
+	}
+	cfg.MetricsAddr = os.Getenv("{{.EnvPrefix}}_METRICS_ADDR")
+	cfg.DebugAddr = os.Getenv("{{.EnvPrefix}}_DEBUG_ADDR")
+	{{- if .GenOps }}
+	cfg.OpsAddr = os.Getenv("{{.EnvPrefix}}_OPS_ADDR")
+	cfg.OpsToken = os.Getenv("{{.EnvPrefix}}_OPS_TOKEN")
+	{{- end }}
+	{{- if .BinaryLog }}
+	cfg.BinaryLogPath = os.Getenv("{{.EnvPrefix}}_BINARY_LOG_PATH")
+	cfg.BinaryLogMaxBytes, cfg.BinaryLogMaxFiles = 64<<20, 5
+	if v := os.Getenv("{{.EnvPrefix}}_BINARY_LOG_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_BINARY_LOG_MAX_BYTES: %v", err)
+		}
+		cfg.BinaryLogMaxBytes = n
+	}
+	if v := os.Getenv("{{.EnvPrefix}}_BINARY_LOG_MAX_FILES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_BINARY_LOG_MAX_FILES: %v", err)
+		}
+		cfg.BinaryLogMaxFiles = n
+	}
+	cfg.BinaryLogMethods = {{.LowerName}}SplitList([]string{os.Getenv("{{.EnvPrefix}}_BINARY_LOG_METHODS")})
+	{{- end }}
+	{{- if .EnvPresets }}
+	if v := os.Getenv("{{.EnvPrefix}}_REFLECTION"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_REFLECTION: %v", err)
+		}
+		cfg.Reflection = b
+	}
+	cfg.TLSCertFile = os.Getenv("{{.EnvPrefix}}_TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("{{.EnvPrefix}}_TLS_KEY_FILE")
+	if v := os.Getenv("{{.EnvPrefix}}_REQUIRE_TLS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_REQUIRE_TLS: %v", err)
+		}
+		cfg.RequireTLS = b
+	}
+	{{- end }}
+	{{- if or .Reload .EnvPresets }}
+	{{- if not .EnvPresets }}
+	cfg.LogLevel = "info"
+	{{- end }}
+	if v := os.Getenv("{{.EnvPrefix}}_LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+	{{- end }}
+	{{- if .Reload }}
+	if v := os.Getenv("{{.EnvPrefix}}_RATE_LIMIT"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid {{.EnvPrefix}}_RATE_LIMIT: %v", err)
+		}
+		cfg.RateLimit = r
+	}
+	cfg.Toggles = {{.LowerName}}SplitList([]string{os.Getenv("{{.EnvPrefix}}_TOGGLES")})
+	{{- end }}
+	return cfg, nil
+}
+
+{{ end }}
+
+// Run{{.Name}} serves srv, along with the standard health service, as
+// configured by cfg until ctx is done or a listener fails, then stops
+// gracefully. With H2C set, requests that are not gRPC go to handler, which
+// may be nil. Metrics and debug endpoints get listeners of their own.
+{{- if .GenStats }} The
+// connections and streams are tracked by a {{.Name}}Stats handler.
+{{- end }}
+{{- if .GenStreamMetrics }} The
+// messages of streams are counted by {{.Name}}StreamMetrics.
+{{- end }}
+{{- if .GenBuildInfo }} Health
+// checks carry the build info in their header metadata.
+{{- end }}
+{{- if .GenEvents }} Calls are
+// published to the observers of Default{{.Name}}Events.
+{{- end }}
+{{- if .HasSunsets }} Deprecated
+// methods are sunset by {{.Name}}Sunset.
+{{- end }}
+{{- if .HasCacheControl }} Cacheable
+// responses carry their Cache-Control directive.
+{{- end }}
+{{- if .GenBaggage }} The baggage
+// of calls is extracted by {{.Name}}BaggagePropagation.
+{{- end }}
+{{- if .GenOps }} The ops
+// service is served on OpsAddr, for operators to set the log level,
+{{- if .GenMaintenance }} switch
+// maintenance mode,{{ end }} and dump the configuration Run{{.Name}}
+// started with.
+{{- end }}
+{{- if .GenDepHealth }} The health
+// status follows the checks of the dependencies of srv, also served on the
+// /readyz debug endpoint.
+{{- end }}
+{{- if .GenStartupBanner }} Once
+// serving, a {{.Name}}Startup banner is logged.
+{{- end }}
+{{- if .GenLifecycle }} The listeners
+// and servers are components of a {{.Name}}Lifecycle, after those srv
+// registers as a {{.Name}}LifecycleRegistrar: they are started in order and
+// stopped in reverse, each within Default{{.Name}}StopTimeout.
+{{- end }}
+func Run{{.Name}}(ctx context.Context, cfg {{.Name}}Config, srv {{.GoPrefix}}.{{.Name}}Server, handler http.Handler, opts ...grpc.ServerOption) error {
+	{{- if .GenStats }}
+	st, err := New{{.Name}}Stats(nil)
+	if err != nil {
+		return fmt.Errorf("unable to set up stats: %v", err)
+	}
+	opts = append([]grpc.ServerOption{grpc.StatsHandler(st)}, opts...)
+	{{- end }}
+	{{- if .GenStreamMetrics }}
+	sm, err := New{{.Name}}StreamMetrics(nil)
+	if err != nil {
+		return fmt.Errorf("unable to set up stream metrics: %v", err)
+	}
+	opts = append([]grpc.ServerOption{grpc.ChainStreamInterceptor(sm.StreamInterceptor())}, opts...)
+	{{- end }}
+	{{- if .HasSunsets }}
+	sunset, err := New{{.Name}}Sunset(nil)
+	if err != nil {
+		return fmt.Errorf("unable to set up sunsets: %v", err)
+	}
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(sunset.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(sunset.StreamInterceptor()),
+	}, opts...)
+	{{- end }}
+	{{- if .GenBaggage }}
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor({{.Name}}BaggagePropagation{}.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor({{.Name}}BaggagePropagation{}.StreamInterceptor()),
+	}, opts...)
+	{{- end }}
+	{{- if .HasCacheControl }}
+	opts = append([]grpc.ServerOption{grpc.ChainUnaryInterceptor({{.Name}}CacheUnaryInterceptor())}, opts...)
+	{{- end }}
+	{{- if .GenEvents }}
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(Default{{.Name}}Events.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(Default{{.Name}}Events.StreamInterceptor()),
+	}, opts...)
+	{{- end }}
+	{{- if .GenBuildInfo }}
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor({{.Name}}InfoUnaryInterceptor()),
+		grpc.ChainStreamInterceptor({{.Name}}InfoStreamInterceptor()),
+	}, opts...)
+	{{- end }}
+	{{- if .BinaryLog }}
+	if cfg.BinaryLogPath != "" {
+		if os.Getenv("GRPC_BINARY_LOG_FILTER") == "" {
+			log.Printf("{{.Name}}: GRPC_BINARY_LOG_FILTER is not set, nothing will be logged to %s", cfg.BinaryLogPath)
+		}
+		sink := &{{.Name}}BinaryLogSink{
+			Path:     cfg.BinaryLogPath,
+			MaxBytes: cfg.BinaryLogMaxBytes,
+			MaxFiles: cfg.BinaryLogMaxFiles,
+			Methods:  cfg.BinaryLogMethods,
+		}
+		binarylog.SetSink(sink)
+		defer sink.Close()
+	}
+	{{- end }}
+	{{- if .GenOps }}
+	ops := &{{.Name}}Ops{Config: func() interface{} { return cfg }}
+	var opsOpts []grpc.ServerOption
+	{{- if .GenMaintenance }}
+	maintenance, err := New{{.Name}}Maintenance()
+	if err != nil {
+		return err
+	}
+	ops.Maintenance = maintenance
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(maintenance.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(maintenance.StreamInterceptor()),
+	}, opts...)
+	{{- end }}
+	{{- end }}
+	{{- if .EnvPresets }}
+	if cfg.TLSCertFile != "" {
+		if cfg.H2C {
+			return fmt.Errorf("{{.Name}}: H2C serves cleartext, it cannot be used with TLS")
+		}
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load the TLS certificate: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+		{{- if .GenOps }}
+		opsOpts = append(opsOpts, grpc.Creds(creds))
+		{{- end }}
+	} else if cfg.RequireTLS {
+		return fmt.Errorf("{{.Name}}: TLS is required in %s, set {{.EnvPrefix}}_TLS_CERT_FILE and {{.EnvPrefix}}_TLS_KEY_FILE", cfg.Env)
+	}
+	{{- end }}
+	s := grpc.NewServer(opts...)
+	{{.GoPrefix}}.Register{{.Name}}Server(s, srv)
+	healthServer := health.NewServer()
+	{{- if .GenDepHealth }}
+	deps := &{{.Name}}Health{Server: healthServer}
+	if c, ok := srv.({{.Name}}HealthChecker); ok {
+		deps.Checker = c
+	}
+	deps.Check(ctx)
+	go deps.Watch(ctx)
+	{{- else }}
+	healthServer.SetServingStatus("{{.FullName}}", grpc_health_v1.HealthCheckResponse_SERVING)
+	{{- end }}
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	{{- if .EnvPresets }}
+	if cfg.Reflection {
+		reflection.Register(s)
+	}
+	{{- end }}
+
+	{{- if .Reload }}
+	if r, ok := srv.({{.Name}}Reloader); ok {
+		reloaded := &{{.LowerName}}Reloaded{r: r}
+		if err := reloaded.apply(cfg); err != nil {
+			return fmt.Errorf("unable to apply the configuration: %v", err)
+		}
+		defer {{.LowerName}}WatchReload(cfg, reloaded)()
+		{{- if .GenOps }}
+		ops.Config = func() interface{} { return reloaded.current() }
+		ops.LogLevel = func(level string) error {
+			return reloaded.update(func(cfg *{{.Name}}Config) { cfg.LogLevel = level })
+		}
+		{{- end }}
+	}
+	{{- end }}
+
+	{{- if .GenLifecycle }}
+
+	lc := &{{.Name}}Lifecycle{}
+	if r, ok := srv.({{.Name}}LifecycleRegistrar); ok {
+		r.RegisterLifecycle(lc)
+	}
+	errc := make(chan error, 4)
+	var grpcHTTP *http.Server
+	{{- if .GenStartupBanner }}
+	var grpcAddr string
+	{{- end }}
+	lc.Register("grpc server", func(context.Context) error {
+		lis, err := {{.LowerName}}Listen(cfg)
+		if err != nil {
+			return err
+		}
+		{{- if .GenStartupBanner }}
+		grpcAddr = lis.Addr().String()
+		{{- end }}
+		if cfg.H2C {
+			grpcHTTP = &http.Server{Handler: h2c.NewHandler({{.LowerName}}Mux(s, handler), &http2.Server{})}
+			go func() { errc <- {{.LowerName}}ServeHTTP(grpcHTTP, lis) }()
+		} else {
+			go func() { errc <- s.Serve(lis) }()
+		}
+		return nil
+	}, func(ctx context.Context) error {
+		if grpcHTTP != nil {
+			grpcHTTP.Shutdown(ctx)
+		}
+		return {{.LowerName}}StopGRPC(ctx, s)
+	})
+	var httpServers []*http.Server
+	if cfg.MetricsAddr != "" {
+		httpServers = append(httpServers, &http.Server{Addr: cfg.MetricsAddr, Handler: promhttp.Handler()})
+	}
+	if cfg.DebugAddr != "" {
+		httpServers = append(httpServers, &http.Server{Addr: cfg.DebugAddr, Handler: {{.LowerName}}DebugMux({{ if .GenDepHealth }}deps{{ end }})})
+	}
+	for _, hs := range httpServers {
+		hs := hs
+		lc.Register("http server on "+hs.Addr, func(context.Context) error {
+			lis, err := net.Listen("tcp", hs.Addr)
+			if err != nil {
+				return err
+			}
+			go func() { errc <- {{.LowerName}}ServeHTTP(hs, lis) }()
+			return nil
+		}, hs.Shutdown)
+	}
+	{{- if .GenOps }}
+	if cfg.OpsAddr != "" {
+		opsServer, err := New{{.Name}}OpsServer(ops, cfg.OpsToken, opsOpts...)
+		if err != nil {
+			return err
+		}
+		lc.Register("ops server", func(context.Context) error {
+			lis, err := net.Listen("tcp", cfg.OpsAddr)
+			if err != nil {
+				return err
+			}
+			go func() { errc <- opsServer.Serve(lis) }()
+			return nil
+		}, func(ctx context.Context) error {
+			return {{.LowerName}}StopGRPC(ctx, opsServer)
+		})
+	}
+	{{- end }}
+	// Registered last, the health service reports NOT_SERVING before the
+	// servers start draining.
+	lc.Register("health", nil, func(context.Context) error {
+		healthServer.Shutdown()
+		return nil
+	})
+
+	if err := lc.Start(ctx); err != nil {
+		return err
+	}
+	{{- if .GenStartupBanner }}
+	{{.LowerName}}LogStartup(cfg, grpcAddr)
+	{{- end }}
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-errc:
+	}
+	if err := lc.Stop(context.Background()); runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+	{{- else }}
+
+	lis, err := {{.LowerName}}Listen(cfg)
+	if err != nil {
+		return err
+	}
+	{{- if .GenOps }}
+	var (
+		opsServer *grpc.Server
+		opsLis    net.Listener
+	)
+	if cfg.OpsAddr != "" {
+		if opsServer, err = New{{.Name}}OpsServer(ops, cfg.OpsToken, opsOpts...); err == nil {
+			opsLis, err = net.Listen("tcp", cfg.OpsAddr)
+		}
+		if err != nil {
+			lis.Close()
+			return err
+		}
+	}
+	{{- end }}
+
+	errc := make(chan error, {{ if .GenOps }}4{{ else }}3{{ end }})
+	var httpServers []*http.Server
+	if cfg.H2C {
+		hs := &http.Server{Handler: h2c.NewHandler({{.LowerName}}Mux(s, handler), &http2.Server{})}
+		httpServers = append(httpServers, hs)
+		go func() { errc <- {{.LowerName}}ServeHTTP(hs, lis) }()
+	} else {
+		go func() { errc <- s.Serve(lis) }()
+	}
+	if cfg.MetricsAddr != "" {
+		hs := &http.Server{Addr: cfg.MetricsAddr, Handler: promhttp.Handler()}
+		httpServers = append(httpServers, hs)
+		go func() { errc <- {{.LowerName}}ServeHTTP(hs, nil) }()
+	}
+	if cfg.DebugAddr != "" {
+		hs := &http.Server{Addr: cfg.DebugAddr, Handler: {{.LowerName}}DebugMux({{ if .GenDepHealth }}deps{{ end }})}
+		httpServers = append(httpServers, hs)
+		go func() { errc <- {{.LowerName}}ServeHTTP(hs, nil) }()
+	}
+	{{- if .GenOps }}
+	if opsServer != nil {
+		go func() { errc <- opsServer.Serve(opsLis) }()
+	}
+	{{- end }}
+	{{- if .GenStartupBanner }}
+	{{.LowerName}}LogStartup(cfg, lis.Addr().String())
+	{{- end }}
+
+	select {
+	case <-ctx.Done():
+	case err = <-errc:
+	}
+	healthServer.Shutdown()
+	for _, hs := range httpServers {
+		hs.Shutdown(context.Background())
+	}
+	s.GracefulStop()
+	{{- if .GenOps }}
+	if opsServer != nil {
+		opsServer.GracefulStop()
+	}
+	{{- end }}
+	return err
+}
+{{- end }}
+
+{{- if .Reload }}
+// {{.Name}}Reloader is implemented by services applying the reloadable
+// settings of the configuration. Reload may be called while calls are
+// being served.
+type {{.Name}}Reloader interface {
+	Reload(cfg {{.Name}}Config) error
+}
+
+// {{.LowerName}}Reloaded holds the configuration last applied by the Reload
+// hook of the service, on which the settings changed at runtime, e.g. the
+// log level set through the ops service, build until the next reload.
+type {{.LowerName}}Reloaded struct {
+	r   {{.Name}}Reloader
+	mu  sync.Mutex
+	cfg {{.Name}}Config
+}
+
+// apply passes cfg to Reload, keeping it once applied.
+func (h *{{.LowerName}}Reloaded) apply(cfg {{.Name}}Config) error {
+	return h.update(func(c *{{.Name}}Config) { *c = cfg })
+}
+
+// update passes the current configuration, changed by change, to Reload,
+// keeping it once applied. Updates are applied one at a time.
+func (h *{{.LowerName}}Reloaded) update(change func(cfg *{{.Name}}Config)) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cfg := h.cfg
+	change(&cfg)
+	if err := h.r.Reload(cfg); err != nil {
+		return err
+	}
+	h.cfg = cfg
+	return nil
+}
+
+// current returns the configuration last applied.
+func (h *{{.LowerName}}Reloaded) current() {{.Name}}Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cfg
+}
+
+// {{.LowerName}}WatchReload reloads the configuration and passes it to h on
+// SIGHUP{{ if eq .ConfigBackend "viper" }} and when the config file changes{{ end }}, until the returned func is called.
+// Failures are logged and leave the previous settings in place.
+{{- if eq .ConfigBackend "viper" }} As viper
+// cannot stop watching files, changes to the file keep being applied after
+// that.
+{{- end }}
+func {{.LowerName}}WatchReload(cfg {{.Name}}Config, h *{{.LowerName}}Reloaded) (stop func()) {
+	apply := func(cfg {{.Name}}Config, err error) {
+		if err == nil {
+			err = h.apply(cfg)
+		}
+		if err != nil {
+			log.Printf("{{.Name}}: unable to reload the configuration: %v", err)
+		}
+	}
+	{{- if eq .ConfigBackend "viper" }}
+	if cfg.path != "" {
+		if _, err := Watch{{.Name}}Config(cfg.path, apply); err != nil {
+			log.Printf("{{.Name}}: unable to watch %s: %v", cfg.path, err)
+		}
+	}
+	{{- end }}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				apply(Load{{.Name}}Config({{ if eq .ConfigBackend "viper" }}cfg.path{{ end }}))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
+{{ end }}
+{{ if or .Reload .BinaryLog }}
+// {{.LowerName}}SplitList splits the comma-separated items of a list,
+// dropping empty ones.
+func {{.LowerName}}SplitList(items []string) []string {
+	var list []string
+	for _, item := range items {
+		for _, s := range strings.Split(item, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+{{ end }}
+
+// {{.LowerName}}ServeHTTP serves hs on lis, or on hs.Addr when lis is nil,
+// until it is shut down.
+func {{.LowerName}}ServeHTTP(hs *http.Server, lis net.Listener) error {
+	var err error
+	if lis != nil {
+		err = hs.Serve(lis)
+	} else {
+		err = hs.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// {{.LowerName}}DebugMux serves the pprof profiles under /debug/pprof/
+{{- if .GenBuildInfo }}
+// and the build info on /debug/info{{ end }}.
+{{- if .GenDepHealth }} The readiness of the
+// dependencies is served on /readyz.
+{{- end }}
+func {{.LowerName}}DebugMux({{ if .GenDepHealth }}ready http.Handler{{ end }}) http.Handler {
+	mux := http.NewServeMux()
+	{{- if .GenDepHealth }}
+	mux.Handle("/readyz", ready)
+	{{- end }}
+	{{- if .GenBuildInfo }}
+	mux.Handle("/debug/info", {{.Name}}InfoHandler())
+	{{- end }}
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// {{.LowerName}}Listen listens on the configured address. Stale unix
+// sockets left behind by a previous instance are removed first; the socket
+// is removed again when the listener is closed.
+{{- if .Systemd }} A listener inherited
+// through systemd socket activation is used instead when there is one.
+{{- end }}
+func {{.LowerName}}Listen(cfg {{.Name}}Config) (net.Listener, error) {
+	{{- if .Systemd }}
+	if lis, err := {{.LowerName}}ActivationListener(); lis != nil || err != nil {
+		return lis, err
+	}
+
+	{{ end -}}
+	path, ok := {{.LowerName}}SocketPath(cfg.Addr)
+	if !ok {
+		lis, err := net.Listen("tcp", cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on %s: %v", cfg.Addr, err)
+		}
+		return lis, nil
+	}
+
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("unable to remove stale socket %s: %v", path, err)
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %v", path, err)
+	}
+	mode := cfg.SocketMode
+	if mode == 0 {
+		mode = 0660
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("unable to set the permissions of %s: %v", path, err)
+	}
+	return lis, nil
+}
+
+{{ if .Systemd -}}
+// {{.LowerName}}ActivationListener returns the first listener passed by
+// systemd socket activation (sd_listen_fds), or nil when the process was not
+// socket activated. The activation variables are cleared so that children
+// do not inherit them.
+func {{.LowerName}}ActivationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	// Passed descriptors start at 3, right after stdin, stdout and stderr.
+	const listenFDsStart = 3
+	f := os.NewFile(listenFDsStart, "LISTEN_FD_3")
+	defer f.Close()
+	lis, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use the socket passed by systemd: %v", err)
+	}
+	return lis, nil
+}
+
+{{ end -}}
+// {{.LowerName}}SocketPath returns the path of a unix socket address, given
+// as unix:///abs/path, unix:rel/path or unix:/abs/path.
+func {{.LowerName}}SocketPath(addr string) (string, bool) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return strings.TrimPrefix(addr, "unix://"), true
+	case strings.HasPrefix(addr, "unix:"):
+		return strings.TrimPrefix(addr, "unix:"), true
+	}
+	return "", false
+}
+
+// {{.LowerName}}Mux routes gRPC requests to s and the others to handler.
+func {{.LowerName}}Mux(s *grpc.Server, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			s.ServeHTTP(w, r)
+			return
+		}
+		if handler == nil {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+{{- define "reload_env" }}
+{{- if .GenOps }}
+//	{{.EnvPrefix}}_OPS_ADDR      address serving the ops service, off by default
+//	{{.EnvPrefix}}_OPS_TOKEN     bearer token the ops service requires
+{{- end }}
+{{- if .BinaryLog }}
+//	{{.EnvPrefix}}_BINARY_LOG_PATH       file gRPC binary logs are written to, off by default
+//	{{.EnvPrefix}}_BINARY_LOG_MAX_BYTES  size the binary log is rotated at, 64 MiB by default
+//	{{.EnvPrefix}}_BINARY_LOG_MAX_FILES  rotated binary logs kept, 5 by default
+//	{{.EnvPrefix}}_BINARY_LOG_METHODS    comma-separated methods logged, all by default
+{{- end }}
+{{- if .EnvPresets }}
+//	{{.EnvPrefix}}_REFLECTION    "true" to register server reflection, preset by {{.Name}}EnvVar
+//	{{.EnvPrefix}}_TLS_CERT_FILE certificate served over TLS, cleartext by default
+//	{{.EnvPrefix}}_TLS_KEY_FILE  key of the certificate
+//	{{.EnvPrefix}}_REQUIRE_TLS   "true" to refuse to serve without a certificate, preset by {{.Name}}EnvVar
+{{- if not .Reload }}
+//	{{.EnvPrefix}}_LOG_LEVEL     minimum level of the logs, preset by {{.Name}}EnvVar
+{{- end }}
+{{- end }}
+{{- if .Reload }}
+//	{{.EnvPrefix}}_LOG_LEVEL     minimum level of the logs, {{ if .EnvPresets }}preset by {{.Name}}EnvVar{{ else }}"info" by default{{ end }}
+//	{{.EnvPrefix}}_RATE_LIMIT    calls served per second, unlimited by default
+//	{{.EnvPrefix}}_TOGGLES       comma-separated feature toggles turned on
+{{- end }}
+{{- end }}
+`)
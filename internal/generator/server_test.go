@@ -0,0 +1,29 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestServerOpsReload checks that the ops service of a reloading server
+// sets the log level on, and dumps, the configuration last applied rather
+// than the one the server started with, which reloads may have replaced.
+func TestServerOpsReload(t *testing.T) {
+	files := generateFiles(t, testRequest("reload=true,gen_ops=true", testFile("echo.proto", testService("Echo",
+		testMethod("Say", false, false),
+	))))
+	server, ok := files["echo_server.go"]
+	if !ok {
+		t.Fatal("echo_server.go is not generated")
+	}
+	for _, want := range []string{
+		"defer echoWatchReload(cfg, reloaded)()",
+		"ops.Config = func() interface{} { return reloaded.current() }",
+		"return reloaded.update(func(cfg *EchoConfig) { cfg.LogLevel = level })",
+		"err = h.apply(cfg)",
+	} {
+		if !strings.Contains(server, want) {
+			t.Errorf("echo_server.go does not contain %q:\n%s", want, server)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceConfig is the subset of the gRPC service config the plugin derives
+// from method options, see
+// https://github.com/grpc/grpc/blob/master/doc/service_config.md
+type serviceConfig struct {
+	LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+	MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	Timeout     string       `json:"timeout,omitempty"`
+	RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method,omitempty"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          uint32   `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// statusCodeNames are the status code names accepted in retry policies.
+var statusCodeNames = map[string]bool{
+	"OK": true, "CANCELLED": true, "UNKNOWN": true, "INVALID_ARGUMENT": true,
+	"DEADLINE_EXCEEDED": true, "NOT_FOUND": true, "ALREADY_EXISTS": true,
+	"PERMISSION_DENIED": true, "RESOURCE_EXHAUSTED": true,
+	"FAILED_PRECONDITION": true, "ABORTED": true, "OUT_OF_RANGE": true,
+	"UNIMPLEMENTED": true, "INTERNAL": true, "UNAVAILABLE": true,
+	"DATA_LOSS": true, "UNAUTHENTICATED": true,
+}
+
+// ServiceConfigJSON returns the service config of the service, rendered
+// once by Parse for both the JSON and the Go files.
+func (p Service) ServiceConfigJSON() string {
+	return p.serviceConfig
+}
+
+// renderServiceConfig renders the service config of the service: round
+// robin load balancing plus per-method timeouts and, for idempotent methods
+// only, retry policies.
+func (p Service) renderServiceConfig() (string, error) {
+	cfg := serviceConfig{
+		LoadBalancingConfig: []map[string]struct{}{{"round_robin": {}}},
+	}
+	for _, m := range p.Methods {
+		mc := methodConfig{
+			Name: []methodName{{Service: p.FullName(), Method: m.WireName()}},
+		}
+		if t := m.Timeout(); t != "" {
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				return "", errors.New("invalid timeout option on " + m.GetName() + ": " + err.Error())
+			}
+			mc.Timeout = strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+		}
+		if m.Idempotent() {
+			rp, err := m.retryPolicy()
+			if err != nil {
+				return "", err
+			}
+			mc.RetryPolicy = rp
+		} else if len(m.RetryableCodes()) > 0 && !p.Strict {
+			warnf("%s: ignoring retryable_codes on %s.%s: method is not idempotent", p.ProtoName, p.wireName, m.wireName)
+		}
+		if mc.Timeout == "" && mc.RetryPolicy == nil {
+			continue
+		}
+		cfg.MethodConfig = append(cfg.MethodConfig, mc)
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", errors.New("unable to marshal service config: " + err.Error())
+	}
+	return string(b), nil
+}
+
+// retryPolicy builds the retry policy of an idempotent method.
+func (m method) retryPolicy() (*retryPolicy, error) {
+	rp := &retryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       "0.1s",
+		MaxBackoff:           "1s",
+		BackoffMultiplier:    2,
+		RetryableStatusCodes: []string{"UNAVAILABLE"},
+	}
+	if n := m.MaxAttempts(); n > 0 {
+		rp.MaxAttempts = n
+	}
+	if codes := m.RetryableCodes(); len(codes) > 0 {
+		rp.RetryableStatusCodes = nil
+		for _, c := range codes {
+			c = strings.ToUpper(c)
+			if !statusCodeNames[c] {
+				return nil, errors.New("invalid retryable code on " + m.GetName() + ": " + c)
+			}
+			rp.RetryableStatusCodes = append(rp.RetryableStatusCodes, c)
+		}
+	}
+	return rp, nil
+}
+
+// clientRetry is the retry loop of an idempotent unary method in the
+// client wrapper, for clients without the retry policy of the service
+// config.
+type clientRetry struct {
+	MaxAttempts uint32
+	// Codes is the Go expression of the retryable codes.
+	Codes string
+}
+
+// ClientRetry returns the retry loop of the method in the client wrapper, or
+// nil when it is not retried there: non-idempotent methods are never
+// retried, streams are not replayed, and with gen_service_config the
+// channel retries idempotent methods itself.
+func (m method) ClientRetry() (*clientRetry, error) {
+	if !m.Idempotent() || m.GetClientStreaming() || m.GetServerStreaming() || m.service.GenServiceConfig {
+		return nil, nil
+	}
+	rp, err := m.retryPolicy()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, c := range rp.RetryableStatusCodes {
+		names = append(names, "codes."+goCodeName(c))
+	}
+	return &clientRetry{MaxAttempts: rp.MaxAttempts, Codes: "[]codes.Code{" + strings.Join(names, ", ") + "}"}, nil
+}
+
+// HasClientRetries reports whether the client wrapper retries any method.
+func (p Service) HasClientRetries() bool {
+	for _, m := range p.Methods {
+		if r, _ := m.ClientRetry(); r != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// goCodeName returns the name of the codes constant of a status code name.
+func goCodeName(name string) string {
+	switch name {
+	case "OK":
+		return "OK"
+	case "CANCELLED":
+		return "Canceled"
+	}
+	return camelCase(strings.ToLower(name))
+}
+
+var serviceConfigTmpl = newTemplate("serviceconfig", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+// {{.Name}}ServiceConfig is the gRPC service config derived from the method
+// options of {{.Name}}: load balancing, timeouts and retry policies.
+const {{.Name}}ServiceConfig = `+"`"+`{{.ServiceConfigJSON}}`+"`"+`
+`)
+
+var serviceConfigJSONTmpl = newTemplate("serviceconfigjson", "{{.ServiceConfigJSON}}\n")
@@ -0,0 +1,133 @@
+package generator
+
+var shadowTmpl = newTemplate("shadow", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	{{.GoImport}}
+)
+
+// {{.Name}}ShadowConfig controls which calls are mirrored to the shadow.
+type {{.Name}}ShadowConfig struct {
+	// Percent is the share of calls mirrored, from 0 to 100.
+	Percent float64
+	// Methods restricts mirroring to the named unary methods. Every unary
+	// method is mirrored when it is empty.
+	Methods []string
+	// Timeout bounds each shadow call, 5s by default.
+	Timeout time.Duration
+	// MaxInFlight bounds the concurrent shadow calls, 64 by default. Calls
+	// beyond it are dropped rather than queued.
+	MaxInFlight int
+	// OnError, if set, is called with the errors returned by the shadow.
+	OnError func(method string, err error)
+}
+
+// {{.Name}}ShadowStats counts the mirrored calls.
+type {{.Name}}ShadowStats struct {
+	Sent    uint64
+	Dropped uint64
+	Errors  uint64
+}
+
+// {{.Name}}Shadow is a {{.Name}} client serving every call from the primary
+// while sending a copy of unary requests to the shadow in the background.
+// Shadow responses are discarded; streams only go to the primary.
+type {{.Name}}Shadow struct {
+	{{.GoPrefix}}.{{.Name}}Client
+
+	shadow   {{.GoPrefix}}.{{.Name}}Client
+	cfg      {{.Name}}ShadowConfig
+	methods  map[string]bool
+	inFlight chan struct{}
+
+	sent, dropped, errors uint64
+}
+
+// New{{.Name}}Shadow returns a client decorating primary with mirroring to
+// shadow as configured by cfg.
+func New{{.Name}}Shadow(primary, shadow {{.GoPrefix}}.{{.Name}}Client, cfg {{.Name}}ShadowConfig) *{{.Name}}Shadow {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 64
+	}
+	s := &{{.Name}}Shadow{
+		{{.Name}}Client: primary,
+		shadow:          shadow,
+		cfg:             cfg,
+		inFlight:        make(chan struct{}, cfg.MaxInFlight),
+	}
+	if len(cfg.Methods) > 0 {
+		s.methods = make(map[string]bool, len(cfg.Methods))
+		for _, m := range cfg.Methods {
+			s.methods[m] = true
+		}
+	}
+	return s
+}
+
+// Stats returns the counts of mirrored calls so far.
+func (s *{{.Name}}Shadow) Stats() {{.Name}}ShadowStats {
+	return {{.Name}}ShadowStats{
+		Sent:    atomic.LoadUint64(&s.sent),
+		Dropped: atomic.LoadUint64(&s.dropped),
+		Errors:  atomic.LoadUint64(&s.errors),
+	}
+}
+
+// mirror runs call with a copy of req against the shadow in the background
+// when method is selected for mirroring. Call options are not passed on, as
+// they may capture headers or trailers for the caller.
+func (s *{{.Name}}Shadow) mirror(method string, req proto.Message, call func(ctx context.Context, req proto.Message) error) {
+	if s.methods != nil && !s.methods[method] {
+		return
+	}
+	if s.cfg.Percent < 100 && rand.Float64()*100 >= s.cfg.Percent {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+	atomic.AddUint64(&s.sent, 1)
+
+	req = proto.Clone(req)
+	go func() {
+		defer func() { <-s.inFlight }()
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		defer cancel()
+		if err := call(ctx, req); err != nil {
+			atomic.AddUint64(&s.errors, 1)
+			if s.cfg.OnError != nil {
+				s.cfg.OnError(method, err)
+			}
+		}
+	}()
+}
+{{ range .Methods }}
+	{{- if not (or .GetClientStreaming .GetServerStreaming) }}
+
+// {{.Name}} calls the primary and mirrors the request to the shadow.
+func (s *{{$.Name}}Shadow) {{.Name}}(ctx context.Context, in *{{$.GoPrefix}}.{{.InputGoName}}, opts ...grpc.CallOption) (*{{$.GoPrefix}}.{{.OutputGoName}}, error) {
+	s.mirror("{{.Name}}", in, func(ctx context.Context, req proto.Message) error {
+		_, err := s.shadow.{{.Name}}(ctx, req.(*{{$.GoPrefix}}.{{.InputGoName}}))
+		return err
+	})
+	return s.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+}
+	{{- end }}
+{{- end }}
+`)
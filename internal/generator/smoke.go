@@ -0,0 +1,172 @@
+package generator
+
+var smokeTmpl = newTemplate("smoke", `
+{{template "header" .}}
+
+// This command checks that a running instance serves the {{.FullName}}
+// contract it was generated from: server reflection must list
+// the service with every expected method, and the health probe must report
+// SERVING. It exits non-zero otherwise, which makes it usable as a
+// post-deploy check.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// serviceName is the fully qualified name of the service under test.
+const serviceName = "{{.FullName}}"
+
+// shape describes whether a method streams its input and output.
+type shape struct {
+	clientStreaming bool
+	serverStreaming bool
+}
+
+// expectedMethods are the methods the service must serve.
+var expectedMethods = map[string]shape{
+{{- range .Methods }}
+	"{{.WireName}}": {clientStreaming: {{.GetClientStreaming}}, serverStreaming: {{.GetServerStreaming}}},
+{{- end }}
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:4001", "address of the instance to check")
+	insecure := flag.Bool("insecure", false, "disable transport security")
+	healthService := flag.String("health-service", serviceName, "service name sent in the health probe, empty for the whole server")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of the whole check")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := run(ctx, *addr, *insecure, *healthService); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("ok")
+}
+
+func run(ctx context.Context, addr string, insecure bool, healthService string) error {
+	creds := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	if insecure {
+		creds = grpc.WithInsecure()
+	}
+	conn, err := grpc.DialContext(ctx, addr, creds, grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("unable to dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	if err := checkReflection(ctx, conn); err != nil {
+		return err
+	}
+	return checkHealth(ctx, conn, healthService)
+}
+
+// checkReflection verifies through server reflection that the service and
+// all of its methods are registered with the expected streaming shapes.
+func checkReflection(ctx context.Context, conn *grpc.ClientConn) error {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to open reflection stream: %v", err)
+	}
+	defer stream.CloseSend()
+
+	listed, err := reflect(stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, svc := range listed.GetListServicesResponse().GetService() {
+		if svc.GetName() == serviceName {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("service %s is not registered", serviceName)
+	}
+
+	files, err := reflect(stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName},
+	})
+	if err != nil {
+		return err
+	}
+	served := make(map[string]shape)
+	for _, raw := range files.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		var fd descriptor.FileDescriptorProto
+		if err := proto.Unmarshal(raw, &fd); err != nil {
+			return fmt.Errorf("unable to unmarshal file descriptor: %v", err)
+		}
+		for _, svc := range fd.GetService() {
+			name := svc.GetName()
+			if fd.GetPackage() != "" {
+				name = fd.GetPackage() + "." + name
+			}
+			if name != serviceName {
+				continue
+			}
+			for _, m := range svc.GetMethod() {
+				served[m.GetName()] = shape{
+					clientStreaming: m.GetClientStreaming(),
+					serverStreaming: m.GetServerStreaming(),
+				}
+			}
+		}
+	}
+
+	for name, want := range expectedMethods {
+		got, ok := served[name]
+		if !ok {
+			return fmt.Errorf("method %s/%s is not served", serviceName, name)
+		}
+		if got != want {
+			return fmt.Errorf("method %s/%s has a different streaming shape than expected", serviceName, name)
+		}
+	}
+	return nil
+}
+
+// reflect sends a single reflection request and waits for its response.
+func reflect(stream rpb.ServerReflection_ServerReflectionInfoClient, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if err := stream.Send(req); err != nil {
+		return nil, fmt.Errorf("unable to send reflection request: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("unable to receive reflection response: %v", err)
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, fmt.Errorf("reflection failed: %s", e.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// checkHealth runs the configured health probe.
+func checkHealth(ctx context.Context, conn *grpc.ClientConn, service string) error {
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+		Service: service,
+	})
+	if err != nil {
+		return fmt.Errorf("health probe failed: %v", err)
+	}
+	if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health probe reported %s", resp.GetStatus())
+	}
+	return nil
+}
+`)
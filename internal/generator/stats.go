@@ -0,0 +1,222 @@
+package generator
+
+var statsTmpl = newTemplate("stats", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	{{- if .GenOTelMetrics }}
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	{{- else }}
+	"github.com/prometheus/client_golang/prometheus"
+	{{- end }}
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}Stats is a stats.Handler tracking the connections of the server,
+// the lifetime of its streams and the bytes they carry, exported through
+// {{ if .GenOTelMetrics }}OpenTelemetry{{ else }}Prometheus{{ end }}. Unary calls count as
+// streams of a single message.
+type {{.Name}}Stats struct {
+	{{- if .GenOTelMetrics }}
+	connections metric.Int64UpDownCounter
+	streams     metric.Int64UpDownCounter
+	lifetime    metric.Float64Histogram
+	bytesIn     metric.Int64Counter
+	bytesOut    metric.Int64Counter
+	{{- else }}
+	connections prometheus.Gauge
+	streams     *prometheus.GaugeVec
+	lifetime    *prometheus.HistogramVec
+	bytesIn     *prometheus.CounterVec
+	bytesOut    *prometheus.CounterVec
+	{{- end }}
+}
+{{ if .GenOTelMetrics }}
+// New{{.Name}}Stats creates the instruments of the handler with the meter
+// provider, otel.GetMeterProvider() being used when it is nil.
+func New{{.Name}}Stats(provider metric.MeterProvider) (*{{.Name}}Stats, error) {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter("{{.FullName}}")
+
+	var (
+		s   {{.Name}}Stats
+		err error
+	)
+	s.connections, err = meter.Int64UpDownCounter("rpc.server.connections",
+		metric.WithDescription("Open connections."),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return nil, err
+	}
+	s.streams, err = meter.Int64UpDownCounter("rpc.server.active_streams",
+		metric.WithDescription("Streams being served."),
+		metric.WithUnit("{stream}"))
+	if err != nil {
+		return nil, err
+	}
+	s.lifetime, err = meter.Float64Histogram("rpc.server.stream_lifetime",
+		metric.WithDescription("Lifetime of the streams served."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	s.bytesIn, err = meter.Int64Counter("rpc.server.received_bytes",
+		metric.WithDescription("Bytes received on the wire."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	s.bytesOut, err = meter.Int64Counter("rpc.server.sent_bytes",
+		metric.WithDescription("Bytes sent on the wire."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+{{ else }}
+// New{{.Name}}Stats registers the metrics of the handler with reg,
+// prometheus.DefaultRegisterer being used when it is nil. Metrics already
+// registered, e.g. by an earlier handler, are shared.
+func New{{.Name}}Stats(reg prometheus.Registerer) (*{{.Name}}Stats, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	register := func(c prometheus.Collector) (prometheus.Collector, error) {
+		if err := reg.Register(c); err != nil {
+			if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				return are.ExistingCollector, nil
+			}
+			return nil, err
+		}
+		return c, nil
+	}
+	labels := prometheus.Labels{"grpc_service": "{{.FullName}}"}
+
+	var s {{.Name}}Stats
+	c, err := register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "grpc_server_connections",
+		Help:        "Open connections.",
+		ConstLabels: labels,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	s.connections = c.(prometheus.Gauge)
+	c, err = register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "grpc_server_active_streams",
+		Help:        "Streams being served.",
+		ConstLabels: labels,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	s.streams = c.(*prometheus.GaugeVec)
+	c, err = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "grpc_server_stream_lifetime_seconds",
+		Help:        "Lifetime of the streams served.",
+		ConstLabels: labels,
+		Buckets:     prometheus.ExponentialBuckets(0.001, 4, 12),
+	}, []string{"grpc_method", "grpc_code"}))
+	if err != nil {
+		return nil, err
+	}
+	s.lifetime = c.(*prometheus.HistogramVec)
+	c, err = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "grpc_server_received_bytes_total",
+		Help:        "Bytes received on the wire.",
+		ConstLabels: labels,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	s.bytesIn = c.(*prometheus.CounterVec)
+	c, err = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "grpc_server_sent_bytes_total",
+		Help:        "Bytes sent on the wire.",
+		ConstLabels: labels,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	s.bytesOut = c.(*prometheus.CounterVec)
+	return &s, nil
+}
+{{ end }}
+// {{.LowerName}}StatsMethodKey is the context key of the method of a stream.
+type {{.LowerName}}StatsMethodKey struct{}
+
+// TagRPC keeps the method of the stream for HandleRPC.
+func (s *{{.Name}}Stats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}StatsMethodKey{}, info.FullMethodName)
+}
+
+// HandleRPC records the beginning and end of streams and their payloads.
+func (s *{{.Name}}Stats) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	method, _ := ctx.Value({{.LowerName}}StatsMethodKey{}).(string)
+	{{- if .GenOTelMetrics }}
+	attrs := metric.WithAttributes(
+		attribute.String("rpc.service", "{{.FullName}}"),
+		attribute.String("rpc.method", method),
+	)
+	switch rs := rs.(type) {
+	case *stats.Begin:
+		s.streams.Add(ctx, 1, attrs)
+	case *stats.InPayload:
+		s.bytesIn.Add(ctx, int64(rs.WireLength), attrs)
+	case *stats.OutPayload:
+		s.bytesOut.Add(ctx, int64(rs.WireLength), attrs)
+	case *stats.End:
+		s.streams.Add(ctx, -1, attrs)
+		s.lifetime.Record(ctx, rs.EndTime.Sub(rs.BeginTime).Seconds(), metric.WithAttributes(
+			attribute.String("rpc.service", "{{.FullName}}"),
+			attribute.String("rpc.method", method),
+			attribute.Int64("rpc.grpc.status_code", int64(status.Code(rs.Error))),
+		))
+	}
+	{{- else }}
+	switch rs := rs.(type) {
+	case *stats.Begin:
+		s.streams.WithLabelValues(method).Inc()
+	case *stats.InPayload:
+		s.bytesIn.WithLabelValues(method).Add(float64(rs.WireLength))
+	case *stats.OutPayload:
+		s.bytesOut.WithLabelValues(method).Add(float64(rs.WireLength))
+	case *stats.End:
+		s.streams.WithLabelValues(method).Dec()
+		s.lifetime.WithLabelValues(method, status.Code(rs.Error).String()).Observe(rs.EndTime.Sub(rs.BeginTime).Seconds())
+	}
+	{{- end }}
+}
+
+// TagConn returns ctx unchanged.
+func (s *{{.Name}}Stats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn counts the open connections.
+func (s *{{.Name}}Stats) HandleConn(ctx context.Context, cs stats.ConnStats) {
+	switch cs.(type) {
+	case *stats.ConnBegin:
+		{{- if .GenOTelMetrics }}
+		s.connections.Add(ctx, 1)
+		{{- else }}
+		s.connections.Inc()
+		{{- end }}
+	case *stats.ConnEnd:
+		{{- if .GenOTelMetrics }}
+		s.connections.Add(ctx, -1)
+		{{- else }}
+		s.connections.Dec()
+		{{- end }}
+	}
+}
+`)
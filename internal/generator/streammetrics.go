@@ -0,0 +1,300 @@
+package generator
+
+var streamMetricsTmpl = newTemplate("stream-metrics", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	{{- if .StreamSpanEvents }}
+	"io"
+	"sync/atomic"
+	{{- end }}
+
+	"github.com/golang/protobuf/proto"
+	{{- if .GenOTelMetrics }}
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	{{- else }}
+	"github.com/prometheus/client_golang/prometheus"
+	{{- end }}
+	{{- if .StreamSpanEvents }}
+	{{- if not .GenOTelMetrics }}
+	"go.opentelemetry.io/otel/attribute"
+	{{- end }}
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/status"
+	{{- end }}
+	"google.golang.org/grpc"
+)
+
+// {{.Name}}StreamMetrics counts the messages received and sent on the
+// streams of {{.Name}} and records their sizes per method, exported through
+// {{ if .GenOTelMetrics }}OpenTelemetry{{ else }}Prometheus{{ end }}. Unary calls are left to the other metrics.
+{{- if .StreamSpanEvents }}
+// The lifecycle of the streams is recorded as events of the span of their
+// context: stream.open, stream.messages every EventEvery messages received
+// or sent, stream.half_close and stream.end with the termination reason.
+{{- end }}
+type {{.Name}}StreamMetrics struct {
+	{{- if .StreamSpanEvents }}
+	// EventEvery is the number of messages between stream.messages events,
+	// Default{{.Name}}StreamEventEvery when zero.
+	EventEvery int64
+
+	{{- end }}
+	{{- if .GenOTelMetrics }}
+	received     metric.Int64Counter
+	sent         metric.Int64Counter
+	receivedSize metric.Int64Histogram
+	sentSize     metric.Int64Histogram
+	{{- else }}
+	received     *prometheus.CounterVec
+	sent         *prometheus.CounterVec
+	receivedSize *prometheus.HistogramVec
+	sentSize     *prometheus.HistogramVec
+	{{- end }}
+}
+{{ if .GenOTelMetrics }}
+// New{{.Name}}StreamMetrics creates the instruments of the streams with the
+// meter provider, otel.GetMeterProvider() being used when it is nil.
+func New{{.Name}}StreamMetrics(provider metric.MeterProvider) (*{{.Name}}StreamMetrics, error) {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter("{{.FullName}}")
+
+	var (
+		m   {{.Name}}StreamMetrics
+		err error
+	)
+	m.received, err = meter.Int64Counter("rpc.server.stream.messages_received",
+		metric.WithDescription("Messages received on streams."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return nil, err
+	}
+	m.sent, err = meter.Int64Counter("rpc.server.stream.messages_sent",
+		metric.WithDescription("Messages sent on streams."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return nil, err
+	}
+	m.receivedSize, err = meter.Int64Histogram("rpc.server.request.size",
+		metric.WithDescription("Size of the messages received on streams."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	m.sentSize, err = meter.Int64Histogram("rpc.server.response.size",
+		metric.WithDescription("Size of the messages sent on streams."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+{{ else }}
+// New{{.Name}}StreamMetrics registers the metrics of the streams with reg,
+// prometheus.DefaultRegisterer being used when it is nil. Metrics already
+// registered, e.g. by an earlier instance, are shared.
+func New{{.Name}}StreamMetrics(reg prometheus.Registerer) (*{{.Name}}StreamMetrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	register := func(c prometheus.Collector) (prometheus.Collector, error) {
+		if err := reg.Register(c); err != nil {
+			if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				return are.ExistingCollector, nil
+			}
+			return nil, err
+		}
+		return c, nil
+	}
+	labels := prometheus.Labels{"grpc_service": "{{.FullName}}"}
+	sizes := prometheus.ExponentialBuckets(64, 4, 10)
+
+	var m {{.Name}}StreamMetrics
+	c, err := register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "grpc_server_stream_messages_received_total",
+		Help:        "Messages received on streams.",
+		ConstLabels: labels,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	m.received = c.(*prometheus.CounterVec)
+	c, err = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "grpc_server_stream_messages_sent_total",
+		Help:        "Messages sent on streams.",
+		ConstLabels: labels,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	m.sent = c.(*prometheus.CounterVec)
+	c, err = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "grpc_server_stream_message_received_bytes",
+		Help:        "Size of the messages received on streams.",
+		ConstLabels: labels,
+		Buckets:     sizes,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	m.receivedSize = c.(*prometheus.HistogramVec)
+	c, err = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "grpc_server_stream_message_sent_bytes",
+		Help:        "Size of the messages sent on streams.",
+		ConstLabels: labels,
+		Buckets:     sizes,
+	}, []string{"grpc_method"}))
+	if err != nil {
+		return nil, err
+	}
+	m.sentSize = c.(*prometheus.HistogramVec)
+	return &m, nil
+}
+{{ end }}
+{{- if .StreamSpanEvents }}
+// Default{{.Name}}StreamEventEvery is the number of messages between
+// stream.messages events when EventEvery is zero.
+const Default{{.Name}}StreamEventEvery = 100
+{{ end }}
+// StreamInterceptor instruments the messages of stream calls.
+func (m *{{.Name}}StreamMetrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		{{- if .GenOTelMetrics }}
+		attrs := metric.WithAttributes(
+			attribute.String("rpc.service", "{{.FullName}}"),
+			attribute.String("rpc.method", info.FullMethod),
+		)
+		s := &{{.LowerName}}InstrumentedStream{ServerStream: ss, metrics: m, attrs: attrs}
+		{{- else }}
+		s := &{{.LowerName}}InstrumentedStream{ServerStream: ss, metrics: m, method: info.FullMethod}
+		{{- end }}
+		{{- if .StreamSpanEvents }}
+		s.span = trace.SpanFromContext(ss.Context())
+		s.span.AddEvent("stream.open", trace.WithAttributes(
+			attribute.String("rpc.method", info.FullMethod),
+			attribute.Bool("rpc.client_streaming", info.IsClientStream),
+			attribute.Bool("rpc.server_streaming", info.IsServerStream),
+		))
+		err := handler(srv, s)
+		s.span.AddEvent("stream.end", trace.WithAttributes(
+			attribute.String("rpc.grpc.status_code", status.Code(err).String()),
+			attribute.String("stream.end_reason", {{.LowerName}}EndReason(ss.Context(), err)),
+			attribute.Int64("stream.messages_received", atomic.LoadInt64(&s.received)),
+			attribute.Int64("stream.messages_sent", atomic.LoadInt64(&s.sent)),
+		))
+		return err
+		{{- else }}
+		return handler(srv, s)
+		{{- end }}
+	}
+}
+{{- if .StreamSpanEvents }}
+
+// {{.LowerName}}EndReason tells why a stream ended with err: "completed",
+// "canceled" or "deadline_exceeded" by the client, or "error".
+func {{.LowerName}}EndReason(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case ctx.Err() == context.Canceled:
+		return "canceled"
+	case ctx.Err() == context.DeadlineExceeded:
+		return "deadline_exceeded"
+	}
+	return "error"
+}
+
+// messages adds a stream.messages event when count, the messages received
+// or sent so far, is a multiple of EventEvery.
+func (s *{{.LowerName}}InstrumentedStream) messages(count int64) {
+	every := s.metrics.EventEvery
+	if every <= 0 {
+		every = Default{{.Name}}StreamEventEvery
+	}
+	if count%every != 0 {
+		return
+	}
+	s.span.AddEvent("stream.messages", trace.WithAttributes(
+		attribute.Int64("stream.messages_received", atomic.LoadInt64(&s.received)),
+		attribute.Int64("stream.messages_sent", atomic.LoadInt64(&s.sent)),
+	))
+}
+{{- end }}
+
+// {{.LowerName}}InstrumentedStream records the messages going through a
+// stream.
+type {{.LowerName}}InstrumentedStream struct {
+	grpc.ServerStream
+	metrics *{{.Name}}StreamMetrics
+	{{- if .GenOTelMetrics }}
+	attrs metric.MeasurementOption
+	{{- else }}
+	method string
+	{{- end }}
+	{{- if .StreamSpanEvents }}
+	span trace.Span
+	// received and sent count the messages, atomically as streams may
+	// receive and send from different goroutines.
+	received, sent int64
+	{{- end }}
+}
+
+// {{.LowerName}}MessageSize returns the encoded size of msg, or 0 when it is
+// not a protobuf message.
+func {{.LowerName}}MessageSize(msg interface{}) int {
+	if pm, ok := msg.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
+
+func (s *{{.LowerName}}InstrumentedStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		size := {{.LowerName}}MessageSize(msg)
+		{{- if .GenOTelMetrics }}
+		s.metrics.received.Add(s.Context(), 1, s.attrs)
+		s.metrics.receivedSize.Record(s.Context(), int64(size), s.attrs)
+		{{- else }}
+		s.metrics.received.WithLabelValues(s.method).Inc()
+		s.metrics.receivedSize.WithLabelValues(s.method).Observe(float64(size))
+		{{- end }}
+		{{- if .StreamSpanEvents }}
+		s.messages(atomic.AddInt64(&s.received, 1))
+		{{- end }}
+	}
+	{{- if .StreamSpanEvents }}
+	if err == io.EOF {
+		s.span.AddEvent("stream.half_close", trace.WithAttributes(
+			attribute.Int64("stream.messages_received", atomic.LoadInt64(&s.received)),
+		))
+	}
+	{{- end }}
+	return err
+}
+
+func (s *{{.LowerName}}InstrumentedStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		size := {{.LowerName}}MessageSize(msg)
+		{{- if .GenOTelMetrics }}
+		s.metrics.sent.Add(s.Context(), 1, s.attrs)
+		s.metrics.sentSize.Record(s.Context(), int64(size), s.attrs)
+		{{- else }}
+		s.metrics.sent.WithLabelValues(s.method).Inc()
+		s.metrics.sentSize.WithLabelValues(s.method).Observe(float64(size))
+		{{- end }}
+		{{- if .StreamSpanEvents }}
+		s.messages(atomic.AddInt64(&s.sent, 1))
+		{{- end }}
+	}
+	return err
+}
+`)
@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The field numbers of the options of service_gen.proto. Extensions in this
+// range that this version of the plugin does not know are typically options
+// of a newer version, which it would silently ignore.
+const (
+	minServiceGenOption = 52000
+	maxServiceGenOption = 52999
+)
+
+// strictProblems returns what strict=true rejects in the service: a proto
+// file without go_package, names sanitized into Go identifiers, inputs and
+// outputs declared in no file of the request, and unknown service_gen
+// options.
+func (p *Service) strictProblems() []string {
+	var problems []string
+	if p.file.GetOptions().GetGoPackage() == "" {
+		problems = append(problems, p.ProtoName+" has no go_package option")
+	}
+	if p.GetName() != p.wireName {
+		problems = append(problems, fmt.Sprintf("service %s is sanitized into %s", p.wireName, p.GetName()))
+	}
+	problems = append(problems, unknownOptions("service "+p.wireName, p.GetOptions())...)
+
+	checked := make(map[string]bool)
+	for _, m := range p.Methods {
+		if m.GetName() != m.wireName {
+			problems = append(problems, fmt.Sprintf("method %s is sanitized into %s", m.wireName, m.GetName()))
+		}
+		problems = append(problems, unknownOptions("method "+m.wireName, m.GetOptions())...)
+		if len(m.RetryableCodes()) > 0 && !m.Idempotent() {
+			problems = append(problems, fmt.Sprintf("method %s sets retryable_codes but is not idempotent", m.wireName))
+		}
+		for _, name := range []string{m.GetInputType(), m.GetOutputType()} {
+			msg, ok := p.messages[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("method %s uses unresolved type %s", m.wireName, name))
+				continue
+			}
+			if checked[name] {
+				continue
+			}
+			checked[name] = true
+			for _, f := range msg.GetField() {
+				problems = append(problems, unknownOptions("field "+strings.TrimPrefix(name, ".")+"."+f.GetName(), f.GetOptions())...)
+			}
+		}
+	}
+	return problems
+}
+
+// unknownOptions reports the service_gen options set on opts that this
+// version of the plugin does not know, where names the options' owner.
+func unknownOptions(where string, opts proto.Message) []string {
+	if opts == nil || reflect.ValueOf(opts).IsNil() {
+		return nil
+	}
+	descs, err := proto.ExtensionDescs(opts)
+	if err != nil {
+		return []string{where + ": unable to read options: " + err.Error()}
+	}
+	var problems []string
+	for _, d := range descs {
+		if d.ExtensionType == nil && d.Field >= minServiceGenOption && d.Field <= maxServiceGenOption {
+			problems = append(problems, fmt.Sprintf("%s sets unknown option %d", where, d.Field))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// checkStrict fails the generation of the services with strict=true when
+// any of them has strictProblems.
+func checkStrict(ps []*Service) error {
+	var problems []string
+	for _, p := range ps {
+		if !p.Strict {
+			continue
+		}
+		for _, problem := range p.strictProblems() {
+			problems = append(problems, p.ProtoName+": "+problem)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("strict mode:\n  " + strings.Join(problems, "\n  "))
+}
+
+// unknownParams returns the parameters of param that are no option, sorted.
+func unknownParams(param map[string][]string, bools map[string]*bool) []string {
+	var unknown []string
+	for key := range param {
+		if _, ok := bools[key]; !ok && !valueParams[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// valueParams are the parameters taking other values than booleans.
+var valueParams = map[string]bool{
+	"GoPrefix":          true,
+	"GoPackageName":     true,
+	"GoImport":          true,
+	"config":            true,
+	"services":          true,
+	"methods":           true,
+	"template_dir":      true,
+	"stubs_dir":         true,
+	"output_dir":        true,
+	"backup":            true,
+	"paths":             true,
+	"constructor_style": true,
+	"layout":            true,
+	"support":           true,
+	"todo_format":       true,
+	"stub_behavior":     true,
+	"errstyle":          true,
+	"deadline_reserve":  true,
+	"config_backend":    true,
+	"feature_flag_code": true,
+	"env_presets":       true,
+}
@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Subscriptions returns the methods streaming outputs for a single input.
+func (p Service) Subscriptions() []method {
+	var ms []method
+	for _, m := range p.Methods {
+		if m.GetServerStreaming() && !m.GetClientStreaming() {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// resumeToken describes the (service_gen.resume_token_field) of a method.
+type resumeToken struct {
+	// Name is the Go name of the field.
+	Name string
+	// Set is the condition under which a token t was received.
+	Set string
+	// Value is the value of the input field holding t.
+	Value string
+}
+
+// ResumeToken returns the resume token of the method, or nil when it has
+// none. The field must be a string, bytes or integer field of both the
+// input and the output.
+func (m method) ResumeToken() (*resumeToken, error) {
+	name := m.ResumeTokenField()
+	if name == "" {
+		return nil, nil
+	}
+	invalid := func(why string) error {
+		return errors.New("invalid resume_token_field option on " + m.GetName() + ": " + why)
+	}
+
+	var (
+		types  []descriptor.FieldDescriptorProto_Type
+		proto2 bool
+	)
+	for _, typeName := range []string{m.GetInputType(), m.GetOutputType()} {
+		msg, ok := m.messages[typeName]
+		if !ok {
+			return nil, invalid("unknown message " + typeName)
+		}
+		var field *descriptor.FieldDescriptorProto
+		for _, f := range msg.GetField() {
+			if f.GetName() == name {
+				field = f
+			}
+		}
+		if field == nil {
+			return nil, invalid(msg.GoName + " has no field " + name)
+		}
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED || field.OneofIndex != nil {
+			return nil, invalid(name + " must be a singular field outside of oneofs")
+		}
+		types = append(types, field.GetType())
+		if typeName == m.GetInputType() {
+			proto2 = msg.Syntax != "proto3"
+		}
+	}
+	if types[0] != types[1] {
+		return nil, invalid(name + " has different types in the input and the output")
+	}
+
+	t := &resumeToken{Name: goFieldName(name), Value: "t"}
+	if proto2 && types[0] != descriptor.FieldDescriptorProto_TYPE_BYTES {
+		t.Value = "&t"
+	}
+	switch types[0] {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		t.Set = `t != ""`
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		t.Set = "len(t) > 0"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		t.Set = "t != 0"
+	default:
+		return nil, invalid(name + " must be a string, bytes or integer field")
+	}
+	return t, nil
+}
+
+var subscribeTmpl = newTemplate("subscribe", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	{{.GoImport}}
+)
+
+// {{.LowerName}}Transient reports whether a subscription should reconnect
+// after err.
+func {{.LowerName}}Transient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// {{.LowerName}}Backoff waits before reconnection attempt n, doubling the
+// delay from min up to max with full jitter. It returns false when ctx is
+// done first.
+func {{.LowerName}}Backoff(ctx context.Context, n int, min, max time.Duration) bool {
+	d := min
+	for i := 0; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	t := time.NewTimer(time.Duration(rand.Int63n(int64(d) + 1)))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+{{ range .Subscriptions }}
+{{- $token := .ResumeToken }}
+// {{.Service.Name}}{{.Name}}Subscription receives the outputs of {{.Name}},
+// re-establishing the stream with backoff after transient errors
+{{- if $token }}, resuming
+// from the {{$token.Name}} of the last output received{{ end }}.
+type {{.Service.Name}}{{.Name}}Subscription struct {
+	// MinBackoff and MaxBackoff bound the delay between reconnections.
+	MinBackoff, MaxBackoff time.Duration
+
+	ctx      context.Context
+	client   {{.Service.GoPrefix}}.{{.Service.Name}}Client
+	input    *{{.Service.GoPrefix}}.{{.InputGoName}}
+	opts     []grpc.CallOption
+	stream   {{.Service.GoPrefix}}.{{.ClientStreamName}}
+	failures int
+}
+
+// Subscribe{{.Service.Name}}{{.Name}} subscribes to {{.Name}}, opening the
+// stream on the first call to Recv. It is closed when ctx is done.
+func Subscribe{{.Service.Name}}{{.Name}}(ctx context.Context, client {{.Service.GoPrefix}}.{{.Service.Name}}Client, input *{{.Service.GoPrefix}}.{{.InputGoName}}, opts ...grpc.CallOption) *{{.Service.Name}}{{.Name}}Subscription {
+	return &{{.Service.Name}}{{.Name}}Subscription{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+		ctx:        ctx,
+		client:     client,
+		input:      proto.Clone(input).(*{{.Service.GoPrefix}}.{{.InputGoName}}),
+		opts:       opts,
+	}
+}
+
+// Recv returns the next output. It returns io.EOF once the server ends the
+// stream, and the error of the last attempt on errors that are not
+// transient or once ctx is done.
+func (s *{{.Service.Name}}{{.Name}}Subscription) Recv() (*{{.Service.GoPrefix}}.{{.OutputGoName}}, error) {
+	for {
+		if s.stream == nil {
+			stream, err := s.client.{{.Name}}(s.ctx, s.input, s.opts...)
+			if err != nil {
+				if err := s.retry(err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			s.stream = stream
+		}
+
+		out, err := s.stream.Recv()
+		if err == nil {
+			s.failures = 0
+			{{- if $token }}
+			if t := out.Get{{$token.Name}}(); {{$token.Set}} {
+				s.input.{{$token.Name}} = {{$token.Value}}
+			}
+			{{- end }}
+			return out, nil
+		}
+		s.stream = nil
+		if err == io.EOF {
+			return nil, err
+		}
+		if err := s.retry(err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retry waits before reconnecting after err, or returns err when it is not
+// worth reconnecting.
+func (s *{{.Service.Name}}{{.Name}}Subscription) retry(err error) error {
+	if !{{.Service.LowerName}}Transient(err) {
+		return err
+	}
+	s.failures++
+	if !{{.Service.LowerName}}Backoff(s.ctx, s.failures-1, s.MinBackoff, s.MaxBackoff) {
+		return err
+	}
+	return nil
+}
+{{ end }}
+`)
@@ -0,0 +1,163 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HasSunsets reports whether any method of the service has a sunset date.
+func (p Service) HasSunsets() bool {
+	for _, m := range p.Methods {
+		if m.Sunset() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SunsetExpr returns the Go expression of the sunset time of the method,
+// parsed as a date, midnight UTC, or as an RFC 3339 time.
+func (m method) SunsetExpr() (string, error) {
+	t, err := time.Parse("2006-01-02", m.Sunset())
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, m.Sunset()); err != nil {
+			return "", errors.New("invalid sunset option on " + m.GetName() + ": " + m.Sunset())
+		}
+	}
+	t = t.UTC()
+	return fmt.Sprintf("time.Date(%d, time.%s, %d, %d, %d, %d, 0, time.UTC)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second()), nil
+}
+
+// sunsetTmpl declares the sunset interceptors in the service file of
+// services with methods annotated with (service_gen.sunset).
+var sunsetTmpl = `
+{{- define "sunset_interceptors" }}
+// {{.Name}}Sunsets are the times deprecated methods are retired at, by full
+// method name.
+var {{.Name}}Sunsets = map[string]time.Time{
+	{{- range .Methods }}
+	{{- if .Sunset }}
+	"/{{$.FullName}}/{{.WireName}}": {{.SunsetExpr}},
+	{{- end }}
+	{{- end }}
+}
+
+// {{.Name}}SunsetReason is the reason of the ErrorInfo detail of calls to
+// retired methods.
+const {{.Name}}SunsetReason = "METHOD_SUNSET"
+
+// {{.Name}}Sunset answers the calls to deprecated methods with Deprecation,
+// Sunset and Warning headers until their sunset, then fails them with
+// Unimplemented and an ErrorInfo detail. Calls to deprecated methods are
+// counted, so that their remaining users can be tracked down.
+type {{.Name}}Sunset struct {
+	{{- if .GenOTelMetrics }}
+	calls metric.Int64Counter
+	{{- else }}
+	calls *prometheus.CounterVec
+	{{- end }}
+}
+{{ if .GenOTelMetrics }}
+// New{{.Name}}Sunset creates the call counter with the meter provider,
+// otel.GetMeterProvider() being used when it is nil.
+func New{{.Name}}Sunset(provider metric.MeterProvider) (*{{.Name}}Sunset, error) {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	calls, err := provider.Meter("{{.FullName}}").Int64Counter("rpc.server.deprecated_calls",
+		metric.WithDescription("Calls to deprecated methods."),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Name}}Sunset{calls: calls}, nil
+}
+{{ else }}
+// New{{.Name}}Sunset registers the call counter with reg,
+// prometheus.DefaultRegisterer being used when it is nil.
+func New{{.Name}}Sunset(reg prometheus.Registerer) (*{{.Name}}Sunset, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	calls := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "grpc_server_deprecated_calls_total",
+		Help:        "Calls to deprecated methods.",
+		ConstLabels: prometheus.Labels{"grpc_service": "{{.FullName}}"},
+	}, []string{"grpc_method", "retired"})
+	if err := reg.Register(calls); err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			return nil, err
+		}
+		calls = are.ExistingCollector.(*prometheus.CounterVec)
+	}
+	return &{{.Name}}Sunset{calls: calls}, nil
+}
+{{ end }}
+// check counts a call to method and returns the headers of its deprecation,
+// or the error of its retirement.
+func (s *{{.Name}}Sunset) check(ctx context.Context, method string) (metadata.MD, error) {
+	sunset, ok := {{.Name}}Sunsets[method]
+	if !ok {
+		return nil, nil
+	}
+	retired := !time.Now().Before(sunset)
+	{{- if .GenOTelMetrics }}
+	s.calls.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("rpc.method", method),
+		attribute.Bool("retired", retired),
+	))
+	{{- else }}
+	s.calls.WithLabelValues(method, strconv.FormatBool(retired)).Inc()
+	{{- end }}
+
+	date := sunset.Format("2006-01-02")
+	if retired {
+		st := status.Newf(codes.Unimplemented, "%s was retired on %s", method, date)
+		if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   {{.Name}}SunsetReason,
+			Domain:   "{{.FullName}}",
+			Metadata: map[string]string{"sunset": sunset.Format(time.RFC3339)},
+		}); err == nil {
+			st = detailed
+		}
+		return nil, st.Err()
+	}
+	return metadata.Pairs(
+		"deprecation", "true",
+		"sunset", sunset.Format(http.TimeFormat),
+		"warning", fmt.Sprintf("299 - %q", method+" is deprecated and will be retired on "+date),
+	), nil
+}
+
+// UnaryInterceptor applies the sunsets to unary calls.
+func (s *{{.Name}}Sunset) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, err := s.check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if md != nil {
+			grpc.SetHeader(ctx, md)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor applies the sunsets to stream calls.
+func (s *{{.Name}}Sunset) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, err := s.check(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if md != nil {
+			ss.SetHeader(md)
+		}
+		return handler(srv, ss)
+	}
+}
+{{- end }}
+`
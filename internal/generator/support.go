@@ -0,0 +1,38 @@
+package generator
+
+import (
+	"path"
+	"strings"
+)
+
+// supportFile is a helper file of support=shared: it holds the builder of a
+// single message and is named after it, so that every protoc run generating
+// that builder into the Go package writes the same file, however many runs
+// target the package.
+type supportFile struct {
+	builder builder
+}
+
+// name returns the name of the file in the output directory of p.
+func (f supportFile) name(p *Service) string {
+	return path.Join(path.Dir(p.fileName), strings.ToLower(envName(strings.Replace(f.builder.GoName, "_", "", -1)))+"_builder.gen.go")
+}
+
+// supportFiles returns the shared helper files of p.
+func (p *Service) supportFiles() []supportFile {
+	if p.Support != "shared" {
+		return nil
+	}
+	var fs []supportFile
+	for _, b := range p.builders {
+		fs = append(fs, supportFile{builder: b})
+	}
+	return fs
+}
+
+// supportBuildersTmpl renders the builder of a supportFile. Its header
+// names no proto file or owner, which differ from one run to the next.
+var supportBuildersTmpl = newTemplate("support-builders", `
+// Code initially generated by protoc-gen-grpc-go-service
+// shared by the services generated into package {{.GoPackageName}}
+`+buildersBody)
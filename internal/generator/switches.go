@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// enum is an enum type declared in one of the request's proto files.
+type enum struct {
+	*descriptor.EnumDescriptorProto
+	// ValuePrefix prefixes the Go names of the values: the enum name for
+	// top-level enums, the enclosing message's Go name for nested ones.
+	ValuePrefix string
+	// Package is the proto package the enum is declared in.
+	Package string
+}
+
+// enumIndex maps fully qualified enum names, with their leading dot, to the
+// enums declared in the request.
+type enumIndex map[string]*enum
+
+// indexEnums indexes the enums, including nested ones, of files.
+func indexEnums(files []*descriptor.FileDescriptorProto) enumIndex {
+	idx := make(enumIndex)
+	for _, f := range files {
+		prefix := "."
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage() + "."
+		}
+		for _, e := range f.GetEnumType() {
+			idx[prefix+e.GetName()] = &enum{EnumDescriptorProto: e, ValuePrefix: camelCase(e.GetName()), Package: f.GetPackage()}
+		}
+		for _, d := range f.GetMessageType() {
+			idx.addNested(f.GetPackage(), prefix, "", d)
+		}
+	}
+	return idx
+}
+
+func (idx enumIndex) addNested(pkg, prefix, goPrefix string, d *descriptor.DescriptorProto) {
+	goName := goPrefix + camelCase(d.GetName())
+	prefix += d.GetName() + "."
+	for _, e := range d.GetEnumType() {
+		idx[prefix+e.GetName()] = &enum{EnumDescriptorProto: e, ValuePrefix: goName, Package: pkg}
+	}
+	for _, nested := range d.GetNestedType() {
+		idx.addNested(pkg, prefix, goName+"_", nested)
+	}
+}
+
+// inputSwitch is a switch over a oneof or an enum field of the input.
+type inputSwitch struct {
+	// Expr is the switched expression.
+	Expr  string
+	Cases []switchCase
+}
+
+// switchCase is a case of an inputSwitch.
+type switchCase struct {
+	// Expr is the case expression, "default" for the default case.
+	Expr string
+	// Note is the TODO note of the case.
+	Note string
+}
+
+// InputSwitches returns a switch over every top-level oneof of the input,
+// and over every top-level enum field outside of them, when the input is a
+// message of the service's proto package.
+func (m method) InputSwitches() []inputSwitch {
+	p := m.service
+	msg, ok := m.messages[m.GetInputType()]
+	if !p.GenSwitches || !ok || msg.Package != p.PackageName {
+		return nil
+	}
+
+	var switches []inputSwitch
+	for i, o := range msg.GetOneofDecl() {
+		name := goFieldName(o.GetName())
+		s := inputSwitch{Expr: "input." + name + ".(type)"}
+		for _, f := range msg.GetField() {
+			if f.OneofIndex == nil || int(f.GetOneofIndex()) != i {
+				continue
+			}
+			field := goFieldName(f.GetName())
+			s.Cases = append(s.Cases, switchCase{
+				Expr: "*" + p.GoPrefix + "." + msg.GoName + "_" + field,
+				Note: "Handle " + field,
+			})
+		}
+		s.Cases = append(s.Cases, switchCase{Expr: "nil", Note: "Handle a missing " + name})
+		switches = append(switches, s)
+	}
+	for _, f := range msg.GetField() {
+		if f.GetType() != descriptor.FieldDescriptorProto_TYPE_ENUM ||
+			f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED ||
+			f.OneofIndex != nil {
+			continue
+		}
+		e, ok := p.enums[f.GetTypeName()]
+		if !ok || e.Package != p.PackageName {
+			continue
+		}
+		name := goFieldName(f.GetName())
+		s := inputSwitch{Expr: "input.Get" + name + "()"}
+		seen := make(map[int32]bool)
+		for _, v := range e.GetValue() {
+			// Aliases share the number, and would be duplicate cases.
+			if seen[v.GetNumber()] {
+				continue
+			}
+			seen[v.GetNumber()] = true
+			s.Cases = append(s.Cases, switchCase{
+				Expr: p.GoPrefix + "." + e.ValuePrefix + "_" + v.GetName(),
+				Note: "Handle " + v.GetName(),
+			})
+		}
+		s.Cases = append(s.Cases, switchCase{Expr: "default", Note: "Handle unknown " + name + " values"})
+		switches = append(switches, s)
+	}
+	return switches
+}
@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// stubSymbols are the top-level symbols declared by the protobuf and gRPC
+// stubs the generated code builds on.
+type stubSymbols struct {
+	// decls holds the names of types, funcs, vars and consts.
+	decls map[string]bool
+	// methods holds the method names of the interfaces.
+	methods map[string]map[string]bool
+}
+
+// loadStubSymbols collects the symbols of the Go files in dir, which is
+// expected to hold the output of protoc-gen-go and its gRPC plugin.
+func loadStubSymbols(dir string) (*stubSymbols, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, errors.New("no Go files in " + dir)
+	}
+
+	s := &stubSymbols{
+		decls:   make(map[string]bool),
+		methods: make(map[string]map[string]bool),
+	}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				s.add(decl)
+			}
+		}
+	}
+	return s, nil
+}
+
+// add records the symbols declared by decl.
+func (s *stubSymbols) add(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			s.decls[d.Name.Name] = true
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch sp := spec.(type) {
+			case *ast.TypeSpec:
+				s.decls[sp.Name.Name] = true
+				if it, ok := sp.Type.(*ast.InterfaceType); ok {
+					methods := make(map[string]bool)
+					for _, m := range it.Methods.List {
+						for _, n := range m.Names {
+							methods[n.Name] = true
+						}
+					}
+					s.methods[sp.Name.Name] = methods
+				}
+			case *ast.ValueSpec:
+				for _, n := range sp.Names {
+					s.decls[n.Name] = true
+				}
+			}
+		}
+	}
+}
+
+// check lists the symbols the generated code refers to for p that the
+// stubs do not declare.
+func (s *stubSymbols) check(p *Service) []string {
+	var missing []string
+	reported := make(map[string]bool)
+	report := func(name string) {
+		if !reported[name] {
+			reported[name] = true
+			missing = append(missing, p.GoPrefix+"."+name)
+		}
+	}
+	need := func(name string) {
+		if !s.decls[name] {
+			report(name)
+		}
+	}
+	needMethod := func(iface, name string) {
+		if methods, ok := s.methods[iface]; ok && !methods[name] {
+			report(iface + "." + name)
+		}
+	}
+
+	need(p.GetName() + "Server")
+	need(p.GetName() + "Client")
+	need("New" + p.GetName() + "Client")
+	need("Register" + p.GetName() + "Server")
+	for _, m := range p.Methods {
+		needMethod(p.GetName()+"Server", m.GetName())
+		needMethod(p.GetName()+"Client", m.GetName())
+		need(m.InputGoName())
+		need(m.OutputGoName())
+		if m.GetClientStreaming() || m.GetServerStreaming() {
+			need(m.StreamName())
+			need(m.ClientStreamName())
+		}
+	}
+	return missing
+}
+
+// checkSymbols validates the services against the stubs in their StubsDir,
+// reporting every symbol the generated code would refer to in vain.
+func checkSymbols(ps []*Service) error {
+	loaded := make(map[string]*stubSymbols)
+	var problems []string
+	for _, p := range ps {
+		if p.StubsDir == "" {
+			continue
+		}
+		s, ok := loaded[p.StubsDir]
+		if !ok {
+			var err error
+			if s, err = loadStubSymbols(filepath.Clean(p.StubsDir)); err != nil {
+				return errors.New("unable to load stubs: " + err.Error())
+			}
+			loaded[p.StubsDir] = s
+		}
+		for _, name := range s.check(p) {
+			problems = append(problems, p.GetName()+": "+name+" is not declared in "+p.StubsDir)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("generated code does not match the stubs:\n  " + strings.Join(problems, "\n  "))
+}
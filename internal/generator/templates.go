@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"text/template"
+)
+
+// baseTmpl holds the blocks shared by every generated file.
+var baseTmpl = template.Must(template.New("base").Parse(`
+{{- define "header" -}}
+// Code initially generated by protoc-gen-grpc-go-service
+// source: {{.ProtoName}}
+{{- with .Owners }}
+//
+// AUTHORS:
+{{- range . }}
+//   {{.Name}}{{if .Methods}}: {{.Methods}}{{end}}
+{{- end }}
+{{- end }}
+{{- end -}}
+`))
+
+// newTemplate parses a built-in file template on top of the shared blocks.
+func newTemplate(name, text string) *template.Template {
+	return template.Must(template.Must(baseTmpl.Clone()).New(name).Parse(text))
+}
+
+// withOverrides layers the blocks defined in the *.tmpl files of dir over
+// the built-in template t, so that e.g. a single {{define "unary_body"}}
+// can restyle the generated stubs without forking the whole template.
+func withOverrides(t *template.Template, dir string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil || len(files) == 0 {
+		return t, err
+	}
+
+	c, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		// Parsing into a template of its own keeps any text outside of
+		// the definitions from replacing the file template.
+		// Override files saved with CRLF line endings would otherwise put
+		// carriage returns in the generated files.
+		if _, err := c.New(filepath.Base(f)).Parse(unixNewlines(string(b))); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// overrideKey identifies a built-in template layered with a directory.
+type overrideKey struct {
+	tmpl *template.Template
+	dir  string
+}
+
+// overrides caches the results of withOverrides, so that override files
+// are read and parsed once per invocation rather than once per generated
+// file. The cached templates are only ever executed, which is safe from
+// parallel workers.
+var overrides = struct {
+	sync.Mutex
+	templates map[overrideKey]*template.Template
+}{templates: make(map[overrideKey]*template.Template)}
+
+// cachedOverrides returns withOverrides(t, dir), computing it once.
+func cachedOverrides(t *template.Template, dir string) (*template.Template, error) {
+	overrides.Lock()
+	defer overrides.Unlock()
+
+	key := overrideKey{tmpl: t, dir: dir}
+	if c, ok := overrides.templates[key]; ok {
+		return c, nil
+	}
+	c, err := withOverrides(t, dir)
+	if err != nil {
+		return nil, err
+	}
+	overrides.templates[key] = c
+	return c, nil
+}
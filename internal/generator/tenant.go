@@ -0,0 +1,152 @@
+package generator
+
+var tenantTmpl = newTemplate("tenant", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// {{.Name}}TenantHeader is the metadata key carrying the tenant of a call.
+const {{.Name}}TenantHeader = "x-tenant-id"
+
+// {{.LowerName}}TenantRequired lists the methods annotated with
+// (service_gen.tenant_required).
+var {{.LowerName}}TenantRequired = map[string]bool{
+	{{- range .Methods }}
+		{{- if .TenantRequired }}
+	"{{.WireName}}": true,
+		{{- end }}
+	{{- end }}
+}
+
+{{ if not .GenCtxKeys }}
+type {{.LowerName}}TenantKey struct{}
+
+// With{{.Name}}Tenant returns a copy of ctx carrying tenant.
+func With{{.Name}}Tenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, {{.LowerName}}TenantKey{}, tenant)
+}
+
+// {{.Name}}TenantFromContext returns the tenant stored in ctx by the
+// {{.Name}}Tenancy interceptors, or "" when there is none.
+func {{.Name}}TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value({{.LowerName}}TenantKey{}).(string)
+	return tenant
+}
+{{ end }}
+
+// {{.LowerName}}TenantFromMetadata reads the tenant from the incoming
+// metadata.
+func {{.LowerName}}TenantFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get({{.Name}}TenantHeader); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// {{.Name}}Tenancy scopes {{.Name}} calls to the tenant making them: it
+// stores the tenant in the context of the handlers, tags logs and metrics
+// with it and rejects calls to methods requiring a tenant when there is none.
+type {{.Name}}Tenancy struct {
+	// Claims extracts the tenant from authenticated claims, e.g. those of a
+	// token verified by an earlier interceptor. It takes precedence over
+	// {{.Name}}TenantHeader, which is only trusted when Claims is nil.
+	Claims func(ctx context.Context) (string, bool)
+	// Logger, if set, logs every call with its tenant.
+	Logger *log.Logger
+	// Observe, if set, is called once every call has completed, e.g. to
+	// update metrics labeled by tenant.
+	Observe func(tenant, method string, code codes.Code, latency time.Duration)
+}
+
+// extract returns the tenant of the call.
+func (t *{{.Name}}Tenancy) extract(ctx context.Context) string {
+	if t.Claims != nil {
+		tenant, _ := t.Claims(ctx)
+		return tenant
+	}
+	return {{.LowerName}}TenantFromMetadata(ctx)
+}
+
+// scope returns the context of a call to the full method name, or the
+// error rejecting it.
+func (t *{{.Name}}Tenancy) scope(ctx context.Context, fullMethod string) (context.Context, string, error) {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	tenant := t.extract(ctx)
+	if tenant == "" && {{.LowerName}}TenantRequired[method] {
+		return ctx, method, status.Error(codes.Unauthenticated, "{{.Name}}: "+method+" requires a tenant")
+	}
+	return With{{.Name}}Tenant(ctx, tenant), method, nil
+}
+
+// done logs and observes a completed call.
+func (t *{{.Name}}Tenancy) done(tenant, method string, err error, start time.Time) {
+	latency := time.Since(start)
+	code := status.Code(err)
+	if t.Logger != nil {
+		t.Logger.Printf("tenant=%q method={{.FullName}}/%s code=%s latency=%s", tenant, method, code, latency)
+	}
+	if t.Observe != nil {
+		t.Observe(tenant, method, code, latency)
+	}
+}
+
+// UnaryInterceptor scopes unary calls to their tenant.
+func (t *{{.Name}}Tenancy) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, "/{{.FullName}}/") {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		ctx, method, err := t.scope(ctx, info.FullMethod)
+		if err != nil {
+			t.done("", method, err, start)
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		t.done({{.Name}}TenantFromContext(ctx), method, err, start)
+		return resp, err
+	}
+}
+
+// StreamInterceptor scopes streams to their tenant.
+func (t *{{.Name}}Tenancy) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, "/{{.FullName}}/") {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		ctx, method, err := t.scope(ss.Context(), info.FullMethod)
+		if err != nil {
+			t.done("", method, err, start)
+			return err
+		}
+		err = handler(srv, &{{.LowerName}}TenantStream{ServerStream: ss, ctx: ctx})
+		t.done({{.Name}}TenantFromContext(ctx), method, err, start)
+		return err
+	}
+}
+
+// {{.LowerName}}TenantStream exposes the tenant scoped context to stream
+// handlers.
+type {{.LowerName}}TenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *{{.LowerName}}TenantStream) Context() context.Context {
+	return s.ctx
+}
+`)
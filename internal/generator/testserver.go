@@ -0,0 +1,205 @@
+package generator
+
+var testServerTmpl = newTemplate("testserver", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	{{.GoImport}}
+)
+
+// {{.Name}}TestOption configures the server built by NewTest{{.Name}}Server.
+type {{.Name}}TestOption func(*{{.LowerName}}TestConfig)
+
+type {{.LowerName}}TestConfig struct {
+	service    {{.GoPrefix}}.{{.Name}}Server
+	unary      []grpc.UnaryServerInterceptor
+	stream     []grpc.StreamServerInterceptor
+	serverOpts []grpc.ServerOption
+	dialOpts   []grpc.DialOption
+}
+
+// With{{.Name}}TestService serves s instead of the default service.
+func With{{.Name}}TestService(s {{.GoPrefix}}.{{.Name}}Server) {{.Name}}TestOption {
+	return func(c *{{.LowerName}}TestConfig) { c.service = s }
+}
+
+// With{{.Name}}TestInterceptors chains interceptors, in order, onto the
+// calls served.
+func With{{.Name}}TestInterceptors(unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) {{.Name}}TestOption {
+	return func(c *{{.LowerName}}TestConfig) {
+		c.unary = append(c.unary, unary...)
+		c.stream = append(c.stream, stream...)
+	}
+}
+
+// With{{.Name}}TestServerOptions adds options to the gRPC server.
+func With{{.Name}}TestServerOptions(opts ...grpc.ServerOption) {{.Name}}TestOption {
+	return func(c *{{.LowerName}}TestConfig) { c.serverOpts = append(c.serverOpts, opts...) }
+}
+
+// With{{.Name}}TestDialOptions adds options to the client connection.
+func With{{.Name}}TestDialOptions(opts ...grpc.DialOption) {{.Name}}TestOption {
+	return func(c *{{.LowerName}}TestConfig) { c.dialOpts = append(c.dialOpts, opts...) }
+}
+{{ if .HasFeatureFlags }}
+// {{.LowerName}}TestFlags turns every feature flag on.
+type {{.LowerName}}TestFlags struct{}
+
+func ({{.LowerName}}TestFlags) Enabled(context.Context, string) bool { return true }
+{{ end }}
+// NewTest{{.Name}}Server serves {{.Name}} over an in-memory listener and
+// returns a client connected to it. By default the service is {{ if eq .Layout "handlers" }}routed to the
+// handlers of a {{ else }}a
+// {{ end }}{{.Name}}Service{{ if .HasFeatureFlags }} with every feature flag on{{ end }}.
+// Everything is torn down when the test ends, or earlier by calling cleanup.
+func NewTest{{.Name}}Server(t testing.TB, opts ...{{.Name}}TestOption) (client {{.GoPrefix}}.{{.Name}}Client, cleanup func()) {
+	t.Helper()
+	cfg := &{{.LowerName}}TestConfig{
+		{{- if eq .Layout "handlers" }}
+		service: New{{.Name}}Router({{.Name}}Service{ {{- if .HasFeatureFlags }}Flags: {{.LowerName}}TestFlags{}{{ end -}} }),
+		{{- else if .HasFeatureFlags }}
+		service: {{.Name}}Service{Flags: {{.LowerName}}TestFlags{}},
+		{{- else }}
+		service: {{.Name}}Service{},
+		{{- end }}
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	serverOpts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(cfg.unary...),
+		grpc.ChainStreamInterceptor(cfg.stream...),
+	}, cfg.serverOpts...)
+	srv := grpc.NewServer(serverOpts...)
+	{{.GoPrefix}}.Register{{.Name}}Server(srv, cfg.service)
+	go srv.Serve(lis)
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	}, cfg.dialOpts...)
+	conn, err := grpc.DialContext(context.Background(), "passthrough:///bufnet", dialOpts...)
+	if err != nil {
+		srv.Stop()
+		t.Fatalf("unable to dial {{.Name}}: %v", err)
+	}
+
+	var closed bool
+	cleanup = func() {
+		if closed {
+			return
+		}
+		closed = true
+		conn.Close()
+		srv.Stop()
+	}
+	t.Cleanup(cleanup)
+	return {{.GoPrefix}}.New{{.Name}}Client(conn), cleanup
+}
+{{- range .Methods }}
+{{- if and .GetClientStreaming .GetServerStreaming }}
+
+// {{.Service.Name}}{{.Name}}Step is a step of a scripted {{.Name}} stream,
+// doing the first of: sending Send, closing the sends, receiving a message
+// equal to Recv, or waiting for the stream to end with Code.
+type {{.Service.Name}}{{.Name}}Step struct {
+	Send      *{{.Service.GoPrefix}}.{{.InputGoName}}
+	CloseSend bool
+	Recv      *{{.Service.GoPrefix}}.{{.OutputGoName}}
+	End       bool
+	// Code is the status the stream ends with at an End step, OK for a
+	// clean end.
+	Code codes.Code
+	// Timeout bounds the wait of Recv and End steps, 1s when zero.
+	Timeout time.Duration
+}
+
+// Run{{.Service.Name}}{{.Name}}Script opens a {{.Name}} stream with client,
+// e.g. one returned by NewTest{{.Service.Name}}Server, and runs steps in
+// order. It fails t at the first step that does not go as scripted.
+func Run{{.Service.Name}}{{.Name}}Script(t testing.TB, client {{.Service.GoPrefix}}.{{.Service.Name}}Client, steps ...{{.Service.Name}}{{.Name}}Step) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.{{.Name}}(ctx)
+	if err != nil {
+		t.Fatalf("unable to open {{.Name}}: %v", err)
+	}
+
+	type received struct {
+		out *{{.Service.GoPrefix}}.{{.OutputGoName}}
+		err error
+	}
+	recv := func(i int, timeout time.Duration) received {
+		t.Helper()
+		if timeout <= 0 {
+			timeout = time.Second
+		}
+		ch := make(chan received, 1)
+		go func() {
+			out, err := stream.Recv()
+			ch <- received{out, err}
+		}()
+		select {
+		case r := <-ch:
+			return r
+		case <-time.After(timeout):
+			t.Fatalf("step %d: {{.Name}} received nothing within %v", i, timeout)
+			return received{}
+		}
+	}
+
+	for i, step := range steps {
+		switch {
+		case step.Send != nil:
+			if err := stream.Send(step.Send); err != nil {
+				t.Fatalf("step %d: unable to send %v: %v", i, step.Send, err)
+			}
+		case step.CloseSend:
+			if err := stream.CloseSend(); err != nil {
+				t.Fatalf("step %d: unable to close the sends: %v", i, err)
+			}
+		case step.Recv != nil:
+			r := recv(i, step.Timeout)
+			if r.err != nil {
+				t.Fatalf("step %d: expected %v, got error %v", i, step.Recv, r.err)
+			}
+			if !proto.Equal(r.out, step.Recv) {
+				t.Fatalf("step %d: expected %v, got %v", i, step.Recv, r.out)
+			}
+		case step.End:
+			r := recv(i, step.Timeout)
+			if r.err == nil {
+				t.Fatalf("step %d: expected the end of the stream, got %v", i, r.out)
+			}
+			if r.err == io.EOF {
+				r.err = nil
+			}
+			if code := status.Code(r.err); code != step.Code {
+				t.Fatalf("step %d: expected the stream to end with %v, got %v", i, step.Code, r.err)
+			}
+		default:
+			t.Fatalf("step %d does nothing", i)
+		}
+	}
+}
+{{- end }}
+{{- end }}
+`)
@@ -0,0 +1,141 @@
+package generator
+
+var traceCtxTmpl = newTemplate("tracectx", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// {{.Name}}TraceHeaders are the W3C trace context and B3 headers propagated
+// from incoming calls to outgoing ones, keeping traces continuous through
+// the mesh without a tracer in the service.
+var {{.Name}}TraceHeaders = []string{
+	"traceparent",
+	"tracestate",
+	"b3",
+	"x-b3-traceid",
+	"x-b3-spanid",
+	"x-b3-parentspanid",
+	"x-b3-sampled",
+	"x-b3-flags",
+}
+
+// {{.LowerName}}TraceKey is the context key of the trace headers of a call.
+type {{.LowerName}}TraceKey struct{}
+
+// With{{.Name}}Trace returns a copy of ctx carrying the trace headers found
+// in md.
+func With{{.Name}}Trace(ctx context.Context, md metadata.MD) context.Context {
+	trace := metadata.MD{}
+	for _, h := range {{.Name}}TraceHeaders {
+		if v := md.Get(h); len(v) > 0 {
+			trace.Set(h, v...)
+		}
+	}
+	if len(trace) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, {{.LowerName}}TraceKey{}, trace)
+}
+
+// {{.Name}}TraceFromContext returns the trace headers stored in ctx, or nil
+// when there are none.
+func {{.Name}}TraceFromContext(ctx context.Context) metadata.MD {
+	trace, _ := ctx.Value({{.LowerName}}TraceKey{}).(metadata.MD)
+	return trace
+}
+
+// {{.Name}}TraceID returns the trace ID of the call, from its traceparent or
+// B3 headers, or "" when it carries none. Add it to logs to find them from
+// traces.
+func {{.Name}}TraceID(ctx context.Context) string {
+	trace := {{.Name}}TraceFromContext(ctx)
+	// traceparent is version-traceid-parentid-flags.
+	if v := trace.Get("traceparent"); len(v) > 0 {
+		if parts := strings.Split(v[0], "-"); len(parts) == 4 {
+			return parts[1]
+		}
+	}
+	if v := trace.Get("x-b3-traceid"); len(v) > 0 {
+		return v[0]
+	}
+	// b3 is traceid-spanid[-sampled[-parentspanid]], or a lone sampling
+	// decision.
+	if v := trace.Get("b3"); len(v) > 0 {
+		if parts := strings.Split(v[0], "-"); len(parts) >= 2 {
+			return parts[0]
+		}
+	}
+	return ""
+}
+
+// {{.Name}}TracePropagation extracts the trace headers of incoming calls
+// into their context, and forwards them on the outgoing calls made with
+// that context.
+type {{.Name}}TracePropagation struct{}
+
+// UnaryInterceptor extracts the trace headers of unary calls.
+func ({{.Name}}TracePropagation) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		return handler(With{{.Name}}Trace(ctx, md), req)
+	}
+}
+
+// StreamInterceptor extracts the trace headers of stream calls.
+func ({{.Name}}TracePropagation) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		return handler(srv, &{{.LowerName}}TracedStream{ServerStream: ss, ctx: With{{.Name}}Trace(ss.Context(), md)})
+	}
+}
+
+// {{.LowerName}}TracedStream overrides the context of a stream.
+type {{.LowerName}}TracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *{{.LowerName}}TracedStream) Context() context.Context { return s.ctx }
+
+// {{.LowerName}}Forward returns ctx with its trace headers added to the
+// outgoing metadata, unless already set.
+func {{.LowerName}}Forward(ctx context.Context) context.Context {
+	trace := {{.Name}}TraceFromContext(ctx)
+	if len(trace) == 0 {
+		return ctx
+	}
+	out, _ := metadata.FromOutgoingContext(ctx)
+	var kv []string
+	for h, vs := range trace {
+		if len(out.Get(h)) > 0 {
+			continue
+		}
+		for _, v := range vs {
+			kv = append(kv, h, v)
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// UnaryClientInterceptor forwards the trace headers on unary calls.
+func ({{.Name}}TracePropagation) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker({{.LowerName}}Forward(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor forwards the trace headers on stream calls.
+func ({{.Name}}TracePropagation) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer({{.LowerName}}Forward(ctx), desc, cc, method, opts...)
+	}
+}
+`)
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// importsPGV reports whether f imports the protoc-gen-validate rules.
+func importsPGV(f *descriptor.FileDescriptorProto) bool {
+	for _, dep := range f.GetDependency() {
+		if dep == "validate/validate.proto" {
+			return true
+		}
+	}
+	return false
+}
+
+// Validated reports whether the inputs of the method are checked with the
+// Validate method protoc-gen-validate generates for them.
+func (m method) Validated() bool {
+	msg, ok := m.messages[m.GetInputType()]
+	return ok && m.service.GenPGV && msg.PGV
+}
+
+var validateTmpl = newTemplate("validate", `
+{{template "header" .}}
+
+package {{.GoPackageName}}
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// {{.LowerName}}FieldError is implemented by the errors of the Validate
+// methods protoc-gen-validate generates.
+type {{.LowerName}}FieldError interface {
+	error
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// {{.LowerName}}Validate checks input against its validate.rules, failing
+// with InvalidArgument and a BadRequest detail listing the fields in
+// violation.
+func {{.LowerName}}Validate(input interface{ Validate() error }) error {
+	err := input.Validate()
+	if err == nil {
+		return nil
+	}
+
+	errs := []error{err}
+	// The errors of ValidateAll list every violation.
+	if multi, ok := err.(interface{ AllErrors() []error }); ok {
+		errs = multi.AllErrors()
+	}
+	br := &errdetails.BadRequest{}
+	for _, err := range errs {
+		fe, ok := err.({{.LowerName}}FieldError)
+		if !ok {
+			continue
+		}
+		// Violations of embedded messages are nested in their cause.
+		field := fe.Field()
+		for {
+			inner, ok := fe.Cause().({{.LowerName}}FieldError)
+			if !ok {
+				break
+			}
+			fe = inner
+			field += "." + fe.Field()
+		}
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fe.Reason(),
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, err.Error())
+	if len(br.FieldViolations) > 0 {
+		if detailed, err := st.WithDetails(br); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+`)
@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// worker is a background loop of (service_gen.workers).
+type worker struct {
+	// Name is the name of the worker in the proto file, e.g. cache_refresh.
+	Name string
+	// GoName is the Go name of the worker, e.g. CacheRefresh.
+	GoName   string
+	Interval time.Duration
+}
+
+// IntervalExpr returns the Go expression of the interval of w.
+func (w worker) IntervalExpr() string {
+	return durationExpr(w.Interval)
+}
+
+var workerName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// HasWorkers reports whether the service declares background workers.
+func (p Service) HasWorkers() bool {
+	return len(p.WorkerSpecs()) > 0
+}
+
+// Workers returns the background workers of the service, declared as
+// name=interval, e.g. cache_refresh=30s.
+func (p Service) Workers() ([]worker, error) {
+	var ws []worker
+	seen := make(map[string]bool)
+	for _, spec := range p.WorkerSpecs() {
+		parts := strings.SplitN(spec, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !workerName.MatchString(name) {
+			return nil, errors.New("invalid workers option on " + p.GetName() + ": " + spec + ", expected name=interval, e.g. cache_refresh=30s")
+		}
+		if seen[name] {
+			return nil, errors.New("invalid workers option on " + p.GetName() + ": duplicate worker " + name)
+		}
+		seen[name] = true
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || d <= 0 {
+			return nil, errors.New("invalid workers option on " + p.GetName() + ": " + spec + ", the interval must be a positive duration")
+		}
+		ws = append(ws, worker{Name: name, GoName: camelCase(name), Interval: d})
+	}
+	return ws, nil
+}
+
+// workersTmpl declares the background workers in the service file of
+// services annotated with (service_gen.workers).
+var workersTmpl = `
+{{- define "workers" }}
+// {{.Name}}Worker is a background loop of {{.Name}}Service, calling Run
+// every Interval from Start until Stop. The errors and panics of Run are
+// logged, and the loop goes on at the next tick.
+type {{.Name}}Worker struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start starts the loop, which runs until Stop is called or ctx is done.
+func (w *{{.Name}}Worker) Start(ctx context.Context) error {
+	if w.done != nil {
+		return fmt.Errorf("worker %s is already started", w.Name)
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+	go w.loop(ctx)
+	return nil
+}
+
+// Stop stops the loop and waits for the run in progress to return, or for
+// ctx to be done.
+func (w *{{.Name}}Worker) Stop(ctx context.Context) error {
+	if w.done == nil {
+		return nil
+	}
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *{{.Name}}Worker) loop(ctx context.Context) {
+	defer close(w.done)
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.run(ctx)
+		}
+	}
+}
+
+func (w *{{.Name}}Worker) run(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("{{.Name}}: worker %s panicked: %v", w.Name, r)
+		}
+	}()
+	if err := w.Run(ctx); err != nil {
+		log.Printf("{{.Name}}: worker %s failed: %v", w.Name, err)
+	}
+}
+
+// Workers returns the background workers of the service, declared with
+// (service_gen.workers).
+{{- if .GenLifecycle }} RegisterLifecycle has Run{{.Name}}
+// start them before serving.
+{{- end }}
+func (s {{.Name}}Service) Workers() []*{{.Name}}Worker {
+	return []*{{.Name}}Worker{
+		{{- range .Workers }}
+		{Name: "{{.Name}}", Interval: {{.IntervalExpr}}, Run: s.Run{{.GoName}}},
+		{{- end }}
+	}
+}
+{{ if .GenLifecycle }}
+// RegisterLifecycle registers the workers of the service with lc.
+func (s {{.Name}}Service) RegisterLifecycle(lc *{{.Name}}Lifecycle) {
+	for _, w := range s.Workers() {
+		lc.Register("worker "+w.Name, w.Start, w.Stop)
+	}
+}
+{{ end }}
+{{- range .Workers }}
+// Run{{.GoName}} is one run of the {{.Name}} worker, every {{.Interval}}.
+func (s {{$.Name}}Service) Run{{.GoName}}(ctx context.Context) error {
+	// {{$.TodoNote (printf "Implement the %s worker" .Name)}}
+	return nil
+}
+{{ end }}
+{{- end }}
+`
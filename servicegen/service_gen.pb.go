@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: servicegen/service_gen.proto
+
+package servicegen
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	descriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+var E_Timeout = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52001,
+	Name:          "service_gen.timeout",
+	Tag:           "bytes,52001,opt,name=timeout",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_RetryableCodes = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52002,
+	Name:          "service_gen.retryable_codes",
+	Tag:           "bytes,52002,rep,name=retryable_codes",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_MaxAttempts = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*uint32)(nil),
+	Field:         52003,
+	Name:          "service_gen.max_attempts",
+	Tag:           "varint,52003,opt,name=max_attempts",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_Owner = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52004,
+	Name:          "service_gen.owner",
+	Tag:           "bytes,52004,opt,name=owner",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_FeatureFlag = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52005,
+	Name:          "service_gen.feature_flag",
+	Tag:           "bytes,52005,opt,name=feature_flag",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_TenantRequired = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         52006,
+	Name:          "service_gen.tenant_required",
+	Tag:           "varint,52006,opt,name=tenant_required",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_MaxRequestBytes = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*uint64)(nil),
+	Field:         52007,
+	Name:          "service_gen.max_request_bytes",
+	Tag:           "varint,52007,opt,name=max_request_bytes",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_HeartbeatInterval = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52008,
+	Name:          "service_gen.heartbeat_interval",
+	Tag:           "bytes,52008,opt,name=heartbeat_interval",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_ResumeTokenField = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52009,
+	Name:          "service_gen.resume_token_field",
+	Tag:           "bytes,52009,opt,name=resume_token_field",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_Downstream = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52010,
+	Name:          "service_gen.downstream",
+	Tag:           "bytes,52010,rep,name=downstream",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_SagaSteps = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52011,
+	Name:          "service_gen.saga_steps",
+	Tag:           "bytes,52011,rep,name=saga_steps",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_RequiredRoles = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52012,
+	Name:          "service_gen.required_roles",
+	Tag:           "bytes,52012,rep,name=required_roles",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_Sunset = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52013,
+	Name:          "service_gen.sunset",
+	Tag:           "bytes,52013,opt,name=sunset",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_CacheControl = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52014,
+	Name:          "service_gen.cache_control",
+	Tag:           "bytes,52014,opt,name=cache_control",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_BaggageFields = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52015,
+	Name:          "service_gen.baggage_fields",
+	Tag:           "bytes,52015,rep,name=baggage_fields",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_RateLimit = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52016,
+	Name:          "service_gen.rate_limit",
+	Tag:           "bytes,52016,opt,name=rate_limit",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_DedupeBy = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52017,
+	Name:          "service_gen.dedupe_by",
+	Tag:           "bytes,52017,opt,name=dedupe_by",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_DedupeWindow = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*uint32)(nil),
+	Field:         52018,
+	Name:          "service_gen.dedupe_window",
+	Tag:           "varint,52018,opt,name=dedupe_window",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_ServiceOwner = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         52201,
+	Name:          "service_gen.service_owner",
+	Tag:           "bytes,52201,opt,name=service_owner",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_Workers = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52202,
+	Name:          "service_gen.workers",
+	Tag:           "bytes,52202,rep,name=workers",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_LazyDeps = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         52203,
+	Name:          "service_gen.lazy_deps",
+	Tag:           "bytes,52203,rep,name=lazy_deps",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+var E_Sensitive = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         52101,
+	Name:          "service_gen.sensitive",
+	Tag:           "varint,52101,opt,name=sensitive",
+	Filename:      "servicegen/service_gen.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Timeout)
+	proto.RegisterExtension(E_RetryableCodes)
+	proto.RegisterExtension(E_MaxAttempts)
+	proto.RegisterExtension(E_Owner)
+	proto.RegisterExtension(E_FeatureFlag)
+	proto.RegisterExtension(E_TenantRequired)
+	proto.RegisterExtension(E_MaxRequestBytes)
+	proto.RegisterExtension(E_HeartbeatInterval)
+	proto.RegisterExtension(E_ResumeTokenField)
+	proto.RegisterExtension(E_Downstream)
+	proto.RegisterExtension(E_SagaSteps)
+	proto.RegisterExtension(E_RequiredRoles)
+	proto.RegisterExtension(E_Sunset)
+	proto.RegisterExtension(E_CacheControl)
+	proto.RegisterExtension(E_BaggageFields)
+	proto.RegisterExtension(E_RateLimit)
+	proto.RegisterExtension(E_DedupeBy)
+	proto.RegisterExtension(E_DedupeWindow)
+	proto.RegisterExtension(E_ServiceOwner)
+	proto.RegisterExtension(E_Workers)
+	proto.RegisterExtension(E_LazyDeps)
+	proto.RegisterExtension(E_Sensitive)
+}
+
+func init() { proto.RegisterFile("servicegen/service_gen.proto", fileDescriptor_43d4de4f3aa31568) }
+
+var fileDescriptor_43d4de4f3aa31568 = []byte{
+	// 634 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0xd5, 0x4b, 0x4f, 0xdc, 0x3a,
+	0x14, 0x07, 0x70, 0x5d, 0x71, 0x2f, 0x10, 0xc3, 0xc0, 0x65, 0x56, 0x57, 0x57, 0x7d, 0xb0, 0x64,
+	0x33, 0x33, 0x8b, 0x4a, 0x55, 0x6b, 0x44, 0x1f, 0xd0, 0x52, 0x21, 0xd1, 0x22, 0x85, 0x4a, 0x95,
+	0xba, 0xb1, 0x9c, 0xe4, 0x8c, 0x27, 0x22, 0xb1, 0x53, 0xfb, 0x84, 0x61, 0xba, 0xef, 0x57, 0x80,
+	0x75, 0xdf, 0xef, 0xe7, 0xb7, 0x6a, 0xfb, 0x25, 0x2a, 0xc7, 0x0e, 0x20, 0xb1, 0x30, 0x3b, 0x44,
+	0xce, 0xef, 0x3f, 0x39, 0xc7, 0xc9, 0x09, 0xb9, 0x60, 0x40, 0xef, 0xe7, 0x29, 0x08, 0x90, 0x03,
+	0xff, 0x27, 0x13, 0x20, 0xfb, 0x95, 0x56, 0xa8, 0xba, 0x73, 0xa7, 0xfe, 0xf5, 0xff, 0xb2, 0x50,
+	0x4a, 0x14, 0x30, 0x68, 0x2e, 0x25, 0xf5, 0x70, 0x90, 0x81, 0x49, 0x75, 0x5e, 0xa1, 0xd2, 0xae,
+	0x9c, 0x52, 0x32, 0x83, 0x79, 0x09, 0xaa, 0xc6, 0xee, 0xa5, 0xbe, 0xab, 0xee, 0xb7, 0xd5, 0xfd,
+	0xfb, 0x80, 0x23, 0x95, 0xed, 0x54, 0x98, 0x2b, 0x69, 0xfe, 0x7b, 0x7e, 0x38, 0xb5, 0xfc, 0xd7,
+	0x4a, 0x14, 0xb7, 0x80, 0x6e, 0x91, 0x45, 0x0d, 0xa8, 0x27, 0x3c, 0x29, 0x80, 0xa5, 0x2a, 0x03,
+	0x13, 0xcc, 0x78, 0x71, 0x38, 0xb5, 0x3c, 0xb5, 0x12, 0xc5, 0x0b, 0xc7, 0x70, 0xc3, 0x3a, 0xba,
+	0x41, 0xe6, 0x4b, 0x7e, 0xc0, 0x38, 0x22, 0x94, 0x15, 0x86, 0x73, 0x5e, 0x36, 0xf7, 0xd2, 0x89,
+	0xe7, 0x4a, 0x7e, 0x70, 0xdb, 0x23, 0x7a, 0x95, 0xfc, 0xa3, 0xc6, 0x12, 0x74, 0x50, 0xbf, 0xf2,
+	0x9d, 0xb8, 0x72, 0xfb, 0xe3, 0x43, 0xe0, 0x58, 0x6b, 0x60, 0xc3, 0x82, 0x8b, 0x20, 0x7f, 0xed,
+	0xf9, 0x9c, 0x57, 0x9b, 0x05, 0x17, 0x76, 0x18, 0x08, 0x92, 0x4b, 0x64, 0x1a, 0x9e, 0xd4, 0xb9,
+	0x86, 0x2c, 0x98, 0xf3, 0xa6, 0xc9, 0x99, 0x8d, 0x17, 0x1c, 0x8c, 0xbd, 0xa3, 0xdb, 0x64, 0xc9,
+	0x0e, 0xc3, 0xe6, 0x80, 0x41, 0x96, 0x4c, 0xf0, 0x1c, 0x93, 0x7d, 0xdb, 0x84, 0xfd, 0x1d, 0x2f,
+	0x96, 0xfc, 0x20, 0x76, 0x72, 0xdd, 0x42, 0xba, 0x43, 0xba, 0x23, 0xe0, 0x1a, 0x13, 0xe0, 0xc8,
+	0x72, 0x89, 0xa0, 0xf7, 0x79, 0x11, 0x8c, 0x7b, 0xe7, 0x7b, 0x5c, 0x3a, 0xb6, 0x5b, 0x9e, 0xd2,
+	0x07, 0xa4, 0xab, 0xc1, 0xd4, 0x25, 0x30, 0x54, 0x7b, 0x20, 0xd9, 0x30, 0x87, 0x22, 0xdc, 0xec,
+	0x7b, 0x1f, 0xf8, 0xaf, 0xb3, 0x0f, 0x2d, 0xdd, 0xb4, 0x92, 0xde, 0x22, 0x24, 0x53, 0x63, 0x69,
+	0x50, 0x03, 0x2f, 0x83, 0x39, 0x1f, 0xfc, 0x13, 0x74, 0xca, 0xd0, 0x9b, 0x84, 0x18, 0x2e, 0x38,
+	0x33, 0x08, 0x55, 0x78, 0x52, 0x1f, 0x7d, 0x42, 0x64, 0xcd, 0xae, 0x25, 0xf4, 0x1e, 0x59, 0x68,
+	0x4f, 0x8d, 0x69, 0x55, 0x9c, 0x63, 0xdc, 0x9f, 0x7c, 0x48, 0xa7, 0x75, 0xb1, 0x65, 0xf4, 0x1a,
+	0x99, 0x36, 0xb5, 0x34, 0x10, 0x7e, 0x9b, 0x3e, 0xfb, 0x79, 0xf8, 0x7a, 0x7a, 0x97, 0x74, 0x52,
+	0x9e, 0x8e, 0xec, 0x8b, 0x24, 0x51, 0xab, 0xf0, 0x09, 0x7d, 0xf1, 0x01, 0xf3, 0x0d, 0xdb, 0x70,
+	0xca, 0x76, 0x92, 0x70, 0x21, 0xb8, 0x00, 0x77, 0x2e, 0xe1, 0x4e, 0xbe, 0xb6, 0x9d, 0x78, 0xd7,
+	0x1c, 0x8a, 0xb1, 0x33, 0xd5, 0x1c, 0x81, 0x15, 0x79, 0x99, 0x87, 0xbb, 0xf9, 0xe6, 0x6f, 0x26,
+	0xb2, 0x66, 0xdb, 0x12, 0xba, 0x46, 0xa2, 0x0c, 0xb2, 0xba, 0x02, 0x96, 0x4c, 0x82, 0xfe, 0xbb,
+	0xf7, 0xb3, 0x8e, 0xac, 0x4f, 0xec, 0x3c, 0x3c, 0x1f, 0xe7, 0x32, 0x53, 0xe3, 0x60, 0xc4, 0x0f,
+	0xbf, 0x12, 0xe6, 0x1d, 0x7b, 0xd4, 0x28, 0xba, 0x49, 0x3a, 0xed, 0x42, 0x74, 0xbb, 0xe1, 0xf2,
+	0x99, 0x98, 0x5d, 0x77, 0xbd, 0xcd, 0xf9, 0x79, 0xe4, 0xe7, 0xea, 0xdd, 0x4e, 0xb3, 0x23, 0x56,
+	0xc9, 0xcc, 0x58, 0xe9, 0x3d, 0xd0, 0x26, 0x9c, 0xf0, 0xeb, 0xc8, 0x4d, 0xb4, 0x15, 0xf4, 0x06,
+	0x89, 0x0a, 0xfe, 0x74, 0xc2, 0x32, 0xa8, 0xce, 0xc1, 0x7f, 0x7b, 0x3e, 0x6b, 0xcd, 0x1d, 0xfb,
+	0x78, 0xae, 0x91, 0xc8, 0x80, 0x34, 0x39, 0xe6, 0xfb, 0xd0, 0xbd, 0x78, 0xc6, 0x37, 0x27, 0xd6,
+	0xea, 0x67, 0x47, 0x6e, 0xa9, 0x9c, 0x88, 0xf5, 0xd5, 0xc7, 0xd7, 0x45, 0x8e, 0xa3, 0x3a, 0xe9,
+	0xa7, 0xaa, 0x1c, 0x48, 0x83, 0x4a, 0x48, 0xd0, 0xee, 0xa3, 0x90, 0xf6, 0x04, 0xc8, 0x9e, 0xd0,
+	0x55, 0xda, 0x13, 0xaa, 0xe7, 0x5b, 0x1e, 0x9c, 0x7c, 0x61, 0x92, 0xe9, 0xa6, 0xec, 0xca, 0x9f,
+	0x01, 0x00, 0x2f, 0xbd, 0xee, 0x71, 0x76, 0x06, 0x00, 0x00,
+}